@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/crawler"
+)
+
+// Crawls from the seed URLs and saves every page plus manifest.json in -out:
+//
+//	go run ./cmd/crawl -out site -depth 2 -pages 50 https://go.dev
+func main() {
+	out := flag.String("out", "crawl", "directory for pages and the manifest")
+	depth := flag.Int("depth", 2, "links to follow away from a seed")
+	pages := flag.Int("pages", 100, "stop after this many pages")
+	workers := flag.Int("workers", 4, "pages fetched at once")
+	delay := flag.Duration("delay", time.Second, "minimum gap between requests to one host")
+	domains := flag.String("domains", "", "comma separated domains to stay within, default the seeds' hosts")
+	ignoreRobots := flag.Bool("ignore-robots", false, "do not read robots.txt")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: crawl [flags] URL...")
+		os.Exit(2)
+	}
+
+	opts := []crawler.Option{
+		crawler.WithOutput(*out),
+		crawler.WithMaxDepth(*depth),
+		crawler.WithMaxPages(*pages),
+		crawler.WithWorkers(*workers),
+		crawler.WithDelay(*delay),
+	}
+	if *domains != "" {
+		opts = append(opts, crawler.WithAllowedDomains(strings.Split(*domains, ",")...))
+	}
+	if *ignoreRobots {
+		opts = append(opts, crawler.IgnoreRobots())
+	}
+
+	// Ctrl-C stops the crawl but still writes the manifest
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	m, err := crawler.New(opts...).Crawl(ctx, flag.Args()...)
+	for _, p := range m.Pages {
+		if p.Error != "" {
+			fmt.Printf("%s is DOWN! (%s)\n", p.URL, p.Error)
+		} else {
+			fmt.Printf("depth %d %s -> %s (%d links)\n", p.Depth, p.URL, p.Path, p.Links)
+		}
+	}
+	fmt.Printf("%d pages in %s, skipped %v\n", len(m.Pages), m.Finished.Sub(m.Started).Round(time.Millisecond), m.Skipped)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
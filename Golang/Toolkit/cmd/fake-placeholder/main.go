@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/Shehbab-Kakkar/toolkit/jsonplaceholder/placeholdertest"
+)
+
+// Serves the in-memory JSONPlaceholder fake for offline development:
+//
+//	go run ./cmd/fake-placeholder -addr :3000
+//	go run ./cmd/todo-crud -base-url http://localhost:3000
+func main() {
+	addr := flag.String("addr", ":3000", "listen address")
+	flag.Parse()
+
+	log.Println("Fake JSONPlaceholder listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, placeholdertest.New()))
+}
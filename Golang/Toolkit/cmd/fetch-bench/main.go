@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/fetchbench"
+)
+
+// Compares URL_TO_FILE.WithoutGoRoutines.go, URL_TO_FILES.GoRoutineWait.go,
+// a worker pool and an errgroup against a local server:
+//
+//	go run ./cmd/fetch-bench -urls 500 -latency 20ms -jitter 30ms -fail 0.02
+func main() {
+	n := flag.Int("urls", 200, "URLs per run")
+	latency := flag.Duration("latency", 10*time.Millisecond, "server delay per request")
+	jitter := flag.Duration("jitter", 10*time.Millisecond, "extra random delay up to this")
+	fail := flag.Float64("fail", 0, "fraction of requests that get a 503")
+	workers := flag.Int("workers", 16, "pool and errgroup size")
+	skipSeq := flag.Bool("skip-sequential", false, "skip the slow one-at-a-time run")
+	flag.Parse()
+
+	srv := fetchbench.NewServer(fetchbench.ServerConfig{Latency: *latency, Jitter: *jitter, FailureRate: *fail, BodySize: 4096, Seed: 1})
+	defer srv.Close()
+	// allow every strategy to keep its connections instead of measuring dial cost
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: *n}}
+	urls := fetchbench.URLs(srv, *n)
+
+	strategies := []fetchbench.Strategy{
+		fetchbench.Unbounded(),
+		fetchbench.WorkerPool(*workers),
+		fetchbench.ErrGroup(*workers),
+	}
+	if !*skipSeq {
+		strategies = append([]fetchbench.Strategy{fetchbench.Sequential()}, strategies...)
+	}
+
+	var reports []fetchbench.Report
+	for _, s := range strategies {
+		reports = append(reports, fetchbench.Measure(context.Background(), s, client, urls))
+		client.CloseIdleConnections()
+	}
+	if err := fetchbench.WriteTable(os.Stdout, reports); err != nil {
+		log.Fatal(err)
+	}
+}
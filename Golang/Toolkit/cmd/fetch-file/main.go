@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/downloader/chunked"
+)
+
+// Downloads one large file in parallel ranges. Interrupt it and run the same
+// command again to resume from the .part file.
+//
+//	go run ./cmd/fetch-file -sha256 <hex> https://go.dev/dl/go1.23.0.src.tar.gz
+func main() {
+	out := flag.String("o", "", "output file, default the last path element of the URL")
+	sum := flag.String("sha256", "", "expected SHA-256 in hex")
+	chunk := flag.Int64("chunk", 8<<20, "bytes per range request")
+	workers := flag.Int("workers", 4, "ranges fetched at once")
+	retries := flag.Int("retries", 3, "retries per range")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fetch-file [flags] URL")
+		os.Exit(2)
+	}
+	url := flag.Arg(0)
+	dest := *out
+	if dest == "" {
+		dest = path.Base(url)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	res, err := chunked.Download(ctx, url, dest, chunked.Config{
+		ChunkSize:   *chunk,
+		Concurrency: *workers,
+		SHA256:      *sum,
+		Retries:     *retries,
+		Progress: func(p chunked.Progress) {
+			if p.Total > 0 {
+				fmt.Fprintf(os.Stderr, "\r%s: %5.1f%% of %d bytes", dest, 100*float64(p.Done)/float64(p.Total), p.Total)
+			} else {
+				fmt.Fprintf(os.Stderr, "\r%s: %d bytes", dest, p.Done)
+			}
+		},
+		ProgressInterval: 500 * time.Millisecond,
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %d bytes in %s (%d resumed), sha256 %s\n", res.Path, res.Size, time.Since(start).Round(time.Millisecond), res.Resumed, res.SHA256)
+}
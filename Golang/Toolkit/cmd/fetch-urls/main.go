@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/downloader"
+)
+
+// Goroutine/URL_TO_FILES.GoRoutineWait.go on a bounded worker pool:
+//
+//	go run ./cmd/fetch-urls -workers 2 https://www.golang.org https://www.google1.com https://www.medium.com
+//	go run ./cmd/fetch-urls -out pages -ext-from-type -gzip https://www.golang.org
+func main() {
+	workers := flag.Int("workers", 4, "URLs fetched at once")
+	timeout := flag.Duration("timeout", 10*time.Second, "per attempt timeout")
+	retries := flag.Int("retries", 2, "retries for connection errors, 429 and 5xx")
+	out := flag.String("out", "", "save each body in this directory")
+	typed := flag.Bool("ext-from-type", false, "name saved files by Content-Type instead of .txt")
+	compress := flag.Bool("gzip", false, "gzip saved files")
+	flag.Parse()
+
+	urls := flag.Args()
+	if len(urls) == 0 {
+		urls = []string{"https://www.golang.org", "https://www.google1.com", "https://www.medium.com"}
+	}
+
+	// Ctrl-C cancels the remaining downloads
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := []downloader.Option{
+		downloader.WithWorkers(*workers),
+		downloader.WithTimeout(*timeout),
+		downloader.WithRetries(*retries, 500*time.Millisecond),
+	}
+	if *out != "" {
+		saver := downloader.Saver{Dir: *out, ExtensionFromContentType: *typed, Gzip: *compress}
+		opts = append(opts, downloader.WithSink(saver.Sink))
+	}
+	d := downloader.New(opts...)
+
+	var summary downloader.Summary
+	start := time.Now()
+	for r := range d.Stream(ctx, urls) {
+		if r.OK() {
+			fmt.Printf("%s -> Status Code: %d, %d bytes in %s\n", r.URL, r.StatusCode, r.Bytes, r.Duration.Round(time.Millisecond))
+			if r.Path != "" {
+				fmt.Printf("Writing response Body to %s\n", r.Path)
+			}
+		} else {
+			fmt.Printf("%s is DOWN! (%v after %d attempts)\n", r.URL, r.Err, r.Attempts)
+		}
+		summary.Add(r)
+	}
+	summary.Elapsed = time.Since(start)
+	fmt.Println(summary)
+
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/mailqueue"
+)
+
+var notice = mailqueue.MustParseTemplate("notice",
+	"Hello from the mail queue",
+	"Hi {{.}},\nthis message went through a persistent queue.\n",
+	"<p>Hi {{.}},</p><p>this message went through a persistent queue.</p>")
+
+// Channel/Bufferred.Channel.go's emailSender for real: the addresses, or the
+// five from that example, are queued under -dir and sent through -smtp. Mail
+// still queued when it stops is sent by the next run. The password comes
+// from $SMTP_PASSWORD.
+//
+//	go run ./cmd/mail-queue -smtp smtp.example.com:587 -user me -from me@example.com a@example.com b@example.com
+func main() {
+	dir := flag.String("dir", "mail-queue", "queue directory")
+	addr := flag.String("smtp", "localhost:25", "SMTP server host:port")
+	user := flag.String("user", "", "AUTH PLAIN user name")
+	from := flag.String("from", "noreply@localhost", "sender address")
+	requireTLS := flag.Bool("require-tls", false, "refuse to send without STARTTLS")
+	workers := flag.Int("workers", 4, "concurrent SMTP connections")
+	flag.Parse()
+
+	to := flag.Args()
+	if len(to) == 0 {
+		for i := 0; i < 5; i++ {
+			to = append(to, fmt.Sprintf("%d@gmail.com", i))
+		}
+	}
+
+	sender := mailqueue.SMTP{Addr: *addr, Username: *user, Password: os.Getenv("SMTP_PASSWORD")}
+	if *requireTLS {
+		sender.StartTLS = mailqueue.TLSRequired
+	}
+	q, err := mailqueue.Open(mailqueue.Config{
+		Dir:       *dir,
+		Sender:    sender,
+		From:      *from,
+		Workers:   *workers,
+		Templates: map[string]*mailqueue.Template{"notice": notice},
+		BaseDelay: 10 * time.Second,
+		OnSent:    func(d mailqueue.Delivery) { log.Println("sent email to", d.To) },
+		OnRetry: func(d mailqueue.Delivery, wait time.Duration, err error) {
+			log.Printf("retrying %s in %s: %v", d.To, wait, err)
+		},
+		OnFailed: func(d mailqueue.Delivery, err error) { log.Printf("gave up on %s: %v", d.To, err) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, rcpt := range to {
+		if _, err := q.Enqueue(context.Background(), mailqueue.Message{To: []string{rcpt}, Template: "notice", Data: rcpt}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Println("done queueing")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		for range time.Tick(100 * time.Millisecond) {
+			if q.Stats().Pending == 0 {
+				stop()
+				return
+			}
+		}
+	}()
+	q.Run(ctx)
+	st := q.Stats()
+	fmt.Printf("sent %d, failed %d, still queued %d\n", st.Sent, st.Failed, st.Pending)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/pubsub"
+)
+
+// Channel/unbuffered.RecieveChannel.go's chan1 and chan2 as topics that other
+// processes can publish to and subscribe to. serve runs the broker; the other
+// commands are clients.
+//
+//	go run ./cmd/pubsub serve -addr :4222
+//	go run ./cmd/pubsub sub 'chan.>'
+//	go run ./cmd/pubsub pub chan.1 10
+//	go run ./cmd/pubsub reply rpc.ping pong
+//	go run ./cmd/pubsub req rpc.ping ping
+func main() {
+	addr := flag.String("addr", "localhost:4222", "broker address")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long req waits")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pubsub [-addr host:port] serve | sub <pattern> | pub <topic> [message] | req <topic> [message] | reply <topic> <answer>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if args[0] == "serve" {
+		srv := &pubsub.Server{Broker: pubsub.New()}
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		log.Println("broker listening on", *addr)
+		if err := srv.ListenAndServe(*addr); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := pubsub.Dial(ctx, *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	topic, message := args[1], strings.Join(args[2:], " ")
+
+	switch args[0] {
+	case "pub":
+		// with no message, every line of stdin is one
+		lines := []string{message}
+		if message == "" {
+			lines = nil
+			sc := bufio.NewScanner(os.Stdin)
+			for sc.Scan() {
+				lines = append(lines, sc.Text())
+			}
+		}
+		for _, l := range lines {
+			n, err := c.Publish(topic, []byte(l))
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("published to %d subscribers", n)
+		}
+	case "sub", "reply":
+		sub, err := c.Subscribe(topic, 256)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for {
+			select {
+			case m, ok := <-sub.C:
+				if !ok {
+					log.Fatal("connection lost")
+				}
+				fmt.Printf("%s: %s\n", m.Topic, m.Data)
+				if args[0] == "reply" && m.Reply != "" {
+					if err := c.Respond(m, []byte(message)); err != nil {
+						log.Fatal(err)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	case "req":
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		m, err := c.Request(ctx, topic, []byte(message))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s\n", m.Data)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/diag"
+)
+
+// Goroutine/Nos.Of.CPUs.go printed the CPUs, goroutines, OS and arch once;
+// this prints them and keeps serving them, with the rest of the runtime, on
+// -addr. -load starts some goroutines to look at.
+//
+//	go run ./cmd/runtime-dash -load
+//	curl localhost:6061/api/stats
+//	curl 'localhost:6061/api/goroutines?format=text'
+//	go tool pprof 'localhost:6061/profile/cpu?seconds=10'
+func main() {
+	addr := flag.String("addr", "localhost:6061", "listen address; the pages show stack traces, keep it private")
+	load := flag.Bool("load", false, "run demo goroutines that block, sleep and allocate")
+	flag.Parse()
+
+	fmt.Println("main execution started")
+	s := diag.Collect()
+	fmt.Println("No. of CPUs", s.NumCPU)
+	fmt.Println("No. of Goroutines:", s.Goroutines)
+	fmt.Println("OS:", s.GOOS)
+	fmt.Println("Arch:", s.GOARCH)
+	fmt.Println("GOMAXPROCS:", s.GOMAXPROCS)
+	if q := s.CPUQuota; q != nil {
+		fmt.Printf("CPU quota: %.2f (cgroup v%d)\n", q.CPUs, q.Version)
+	}
+	for _, w := range s.Warnings {
+		fmt.Println("warning:", w)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *load {
+		demo(ctx)
+	}
+	srv := &http.Server{Addr: *addr, Handler: diag.NewHandler(diag.Options{})}
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	log.Println("dashboard on http://" + *addr + "/")
+	<-ctx.Done()
+	srv.Shutdown(context.Background())
+}
+
+// demo starts goroutines that show up as separate groups: waiting on a
+// channel, fighting over a mutex, and allocating garbage
+func demo(ctx context.Context) {
+	for range 20 {
+		go func() { <-ctx.Done() }()
+	}
+	var mu sync.Mutex
+	for range 4 {
+		go func() {
+			for ctx.Err() == nil {
+				mu.Lock()
+				time.Sleep(time.Millisecond)
+				mu.Unlock()
+			}
+		}()
+	}
+	go func() {
+		var keep [][]byte
+		for ctx.Err() == nil {
+			keep = append(keep, make([]byte, 64<<10))
+			if len(keep) > 256 {
+				keep = keep[:0]
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+}
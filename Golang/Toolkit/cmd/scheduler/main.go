@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/scheduler"
+)
+
+// Concurrency/goroutines.go's sayHello and sayHi and sync.WaitGroup.go's f1
+// and f2 as scheduled jobs instead of goroutines held together with
+// time.Sleep. The history is served on -addr.
+//
+//	go run ./cmd/scheduler -addr :8083 -state scheduler-state.json
+//	curl localhost:8083/jobs
+//	curl localhost:8083/jobs/f1/runs
+//	curl -X POST localhost:8083/jobs/sayHi/run
+func main() {
+	addr := flag.String("addr", ":8083", "listen address for the history API")
+	state := flag.String("state", "", "file remembering the last runs across restarts")
+	workers := flag.Int("workers", 2, "runs in progress at once")
+	flag.Parse()
+
+	s, err := scheduler.New(scheduler.Config{
+		MaxConcurrent: *workers,
+		StateFile:     *state,
+		OnRun: func(r scheduler.Run) {
+			log.Printf("%s %s (%s, late %s) %s", r.Job, r.Status, r.Duration.Round(time.Millisecond), r.Late().Round(time.Millisecond), r.Error)
+		},
+		OnError: func(job string, err error) { log.Printf("%s: %v", job, err) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	jobs := []scheduler.Job{
+		{Name: "sayHello", Schedule: scheduler.Every(10 * time.Second), Jitter: time.Second, Func: say("Say Hello Function")},
+		{Name: "sayHi", Schedule: scheduler.MustParse("* * * * *"), Func: say("Say Hi 3")},
+		// f1 takes three seconds but is due every two: every other run is skipped
+		{Name: "f1", Schedule: scheduler.Every(2 * time.Second), Timeout: 5 * time.Second, Func: f1},
+		{Name: "f2", Schedule: scheduler.MustParse("*/5 * * * *"), CatchUp: scheduler.RunOnce, Func: f2},
+	}
+	for _, j := range jobs {
+		if err := s.Add(j); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: *addr, Handler: s.Handler()}
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	log.Println("scheduler running, history on", *addr)
+	s.Run(ctx)
+	srv.Shutdown(context.Background())
+}
+
+func say(msg string) func(context.Context) error {
+	return func(context.Context) error {
+		log.Println(msg)
+		return nil
+	}
+}
+
+func f1(ctx context.Context) error {
+	for i := 0; i < 3; i++ {
+		log.Println("f1, i=", i)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func f2(context.Context) error {
+	for i := 5; i < 8; i++ {
+		log.Println("f2(), i=", i)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/taskqueue"
+)
+
+// Channel/Bufferred.Channel.go's emailSender on a durable queue: the emails
+// are enqueued under -dir and a worker takes a second for each. Interrupt it
+// halfway and run it again without -enqueue; it picks up where it stopped.
+// Addresses ending in "@invalid" fail until they land in the dead letter
+// queue.
+//
+//	go run ./cmd/task-queue -enqueue 5
+//	go run ./cmd/task-queue
+//	go run ./cmd/task-queue -dead
+func main() {
+	dir := flag.String("dir", "task-queue", "queue directory")
+	enqueue := flag.Int("enqueue", 0, "add this many emails first")
+	dead := flag.Bool("dead", false, "list the dead letter queue and exit")
+	flag.Parse()
+
+	q, err := taskqueue.Open(taskqueue.Options{
+		Dir:               *dir,
+		VisibilityTimeout: 10 * time.Second,
+		MaxAttempts:       3,
+		RetryDelay:        func(n int) time.Duration { return time.Duration(n) * time.Second },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer q.Close()
+
+	if *dead {
+		for _, t := range q.DeadLetters() {
+			fmt.Printf("%s\t%s\t%d attempts\t%s\n", t.ID, t.Payload, t.Attempts, t.LastError)
+		}
+		return
+	}
+	for i := 0; i < *enqueue; i++ {
+		to := fmt.Sprintf("%d@gmail.com", i)
+		if i == *enqueue-1 {
+			to = fmt.Sprintf("%d@invalid", i)
+		}
+		if _, err := q.Enqueue([]byte(to)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("%+v", q.Stats())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		l, err := q.Lease(ctx)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		to := string(l.Task.Payload)
+		log.Println("sending email to", to)
+		time.Sleep(time.Second)
+		if strings.HasSuffix(to, "@invalid") {
+			err = l.Nack(errors.New("no such domain"))
+		} else {
+			err = l.Ack()
+		}
+		if err != nil {
+			log.Println(err)
+		}
+		log.Printf("%+v", q.Stats())
+	}
+}
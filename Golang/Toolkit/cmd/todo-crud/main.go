@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/jsonplaceholder"
+	"github.com/Shehbab-Kakkar/toolkit/resilient"
+)
+
+// Same flow as API/AP-CRUD.Operating.go, using the typed client
+func main() {
+	baseURL := flag.String("base-url", jsonplaceholder.DefaultBaseURL, "API base URL, e.g. the fake-placeholder server")
+	flag.Parse()
+
+	fmt.Println("Learning CRUD")
+	ctx := context.Background()
+	httpClient := resilient.NewClient(resilient.DefaultPolicy, resilient.Hooks{
+		OnRetry: func(req *http.Request, attempt int, wait time.Duration, res *http.Response, err error) {
+			fmt.Fprintf(os.Stderr, "retrying %s %s after attempt %d in %s\n", req.Method, req.URL, attempt, wait)
+		},
+		OnBreakerState: func(from, to resilient.State) {
+			fmt.Fprintln(os.Stderr, "circuit breaker", from, "->", to)
+		},
+	})
+	todos := jsonplaceholder.NewClient(*baseURL, httpClient).Todos()
+
+	todo, err := todos.Get(ctx, 1)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error Getting:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Todo: ", todo)
+
+	created, err := todos.Create(ctx, jsonplaceholder.Todo{UserID: 23, Title: "Prince Kumar", Completed: true})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error Creating:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Created: ", created)
+
+	if err := todos.Delete(ctx, 1); err != nil {
+		fmt.Fprintln(os.Stderr, "Error Deleting:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Deleted todo 1")
+}
+
+/*
+Learning CRUD
+Todo:  {1 1 delectus aut autem false}
+Created:  {23 201 Prince Kumar true}
+Deleted todo 1
+*/
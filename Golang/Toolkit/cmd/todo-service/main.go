@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/Shehbab-Kakkar/toolkit/todo"
+)
+
+// Runs the Todo REST service. Without -db the data lives in memory only.
+//
+//	go run ./cmd/todo-service -addr :8080 -db todos.db
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dbPath := flag.String("db", "", "SQLite database file, empty for in-memory")
+	flag.Parse()
+
+	var repo todo.Repository = todo.NewMemoryRepository()
+	if *dbPath != "" {
+		// OpenSQLite runs the schema migrations before we start serving
+		sqlite, err := todo.OpenSQLite(context.Background(), *dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sqlite.Close()
+		repo = sqlite
+	}
+
+	log.Println("Todo service listening on", *addr)
+	log.Fatal(http.ListenAndServe(*addr, todo.NewHandler(repo)))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/monitor"
+	"github.com/Shehbab-Kakkar/toolkit/monitor/alert"
+)
+
+// Watches the URLs from Goroutine/URL_TO_FILES.GoRoutineWait.go, or the targets
+// in -config, and serves the status page on -addr. Alerts go to any of
+// -mail-to, -webhook and -slack; -escalate-to gets outages that last.
+//
+//	go run ./cmd/uptime-monitor -config cmd/uptime-monitor/targets.json -addr :8081
+//	go run ./cmd/uptime-monitor -slack https://hooks.slack.com/services/... -quiet 22:00-07:00
+func main() {
+	configPath := flag.String("config", "", "JSON config file, see targets.json")
+	addr := flag.String("addr", ":8081", "status page listen address")
+	smtpAddr := flag.String("smtp", "localhost:25", "SMTP server for -mail-to")
+	mailFrom := flag.String("mail-from", "monitor@localhost", "sender address")
+	mailTo := flag.String("mail-to", "", "comma separated alert recipients")
+	webhook := flag.String("webhook", "", "URL that receives alerts as JSON")
+	slack := flag.String("slack", "", "Slack incoming webhook URL")
+	escalateTo := flag.String("escalate-to", "", "comma separated recipients for escalations")
+	escalateAfter := flag.Int("escalate-after", 10, "consecutive failures before escalating")
+	repeat := flag.Duration("repeat", 0, "re-send down alerts this often while the outage lasts")
+	quiet := flag.String("quiet", "", "hold down and recovery alerts in this local window, e.g. 22:00-07:00")
+	flag.Parse()
+
+	cfg := monitor.DefaultConfig
+	cfg.Interval = monitor.Duration(30 * time.Second)
+	for _, u := range []string{"https://www.golang.org", "https://www.google1.com", "https://www.medium.com"} {
+		cfg.Targets = append(cfg.Targets, monitor.Target{URL: u})
+	}
+	if *configPath != "" {
+		var err error
+		if cfg, err = monitor.LoadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	policy := alert.Policy{EscalateAfter: *escalateAfter, Repeat: *repeat}
+	if *mailTo != "" {
+		policy.Notifiers = append(policy.Notifiers, alert.Email{Addr: *smtpAddr, From: *mailFrom, To: strings.Split(*mailTo, ",")})
+	}
+	if *webhook != "" {
+		policy.Notifiers = append(policy.Notifiers, alert.Webhook{URL: *webhook})
+	}
+	if *slack != "" {
+		policy.Notifiers = append(policy.Notifiers, alert.Slack{WebhookURL: *slack})
+	}
+	if *escalateTo != "" {
+		policy.Escalation = append(policy.Escalation, alert.Email{Addr: *smtpAddr, From: *mailFrom, To: strings.Split(*escalateTo, ",")})
+	}
+	if *quiet != "" {
+		q, err := alert.ParseQuietHours(*quiet, time.Local)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy.Quiet = q
+	}
+	alerts := alert.NewManager(policy)
+	alerts.OnError = func(a alert.Alert, err error) {
+		log.Printf("could not deliver %q: %v", a.Subject(), err)
+	}
+
+	m, err := monitor.New(cfg, alerts.Hook(), monitor.OnTransition(func(t monitor.Transition) {
+		if t.To == monitor.Down {
+			log.Printf("%s is DOWN! %v", t.Target.Name, t.Result.Failures)
+		} else {
+			log.Printf("%s is %s", t.Target.Name, t.To)
+		}
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go m.Run(ctx)
+
+	srv := &http.Server{Addr: *addr, Handler: m.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	log.Println("Status page on", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
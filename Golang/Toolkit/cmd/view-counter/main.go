@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/views"
+)
+
+// Serves view counts for many posts, Mutex/With-Mutex.go's post.inc behind
+// HTTP. Counts survive restarts; a final snapshot is taken on interrupt.
+//
+//	go run ./cmd/view-counter -addr :8082 -dir views-data
+//	curl -X POST -H 'Idempotency-Key: 42' -H 'X-Visitor-ID: alice' localhost:8082/posts/hello/views
+//	curl localhost:8082/posts/hello/stats
+func main() {
+	addr := flag.String("addr", ":8082", "listen address")
+	dir := flag.String("dir", "views-data", "directory for the snapshot and log")
+	snapshotEvery := flag.Duration("snapshot", time.Minute, "how often to snapshot")
+	flag.Parse()
+
+	store, err := views.Open(views.Options{Dir: *dir, SnapshotInterval: *snapshotEvery})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: *addr, Handler: store.Handler()}
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	log.Println("View counter listening on", *addr)
+	<-ctx.Done()
+	// ListenAndServe returns as soon as Shutdown starts; the store has to
+	// outlive the handlers Shutdown is still waiting for
+	if err := srv.Shutdown(context.Background()); err != nil {
+		log.Println(err)
+	}
+	if err := store.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
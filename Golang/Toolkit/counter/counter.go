@@ -0,0 +1,172 @@
+// Package counter puts the counters from Golang/Mutex behind one interface so
+// they can be swapped and measured against each other. Without-Mutex.go's
+// plain field is deliberately missing: it loses updates as soon as two
+// goroutines share it.
+package counter
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is safe for concurrent use
+type Counter interface {
+	Add(n int64)
+	Load() int64
+}
+
+// Inc adds one, post.inc from With-Mutex.go
+func Inc(c Counter) { c.Add(1) }
+
+// Mutex is the With-Mutex.go counter
+type Mutex struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *Mutex) Add(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += n
+}
+
+func (c *Mutex) Load() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// RWMutex lets reads run together; it only pays off when reads dominate
+type RWMutex struct {
+	mu sync.RWMutex
+	n  int64
+}
+
+func (c *RWMutex) Add(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += n
+}
+
+func (c *RWMutex) Load() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.n
+}
+
+// Atomic is a single sync/atomic word
+type Atomic struct {
+	n atomic.Int64
+}
+
+func (c *Atomic) Add(n int64) { c.n.Add(n) }
+func (c *Atomic) Load() int64 { return c.n.Load() }
+
+// Channel keeps the count in one goroutine that owns it; everyone else sends
+// it messages. Call Close to stop that goroutine.
+type Channel struct {
+	add  chan int64
+	load chan chan int64
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewChannel starts the owner goroutine
+func NewChannel() *Channel {
+	c := &Channel{add: make(chan int64), load: make(chan chan int64), quit: make(chan struct{})}
+	go c.own()
+	return c
+}
+
+func (c *Channel) own() {
+	var n int64
+	for {
+		select {
+		case d := <-c.add:
+			n += d
+		case reply := <-c.load:
+			reply <- n
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Add blocks until the owner has taken the update. It panics after Close.
+func (c *Channel) Add(n int64) {
+	select {
+	case c.add <- n:
+	case <-c.quit:
+		panic("counter: Add on closed Channel")
+	}
+}
+
+func (c *Channel) Load() int64 {
+	reply := make(chan int64, 1)
+	select {
+	case c.load <- reply:
+		return <-reply
+	case <-c.quit:
+		panic("counter: Load on closed Channel")
+	}
+}
+
+// Close stops the owner goroutine
+func (c *Channel) Close() { c.once.Do(func() { close(c.quit) }) }
+
+// cacheLine keeps stripes from sharing a line, which would bring back the
+// contention striping is meant to remove
+const cacheLine = 64
+
+type stripe struct {
+	n atomic.Int64
+	_ [cacheLine - 8]byte
+}
+
+// Sharded spreads adds over stripes and sums them on Load, so writers rarely
+// touch the same memory. Load is O(stripes) and not a snapshot: adds that race
+// with it may or may not be included.
+type Sharded struct {
+	stripes []stripe
+}
+
+// NewSharded makes a counter with n stripes, or one per CPU when n <= 0
+func NewSharded(n int) *Sharded {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return &Sharded{stripes: make([]stripe, n)}
+}
+
+func (c *Sharded) Add(n int64) {
+	// goroutines have no stable id to hash, and the runtime's per-P random
+	// source is cheap enough to pick a stripe with
+	c.stripes[rand.Uint32N(uint32(len(c.stripes)))].n.Add(n)
+}
+
+func (c *Sharded) Load() int64 {
+	var sum int64
+	for i := range c.stripes {
+		sum += c.stripes[i].n.Load()
+	}
+	return sum
+}
+
+// Backend names a Counter constructor, for tests, benchmarks and flags
+type Backend struct {
+	Name string
+	New  func() Counter
+}
+
+// Backends lists every implementation
+func Backends() []Backend {
+	return []Backend{
+		{"mutex", func() Counter { return new(Mutex) }},
+		{"rwmutex", func() Counter { return new(RWMutex) }},
+		{"atomic", func() Counter { return new(Atomic) }},
+		{"channel", func() Counter { return NewChannel() }},
+		{"sharded", func() Counter { return NewSharded(0) }},
+	}
+}
@@ -0,0 +1,136 @@
+package counter
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func closeCounter(c Counter) {
+	if cl, ok := c.(interface{ Close() }); ok {
+		cl.Close()
+	}
+}
+
+// Run with -race: every backend, at several goroutine counts, with readers
+// running alongside the writers, must end on the exact total
+func TestRaceMatrix(t *testing.T) {
+	const perGoroutine = 500
+	for _, b := range Backends() {
+		for _, goroutines := range []int{1, 8, 64} {
+			for _, readers := range []int{0, 4} {
+				t.Run(fmt.Sprintf("%s/g=%d/readers=%d", b.Name, goroutines, readers), func(t *testing.T) {
+					t.Parallel()
+					c := b.New()
+					defer closeCounter(c)
+
+					stop := make(chan struct{})
+					var rwg sync.WaitGroup
+					for range readers {
+						rwg.Add(1)
+						go func() {
+							defer rwg.Done()
+							var last int64
+							for {
+								select {
+								case <-stop:
+									return
+								default:
+								}
+								// adds only ever grow the count
+								n := c.Load()
+								if n < last {
+									t.Errorf("Load went backwards: %d after %d", n, last)
+									return
+								}
+								last = n
+								// spinning readers would starve lock-based writers on one CPU
+								runtime.Gosched()
+							}
+						}()
+					}
+
+					var wg sync.WaitGroup
+					for i := range goroutines {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							for j := range perGoroutine {
+								if (i+j)%2 == 0 {
+									Inc(c)
+								} else {
+									c.Add(2)
+								}
+							}
+						}()
+					}
+					wg.Wait()
+					close(stop)
+					rwg.Wait()
+
+					want := int64(goroutines * perGoroutine * 3 / 2)
+					if got := c.Load(); got != want {
+						t.Fatalf("got %d, want %d", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestChannelClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := NewChannel()
+	Inc(c)
+	c.Close()
+	c.Close()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Add after Close should panic")
+		}
+		// the owner goroutine is gone
+		for range 100 {
+			if runtime.NumGoroutine() <= before {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Errorf("%d goroutines, started with %d", runtime.NumGoroutine(), before)
+	}()
+	c.Add(1)
+}
+
+// go test -run - -bench . ./counter
+//
+// Each backend at several GOMAXPROCS values, all writes and then 90% reads
+func BenchmarkContention(b *testing.B) {
+	procs := []int{1, 2, 4, 8}
+	if n := runtime.NumCPU(); n > 8 {
+		procs = append(procs, n)
+	}
+	for _, readPercent := range []int{0, 90} {
+		for _, be := range Backends() {
+			for _, p := range procs {
+				b.Run(fmt.Sprintf("reads=%d%%/%s/procs=%d", readPercent, be.Name, p), func(b *testing.B) {
+					defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(p))
+					c := be.New()
+					defer closeCounter(c)
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						i := 0
+						for pb.Next() {
+							if i%100 < readPercent {
+								c.Load()
+							} else {
+								c.Add(1)
+							}
+							i++
+						}
+					})
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Package crawler follows links from seed URLs within a set of domains and
+// saves every page it fetches, using the downloader's bounded worker pool one
+// depth level at a time.
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/downloader"
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+// DefaultUserAgent identifies the crawler to robots.txt and servers
+const DefaultUserAgent = "toolkit-crawler/1.0"
+
+// ManifestName is written to the output directory after a crawl
+const ManifestName = "manifest.json"
+
+// maxPageBytes bounds how much of one page is kept for link extraction and saving
+const maxPageBytes = 10 << 20
+
+// Page is one fetched URL in the Manifest
+type Page struct {
+	URL         string `json:"url"`
+	Depth       int    `json:"depth"`
+	Parent      string `json:"parent,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	Path        string `json:"path,omitempty"` // relative to the output directory
+	Links       int    `json:"links"`
+	// Truncated means the page was longer than maxPageBytes; only the first
+	// maxPageBytes were saved and searched for links
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Manifest describes a crawl
+type Manifest struct {
+	Seeds    []string       `json:"seeds"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Pages    []Page         `json:"pages"`
+	Skipped  map[string]int `json:"skipped,omitempty"` // reason -> URLs not fetched
+}
+
+// Crawler holds the crawl limits
+type Crawler struct {
+	client     *http.Client
+	workers    int
+	timeout    time.Duration
+	maxDepth   int
+	maxPages   int
+	delay      time.Duration
+	allowed    []string
+	userAgent  string
+	outDir     string
+	obeyRobots bool
+}
+
+// Option configures a Crawler
+type Option func(*Crawler)
+
+// WithClient sets the client whose transport does the requests. Its
+// CheckRedirect, if any, runs before the crawler's own scope and robots checks.
+func WithClient(c *http.Client) Option { return func(cr *Crawler) { cr.client = c } }
+
+// WithWorkers bounds how many pages are fetched at once
+func WithWorkers(n int) Option { return func(c *Crawler) { c.workers = n } }
+
+// WithTimeout bounds each page fetch, including the wait for the host's politeness delay
+func WithTimeout(t time.Duration) Option { return func(c *Crawler) { c.timeout = t } }
+
+// WithMaxDepth is how many links away from a seed to go; 0 fetches only the seeds
+func WithMaxDepth(n int) Option { return func(c *Crawler) { c.maxDepth = n } }
+
+// WithMaxPages stops the crawl after n fetches
+func WithMaxPages(n int) Option { return func(c *Crawler) { c.maxPages = n } }
+
+// WithDelay is the minimum gap between requests to one host. A longer
+// Crawl-delay in robots.txt wins.
+func WithDelay(d time.Duration) Option { return func(c *Crawler) { c.delay = d } }
+
+// WithAllowedDomains limits the crawl to these domains and their subdomains.
+// By default the seeds' hosts are allowed.
+func WithAllowedDomains(domains ...string) Option {
+	return func(c *Crawler) { c.allowed = append(c.allowed, domains...) }
+}
+
+// WithUserAgent sets the User-Agent header and the robots.txt agent
+func WithUserAgent(ua string) Option { return func(c *Crawler) { c.userAgent = ua } }
+
+// WithOutput saves pages and the manifest in dir; without it nothing is written
+func WithOutput(dir string) Option { return func(c *Crawler) { c.outDir = dir } }
+
+// IgnoreRobots skips robots.txt, for crawling your own sites
+func IgnoreRobots() Option { return func(c *Crawler) { c.obeyRobots = false } }
+
+// New returns a Crawler with 4 workers, depth 2, at most 100 pages and a one second politeness delay
+func New(opts ...Option) *Crawler {
+	c := &Crawler{
+		client:     http.DefaultClient,
+		workers:    4,
+		timeout:    30 * time.Second,
+		maxDepth:   2,
+		maxPages:   100,
+		delay:      time.Second,
+		userAgent:  DefaultUserAgent,
+		obeyRobots: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// crawl is the state of one Crawl call
+type crawl struct {
+	*Crawler
+	transport *politeTransport
+	client    *http.Client
+	robots    *robotsCache
+	saver     *downloader.Saver
+	allowed   []string
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	found    map[string][]string // page URL -> links on it
+	types    map[string]string   // page URL -> content type
+	cut      map[string]bool     // page URLs longer than maxPageBytes
+	manifest Manifest
+}
+
+// Crawl fetches the seeds and follows their links breadth first. It returns
+// the manifest even when ctx is cancelled part way.
+func (c *Crawler) Crawl(ctx context.Context, seeds ...string) (Manifest, error) {
+	base := c.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cr := &crawl{
+		Crawler: c,
+		transport: &politeTransport{
+			base:      base,
+			userAgent: c.userAgent,
+			delay:     c.delay,
+			next:      map[string]time.Time{},
+			delays:    map[string]time.Duration{},
+		},
+		seen:     map[string]bool{},
+		found:    map[string][]string{},
+		types:    map[string]string{},
+		cut:      map[string]bool{},
+		allowed:  c.allowed,
+		manifest: Manifest{Seeds: seeds, Started: time.Now(), Skipped: map[string]int{}},
+	}
+	cr.client = &http.Client{Transport: cr.transport, CheckRedirect: cr.checkRedirect, Jar: c.client.Jar}
+	cr.robots = &robotsCache{client: cr.client, userAgent: c.userAgent, hosts: map[string]*robotsEntry{}}
+	if c.outDir != "" {
+		cr.saver = &downloader.Saver{Dir: c.outDir, ExtensionFromContentType: true}
+	}
+
+	type item struct{ url, parent string }
+	var level []item
+	for _, s := range seeds {
+		n, err := Normalize(s)
+		if err != nil {
+			return cr.manifest, err
+		}
+		if len(c.allowed) == 0 {
+			u, _ := url.Parse(n)
+			cr.allowed = append(cr.allowed, u.Hostname())
+		}
+		if !cr.seen[n] {
+			cr.seen[n] = true
+			level = append(level, item{url: n})
+		}
+	}
+
+	d := downloader.New(
+		downloader.WithClient(cr.client),
+		downloader.WithWorkers(c.workers),
+		downloader.WithTimeout(c.timeout),
+		downloader.WithSink(cr.sink),
+	)
+
+	for depth := 0; len(level) > 0 && ctx.Err() == nil; depth++ {
+		// robots.txt and the page budget decide what this level really fetches
+		var urls []string
+		parents := map[string]string{}
+		for _, it := range level {
+			if !cr.admit(ctx, it.url) {
+				continue
+			}
+			if len(cr.manifest.Pages)+len(urls) >= c.maxPages {
+				cr.manifest.Skipped["max_pages"]++
+				continue
+			}
+			urls = append(urls, it.url)
+			parents[it.url] = it.parent
+		}
+
+		for r := range d.Stream(ctx, urls) {
+			p := Page{URL: r.URL, Depth: depth, Parent: parents[r.URL], StatusCode: r.StatusCode, Bytes: r.Bytes}
+			if r.Err != nil {
+				p.Error = r.Err.Error()
+			}
+			if r.Path != "" {
+				p.Path, _ = filepath.Rel(c.outDir, r.Path)
+			}
+			cr.mu.Lock()
+			p.ContentType = cr.types[r.URL]
+			p.Links = len(cr.found[r.URL])
+			p.Truncated = cr.cut[r.URL]
+			cr.mu.Unlock()
+			cr.manifest.Pages = append(cr.manifest.Pages, p)
+		}
+
+		// the next level is every new, in-scope link found on this one
+		var next []item
+		for _, u := range urls {
+			for _, link := range cr.found[u] {
+				if cr.seen[link] {
+					continue
+				}
+				cr.seen[link] = true
+				switch {
+				case !cr.inScope(link):
+					cr.manifest.Skipped["domain"]++
+				case depth+1 > c.maxDepth:
+					cr.manifest.Skipped["max_depth"]++
+				default:
+					next = append(next, item{url: link, parent: u})
+				}
+			}
+		}
+		level = next
+	}
+
+	cr.manifest.Finished = time.Now()
+	// stable order makes manifests diffable between runs
+	sort.SliceStable(cr.manifest.Pages, func(i, j int) bool {
+		a, b := cr.manifest.Pages[i], cr.manifest.Pages[j]
+		return a.Depth < b.Depth || (a.Depth == b.Depth && a.URL < b.URL)
+	})
+	if c.outDir != "" {
+		if err := cr.writeManifest(); err != nil {
+			return cr.manifest, err
+		}
+	}
+	return cr.manifest, ctx.Err()
+}
+
+// admit checks robots.txt and applies the host's Crawl-delay
+func (cr *crawl) admit(ctx context.Context, rawURL string) bool {
+	if !cr.obeyRobots {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if !cr.robotsAllow(ctx, u) {
+		cr.manifest.Skipped["robots"]++
+		return false
+	}
+	return true
+}
+
+// robotsAllow checks u against its host's robots.txt and applies the
+// host's Crawl-delay
+func (cr *crawl) robotsAllow(ctx context.Context, u *url.URL) bool {
+	rb := cr.robots.get(ctx, u)
+	if rb.crawlDelay > 0 {
+		cr.transport.setDelay(u.Host, rb.crawlDelay)
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rb.allowed(path)
+}
+
+// checkRedirect holds redirects to the rules links follow: the target must
+// be in scope and allowed by its robots.txt. Fetches of robots.txt itself
+// follow redirects anywhere, as RFC 9309 asks.
+func (cr *crawl) checkRedirect(req *http.Request, via []*http.Request) error {
+	if cr.Crawler.client.CheckRedirect != nil {
+		if err := cr.Crawler.client.CheckRedirect(req, via); err != nil {
+			return err
+		}
+	} else if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if via[0].URL.Path == "/robots.txt" {
+		return nil
+	}
+	target := req.URL.String()
+	skip := ""
+	switch {
+	case !cr.inScope(target):
+		skip = "domain"
+	case cr.obeyRobots && !cr.robotsAllow(req.Context(), req.URL):
+		skip = "robots"
+	default:
+		return nil
+	}
+	cr.mu.Lock()
+	cr.manifest.Skipped[skip]++
+	cr.mu.Unlock()
+	return fmt.Errorf("redirect to %s: blocked by %s", target, skip)
+}
+
+// inScope reports whether the link's host is an allowed domain or below one
+func (cr *crawl) inScope(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	for _, d := range cr.allowed {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// sink keeps the body for link extraction and hands it to the Saver
+func (cr *crawl) sink(ctx context.Context, rawURL string, res *http.Response) (int64, string, error) {
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxPageBytes+1))
+	if err != nil {
+		return int64(len(body)), "", err
+	}
+	cut := len(body) > maxPageBytes
+	if cut {
+		body = body[:maxPageBytes]
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	var links []string
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/html" {
+		// links are relative to where redirects ended up
+		for _, l := range extractLinks(res.Request.URL, body) {
+			if n, err := Normalize(l); err == nil {
+				links = append(links, n)
+			}
+		}
+	}
+	cr.mu.Lock()
+	cr.found[rawURL] = links
+	cr.types[rawURL] = contentType
+	cr.cut[rawURL] = cut
+	cr.mu.Unlock()
+
+	if cr.saver == nil {
+		return int64(len(body)), "", nil
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return cr.saver.Sink(ctx, rawURL, res)
+}
+
+func (cr *crawl) writeManifest() error {
+	return fsutil.WriteFileAtomic(filepath.Join(cr.outDir, ManifestName), 0o644, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cr.manifest)
+	})
+}
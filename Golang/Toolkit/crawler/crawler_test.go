@@ -0,0 +1,276 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// site serves a small link graph and records the request times
+type site struct {
+	mu       sync.Mutex
+	times    []time.Time
+	agents   []string
+	requests []string
+}
+
+func (s *site) handler(robots string) http.Handler {
+	pages := map[string]string{
+		"/":               `<a href="/a">a</a> <a href="b?y=2&x=1#top">b</a> <a href="/private/secret">p</a> <a href="https://other.example/">ext</a> <a href="mailto:x@y">m</a>`,
+		"/a":              `<a href="/a/c">c</a> <a href="/">home</a> <a href="/a#again">self</a> <a rel="nofollow" href="/nofollow">nf</a>`,
+		"/b":              `<base href="/a/"><a href="c">same c</a> <a href="/b?x=1&y=2">b again</a>`,
+		"/a/c":            `<a href="/a/c/d">d</a>`,
+		"/a/c/d":          `deep`,
+		"/private/secret": `secret`,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.times = append(s.times, time.Now())
+		s.agents = append(s.agents, r.UserAgent())
+		s.requests = append(s.requests, r.URL.RequestURI())
+		s.mu.Unlock()
+
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robots)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+	})
+}
+
+func TestCrawl(t *testing.T) {
+	s := &site{}
+	srv := httptest.NewServer(s.handler("User-agent: *\nDisallow: /private/\n"))
+	defer srv.Close()
+
+	out := t.TempDir()
+	m, err := New(
+		WithMaxDepth(2),
+		WithDelay(time.Millisecond),
+		WithOutput(out),
+		WithUserAgent("test-bot/1.0"),
+	).Crawl(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range m.Pages {
+		got = append(got, fmt.Sprintf("%d %s", p.Depth, strings.TrimPrefix(p.URL, srv.URL)))
+		if p.Error != "" || p.Path == "" {
+			t.Errorf("%s: %+v", p.URL, p)
+		}
+		if _, err := os.Stat(filepath.Join(out, p.Path)); err != nil {
+			t.Errorf("%s was not saved: %v", p.URL, err)
+		}
+	}
+	want := []string{"0 /", "1 /a", "1 /b?x=1&y=2", "2 /a/c"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("pages %v, want %v", got, want)
+	}
+	if m.Skipped["robots"] != 1 || m.Skipped["domain"] != 1 || m.Skipped["max_depth"] != 1 {
+		t.Errorf("skipped %v", m.Skipped)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.requests, "/private/secret") || slices.Contains(s.requests, "/nofollow") {
+		t.Errorf("fetched forbidden pages: %v", s.requests)
+	}
+	if n := len(slices.DeleteFunc(slices.Clone(s.requests), func(r string) bool { return r != "/robots.txt" })); n != 1 {
+		t.Errorf("robots.txt fetched %d times", n)
+	}
+	for _, ua := range s.agents {
+		if ua != "test-bot/1.0" {
+			t.Errorf("User-Agent %q", ua)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(out, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Manifest
+	if err := json.Unmarshal(b, &saved); err != nil || len(saved.Pages) != 4 || saved.Pages[1].Parent != srv.URL+"/" {
+		t.Fatalf("manifest %s (%v)", b, err)
+	}
+}
+
+func TestPolitenessAndLimits(t *testing.T) {
+	s := &site{}
+	srv := httptest.NewServer(s.handler("User-agent: test-bot\nCrawl-delay: 0.05\n\nUser-agent: *\nDisallow: /\n"))
+	defer srv.Close()
+
+	m, err := New(
+		WithWorkers(4),
+		WithMaxPages(3),
+		WithDelay(time.Millisecond),
+		WithUserAgent("test-bot/1.0"),
+	).Crawl(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Pages) != 3 || m.Skipped["max_pages"] == 0 {
+		t.Fatalf("expected the page budget to stop the crawl: %d pages, skipped %v", len(m.Pages), m.Skipped)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the first gap is robots.txt to the first page, before Crawl-delay is
+	// known; the rest are measured at the server, so allow some jitter
+	for i := 2; i < len(s.times); i++ {
+		if gap := s.times[i].Sub(s.times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d came %s after the previous one despite Crawl-delay", i, gap)
+		}
+	}
+}
+
+func TestRedirectsFollowScopeAndRobots(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+		case "/":
+			fmt.Fprint(w, `<a href="/to-private">p</a> <a href="/to-other">o</a> <a href="/to-open">a</a>`)
+		case "/to-private":
+			http.Redirect(w, r, "/private/secret", http.StatusFound)
+		case "/to-other":
+			http.Redirect(w, r, "https://other.example/", http.StatusFound)
+		case "/to-open":
+			http.Redirect(w, r, "/open", http.StatusFound)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	m, err := New(WithDelay(time.Millisecond)).Crawl(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if slices.Contains(requests, "/private/secret") || !slices.Contains(requests, "/open") {
+		t.Fatalf("requests %v", requests)
+	}
+	if m.Skipped["robots"] != 1 || m.Skipped["domain"] != 1 {
+		t.Fatalf("skipped %v", m.Skipped)
+	}
+	failed := 0
+	for _, p := range m.Pages {
+		if p.Error != "" {
+			failed++
+		}
+	}
+	if len(m.Pages) != 4 || failed != 2 {
+		t.Fatalf("%+v", m.Pages)
+	}
+}
+
+func TestRobotsNotCachedAfterCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+	}))
+	defer srv.Close()
+	cache := &robotsCache{client: srv.Client(), userAgent: "test", hosts: map[string]*robotsEntry{}}
+	u, _ := url.Parse(srv.URL + "/page")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := cache.get(cancelled, u); r.allowed("/page") {
+		t.Fatal("an unreachable robots.txt must keep the caller out")
+	}
+	if r := cache.get(context.Background(), u); !r.allowed("/page") || r.allowed("/private/x") {
+		t.Fatal("the cancelled fetch was cached")
+	}
+}
+
+func TestLongPageIsMarkedTruncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write(bytes.Repeat([]byte("x"), maxPageBytes+1))
+		}
+	}))
+	defer srv.Close()
+
+	m, err := New(WithDelay(time.Millisecond), WithOutput(t.TempDir())).Crawl(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Pages) != 1 || !m.Pages[0].Truncated || m.Pages[0].Bytes != maxPageBytes {
+		t.Fatalf("%+v", m.Pages)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"HTTP://Example.COM":               "http://example.com/",
+		"http://example.com:80/a/./b/../c": "http://example.com/a/c",
+		"https://example.com:443/?b=2&a=1": "https://example.com/?a=1&b=2",
+		"https://user@example.com/x#frag":  "https://example.com/x",
+		"http://[::1]:80/":                 "http://[::1]/",
+	} {
+		got, err := Normalize(in)
+		if err != nil || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestRobotsRules(t *testing.T) {
+	r := parseRobots(strings.NewReader(`
+# comment
+User-agent: otherbot
+Disallow: /
+
+User-agent: test-bot
+User-agent: another
+Disallow: /private
+Allow: /private/open
+Disallow: /*.pdf$
+Disallow: /tmp/*/cache
+
+User-agent: *
+Disallow: /
+`), "Test-Bot/1.0")
+
+	for path, want := range map[string]bool{
+		"/":                  true,
+		"/private":           false,
+		"/private/open/x":    true,
+		"/docs/file.pdf":     false,
+		"/docs/file.pdf?x=1": true,
+		"/tmp/a/b/cache/x":   false,
+		"/tmp/cache":         true,
+	} {
+		if got := r.allowed(path); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	if !parseRobots(strings.NewReader(""), "bot").allowed("/anything") {
+		t.Error("an empty robots.txt allows everything")
+	}
+}
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttrs are the attributes that point at other pages. Images, scripts and
+// stylesheets are left out; the crawler saves pages, not assets.
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"iframe": "src",
+	"frame":  "src",
+}
+
+// extractLinks returns the absolute URLs of every link in an HTML document,
+// resolved against base or the document's own <base href>.
+func extractLinks(base *url.URL, body []byte) []string {
+	var links []string
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "base" {
+				if href := attr(tok, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+				continue
+			}
+			name, ok := linkAttrs[tok.Data]
+			if !ok || strings.Contains(attr(tok, "rel"), "nofollow") {
+				continue
+			}
+			ref := strings.TrimSpace(attr(tok, name))
+			if ref == "" || strings.HasPrefix(ref, "#") {
+				continue
+			}
+			u, err := base.Parse(ref)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			links = append(links, u.String())
+		}
+	}
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// Normalize returns the form of rawURL used for deduplication: lowercase scheme
+// and host, no default port, no fragment, "/" for an empty path, dot segments
+// resolved and query parameters sorted.
+func Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.User = nil
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	// resolving against itself cleans "/a/./b/../c"
+	u = u.ResolveReference(&url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery})
+	if u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+	return u.String(), nil
+}
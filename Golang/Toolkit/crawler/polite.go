@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// politeTransport spaces requests to the same host at least delay apart and
+// sets the User-Agent. Different hosts are not slowed by each other.
+type politeTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	delay     time.Duration
+
+	mu     sync.Mutex
+	next   map[string]time.Time     // earliest start of the next request per host
+	delays map[string]time.Duration // per host overrides from Crawl-delay
+}
+
+func (t *politeTransport) setDelay(host string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delays[host] = d
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	// the next slot is counted from when a request really goes out, not
+	// from when it was due; a late timer must not shrink the gap
+	for {
+		t.mu.Lock()
+		delay := t.delay
+		if d, ok := t.delays[host]; ok && d > delay {
+			delay = d
+		}
+		now := time.Now()
+		next := t.next[host]
+		if !next.After(now) {
+			t.next[host] = now.Add(delay)
+			t.mu.Unlock()
+			break
+		}
+		t.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robots is the subset of robots.txt (RFC 9309) that applies to our user agent
+type robots struct {
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// allowAll is used when robots.txt is missing; disallowAll when it could not be fetched
+var (
+	allowAll    = &robots{}
+	disallowAll = &robots{rules: []rule{{allow: false, pattern: "/"}}}
+)
+
+// parseRobots keeps the group for the most specific user agent that matches
+// ours, falling back to "*". Unknown lines are ignored.
+func parseRobots(r io.Reader, userAgent string) *robots {
+	userAgent = strings.ToLower(userAgent)
+	type group struct {
+		agents []string
+		robots robots
+	}
+	var groups []*group
+	var cur *group
+	lastWasAgent := false
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		switch key {
+		case "user-agent":
+			if cur == nil || !lastWasAgent {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// an empty Disallow allows everything
+			if cur != nil && val != "" {
+				cur.robots.rules = append(cur.robots.rules, rule{allow: key == "allow", pattern: val})
+			}
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(val, 64); err == nil && cur != nil {
+				cur.robots.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+		lastWasAgent = false
+	}
+
+	var best *group
+	bestLen := -1
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" && bestLen < 0 {
+				best, bestLen = g, 0
+			} else if a != "*" && strings.Contains(userAgent, a) && len(a) > bestLen {
+				best, bestLen = g, len(a)
+			}
+		}
+	}
+	if best == nil {
+		return allowAll
+	}
+	return &best.robots
+}
+
+// allowed applies the longest matching rule; Allow wins ties
+func (r *robots) allowed(path string) bool {
+	allow, longest := true, -1
+	for _, ru := range r.rules {
+		if !matchRobots(ru.pattern, path) {
+			continue
+		}
+		if n := len(ru.pattern); n > longest || (n == longest && ru.allow) {
+			allow, longest = ru.allow, n
+		}
+	}
+	return allow
+}
+
+// matchRobots matches a robots.txt path pattern with * wildcards and a $ end anchor
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, p := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, p)
+		}
+		idx := strings.Index(rest, p)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(p):]
+	}
+	return !anchored || rest == ""
+}
+
+// robotsCache fetches robots.txt once per scheme and host
+type robotsCache struct {
+	client    *http.Client
+	userAgent string
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	mu sync.Mutex // one fetch per host at a time
+	r  *robots    // nil until a fetch finished with its ctx still live
+}
+
+func (c *robotsCache) get(ctx context.Context, u *url.URL) *robots {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	e, ok := c.hosts[key]
+	if !ok {
+		e = &robotsEntry{}
+		c.hosts[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.r != nil {
+		return e.r
+	}
+	r := c.fetch(ctx, key+"/robots.txt")
+	// a fetch cut short by its caller's ctx says nothing about the host;
+	// the next caller tries again rather than being kept out for good
+	if ctx.Err() == nil {
+		e.r = r
+	}
+	return r
+}
+
+// fetch treats a 4xx as "no rules" and anything unreachable as "keep out"
+func (c *robotsCache) fetch(ctx context.Context, robotsURL string) *robots {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return disallowAll
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return disallowAll
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		return parseRobots(io.LimitReader(res.Body, 512<<10), c.userAgent)
+	case res.StatusCode >= 400 && res.StatusCode <= 499:
+		return allowAll
+	default:
+		return disallowAll
+	}
+}
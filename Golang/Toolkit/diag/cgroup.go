@@ -0,0 +1,132 @@
+package diag
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// CPUQuota is a cgroup CPU limit
+type CPUQuota struct {
+	Version  int     `json:"cgroup_version"`
+	Cgroup   string  `json:"cgroup"`   // the group whose limit applies
+	QuotaUS  int64   `json:"quota_us"` // CPU time allowed per period
+	PeriodUS int64   `json:"period_us"`
+	CPUs     float64 `json:"cpus"` // QuotaUS / PeriodUS
+}
+
+// DetectCPUQuota reads this process's cgroup CPU limit. It returns nil
+// without an error when there is no limit or no cgroup filesystem, as on
+// anything but Linux.
+func DetectCPUQuota() (*CPUQuota, error) {
+	return detectCPUQuota(os.DirFS("/"))
+}
+
+// detectCPUQuota works on fsys rooted at "/" so tests can fake the files
+func detectCPUQuota(fsys fs.FS) (*CPUQuota, error) {
+	f, err := fsys.Open("proc/self/cgroup")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// lines are "id:controllers:path"; v2 has a single "0::path"
+	var v1, v2 string
+	hasV2 := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			v2, hasV2 = parts[2], true
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == "cpu" {
+				v1 = parts[2]
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if v1 != "" {
+		return quotaV1(fsys, v1)
+	}
+	if hasV2 {
+		return quotaV2(fsys, v2)
+	}
+	return nil, nil
+}
+
+// quotaV2 reads cpu.max from the process's group up to the root; the
+// tightest limit on the way wins
+func quotaV2(fsys fs.FS, group string) (*CPUQuota, error) {
+	var best *CPUQuota
+	for dir := path.Clean("/" + group); ; dir = path.Dir(dir) {
+		b, err := fs.ReadFile(fsys, path.Join("sys/fs/cgroup", dir, "cpu.max"))
+		if err == nil {
+			// "max 100000" or "<quota> <period>"
+			fields := strings.Fields(string(b))
+			if len(fields) == 2 && fields[0] != "max" {
+				q, err1 := strconv.ParseInt(fields[0], 10, 64)
+				p, err2 := strconv.ParseInt(fields[1], 10, 64)
+				if err1 == nil && err2 == nil && q > 0 && p > 0 {
+					c := &CPUQuota{Version: 2, Cgroup: dir, QuotaUS: q, PeriodUS: p, CPUs: float64(q) / float64(p)}
+					if best == nil || c.CPUs < best.CPUs {
+						best = c
+					}
+				}
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if dir == "/" {
+			return best, nil
+		}
+	}
+}
+
+// quotaV1 reads cpu.cfs_quota_us from the cpu controller's usual mount
+// points. Inside a container the group path is "/" or a host path that
+// isn't mounted, so the root of the hierarchy is tried too.
+func quotaV1(fsys fs.FS, group string) (*CPUQuota, error) {
+	for _, mount := range []string{"sys/fs/cgroup/cpu,cpuacct", "sys/fs/cgroup/cpu"} {
+		for _, dir := range []string{path.Clean("/" + group), "/"} {
+			base := path.Join(mount, dir)
+			quota, err := readInt(fsys, path.Join(base, "cpu.cfs_quota_us"))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			period, err := readInt(fsys, path.Join(base, "cpu.cfs_period_us"))
+			if err != nil {
+				return nil, err
+			}
+			if quota <= 0 || period <= 0 {
+				// -1 is unlimited
+				return nil, nil
+			}
+			return &CPUQuota{Version: 1, Cgroup: dir, QuotaUS: quota, PeriodUS: period, CPUs: float64(quota) / float64(period)}, nil
+		}
+	}
+	return nil, nil
+}
+
+func readInt(fsys fs.FS, name string) (int64, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
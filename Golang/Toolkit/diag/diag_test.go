@@ -0,0 +1,226 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	s := Collect()
+	if s.Goroutines < 1 || s.NumCPU < 1 || s.GOMAXPROCS != runtime.GOMAXPROCS(0) {
+		t.Fatalf("%+v", s)
+	}
+	if s.Heap.Objects == 0 || s.Heap.Total < s.Heap.Objects || s.GC.GOGC != 100 {
+		t.Fatalf("heap %+v gc %+v", s.Heap, s.GC)
+	}
+	found := false
+	for _, m := range s.Metrics {
+		if m.Name == "/gc/pauses:seconds" || m.Name == "/sched/pauses/total/gc:seconds" {
+			found = found || m.Histogram
+		}
+	}
+	if !found {
+		t.Fatal("no GC pause histogram")
+	}
+}
+
+func TestCPUQuota(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		cpus  float64 // 0 for no quota
+	}{
+		{"v2", map[string]string{
+			"proc/self/cgroup":                 "0::/app\n",
+			"sys/fs/cgroup/app/cpu.max":        "200000 100000\n",
+			"sys/fs/cgroup/cpu.max":            "max 100000\n",
+			"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+		}, 2},
+		{"v2 unlimited", map[string]string{
+			"proc/self/cgroup":          "0::/app\n",
+			"sys/fs/cgroup/app/cpu.max": "max 100000\n",
+		}, 0},
+		{"v2 parent is tighter", map[string]string{
+			"proc/self/cgroup":                   "0::/kube/pod/app\n",
+			"sys/fs/cgroup/kube/pod/app/cpu.max": "400000 100000\n",
+			"sys/fs/cgroup/kube/pod/cpu.max":     "150000 100000\n",
+		}, 1.5},
+		{"v1 unlimited", map[string]string{
+			"proc/self/cgroup":                            "12:cpu,cpuacct:/docker/abc\n11:memory:/docker/abc\n",
+			"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "-1\n",
+			"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		}, 0},
+		{"v1 half", map[string]string{
+			"proc/self/cgroup":                    "4:cpu:/\n",
+			"sys/fs/cgroup/cpu/cpu.cfs_quota_us":  "50000\n",
+			"sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000\n",
+		}, 0.5},
+		{"no cgroups", map[string]string{}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tc.files {
+				fsys[name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			q, err := detectCPUQuota(fsys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.cpus == 0 && q != nil:
+				t.Fatalf("got %+v", q)
+			case tc.cpus != 0 && (q == nil || q.CPUs != tc.cpus):
+				t.Fatalf("got %+v, want %v CPUs", q, tc.cpus)
+			}
+		})
+	}
+}
+
+//go:noinline
+func parkHere(ch chan struct{}) { <-ch }
+
+func TestGoroutineGroups(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	for range 5 {
+		go parkHere(ch)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	var g *Group
+	groups := Goroutines()
+	for i := range groups {
+		if len(groups[i].Stack) > 0 && strings.HasSuffix(groups[i].Stack[0].Func, ".parkHere") {
+			g = &groups[i]
+		}
+	}
+	if g == nil {
+		t.Fatalf("no parkHere group in %+v", groups)
+	}
+	if g.Count != 5 || g.States["chan receive"] != 5 || len(g.IDs) != 5 ||
+		g.CreatedBy == nil || !strings.HasSuffix(g.CreatedBy.Func, ".TestGoroutineGroups") ||
+		!strings.HasSuffix(g.Stack[0].File, "diag_test.go") {
+		t.Fatalf("%+v", g)
+	}
+}
+
+const dump = `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+
+goroutine 7 [chan receive, 12 minutes]:
+main.worker(0xc000012345, 0x1)
+	/src/main.go:20 +0x25
+created by main.main in goroutine 1
+	/src/main.go:8 +0x3a
+
+goroutine 9 [chan receive]:
+main.worker(0xc000099999, 0x2)
+	/src/main.go:20 +0x25
+created by main.main in goroutine 1
+	/src/main.go:8 +0x3a
+
+goroutine 11 [select, locked to thread]:
+main.worker(0xc000099999, 0x3)
+	/src/main.go:20 +0x25
+created by main.main in goroutine 1
+	/src/main.go:8 +0x3a
+`
+
+func TestParseGoroutines(t *testing.T) {
+	groups, err := ParseGoroutines(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("%+v", groups)
+	}
+	g := groups[0]
+	want := Frame{Func: "main.worker", File: "/src/main.go", Line: 20}
+	if g.Count != 3 || g.Stack[0] != want || *g.CreatedBy != (Frame{"main.main", "/src/main.go", 8}) ||
+		g.States["chan receive"] != 2 || g.States["select"] != 1 || g.MaxWait != 12*time.Minute ||
+		len(g.IDs) != 3 || g.IDs[2] != 11 {
+		t.Fatalf("%+v", g)
+	}
+
+	var buf bytes.Buffer
+	WriteGroups(&buf, groups)
+	if !strings.HasPrefix(buf.String(), "3 goroutines [2 chan receive, 1 select] waiting up to 12m0s\n  main.worker\n  \t/src/main.go:20\n") {
+		t.Fatalf("%s", buf.String())
+	}
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (*http.Response, []byte) {
+	t.Helper()
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res, body
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(Options{MaxProfileDuration: time.Second}))
+	defer srv.Close()
+
+	res, body := get(t, srv, "/api/stats")
+	var s Stats
+	if err := json.Unmarshal(body, &s); err != nil || res.StatusCode != 200 || s.Goroutines == 0 || len(s.Metrics) == 0 {
+		t.Fatalf("%d %v %s", res.StatusCode, err, body)
+	}
+	if res, body = get(t, srv, "/"); res.StatusCode != 200 || !bytes.Contains(body, []byte("Goroutines by stack")) {
+		t.Fatalf("%d %s", res.StatusCode, body)
+	}
+	var groups []Group
+	if res, body = get(t, srv, "/api/goroutines"); json.Unmarshal(body, &groups) != nil || len(groups) == 0 {
+		t.Fatalf("%d %s", res.StatusCode, body)
+	}
+	if res, body = get(t, srv, "/api/goroutines?format=text"); !bytes.Contains(body, []byte(" goroutines [")) {
+		t.Fatalf("%s", body)
+	}
+
+	// gzipped protobuf
+	res, body = get(t, srv, "/profile/heap")
+	if res.StatusCode != 200 || len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b ||
+		!strings.Contains(res.Header.Get("Content-Disposition"), "heap.pprof") {
+		t.Fatalf("%d %q", res.StatusCode, body)
+	}
+	if res, body = get(t, srv, "/profile/goroutine?debug=1"); !bytes.Contains(body, []byte("goroutine profile:")) {
+		t.Fatalf("%d %s", res.StatusCode, body)
+	}
+	if res, _ = get(t, srv, "/profile/cpu?seconds=0.05"); res.StatusCode != 200 {
+		t.Fatal(res.Status)
+	}
+	if res, _ = get(t, srv, "/profile/mutex?seconds=0.01"); res.StatusCode != 200 {
+		t.Fatal(res.Status)
+	}
+	for path, code := range map[string]int{
+		"/profile/nope":           404,
+		"/profile/cpu?seconds=5":  400, // over MaxProfileDuration
+		"/profile/cpu?seconds=-1": 400,
+	} {
+		if res, _ = get(t, srv, path); res.StatusCode != code {
+			t.Errorf("%s: %s", path, res.Status)
+		}
+	}
+
+	// someone else's CPU profile
+	if err := pprof.StartCPUProfile(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	defer pprof.StopCPUProfile()
+	if res, _ = get(t, srv, "/profile/cpu?seconds=0.05"); res.StatusCode != http.StatusConflict {
+		t.Fatal(res.Status)
+	}
+}
@@ -0,0 +1,192 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame is one call in a goroutine's stack
+type Frame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s\n\t%s:%d", f.Func, f.File, f.Line)
+}
+
+// Group is the goroutines sharing one stack. A leak shows up as a group
+// whose count keeps growing.
+type Group struct {
+	Count     int            `json:"count"`
+	States    map[string]int `json:"states"` // "chan receive": 3
+	MaxWait   time.Duration  `json:"max_wait_ns"`
+	Stack     []Frame        `json:"stack"`
+	CreatedBy *Frame         `json:"created_by,omitempty"`
+	IDs       []int64        `json:"ids"` // the first few
+}
+
+const maxGroupIDs = 10
+
+// Goroutines dumps every goroutine and groups them by stack, largest
+// group first. The dump stops the world briefly.
+func Goroutines() []Group {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	groups, _ := ParseGoroutines(bytes.NewReader(buf))
+	return groups
+}
+
+// ParseGoroutines groups a dump in the format of runtime.Stack or
+// debug=2 goroutine profiles
+func ParseGoroutines(r io.Reader) ([]Group, error) {
+	type parsed struct {
+		id      int64
+		state   string
+		wait    time.Duration
+		stack   []Frame
+		created *Frame
+	}
+	var all []parsed
+	var cur *parsed
+	var fn string // a function line waiting for its file line
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			// goroutine 7 [chan receive, 3 minutes, locked to thread]:
+			all = append(all, parsed{})
+			cur = &all[len(all)-1]
+			head, rest, _ := strings.Cut(strings.TrimPrefix(line, "goroutine "), " [")
+			cur.id, _ = strconv.ParseInt(head, 10, 64)
+			rest = strings.TrimSuffix(rest, "]:")
+			parts := strings.Split(rest, ", ")
+			cur.state = parts[0]
+			for _, p := range parts[1:] {
+				if n, ok := strings.CutSuffix(p, " minutes"); ok {
+					m, _ := strconv.Atoi(n)
+					cur.wait = time.Duration(m) * time.Minute
+				}
+			}
+			fn = ""
+		case cur == nil || line == "":
+		case strings.HasPrefix(line, "\t"):
+			if fn == "" {
+				continue
+			}
+			f := Frame{Func: fn}
+			loc := strings.TrimSpace(line)
+			if i := strings.LastIndex(loc, " +0x"); i >= 0 {
+				loc = loc[:i]
+			}
+			if i := strings.LastIndexByte(loc, ':'); i >= 0 {
+				f.File = loc[:i]
+				f.Line, _ = strconv.Atoi(loc[i+1:])
+			}
+			if strings.HasPrefix(fn, "created by ") {
+				f.Func = strings.TrimPrefix(fn, "created by ")
+				// "in goroutine 1" tells who, not where; leave it out so
+				// goroutines from different parents still group
+				f.Func, _, _ = strings.Cut(f.Func, " in goroutine ")
+				cur.created = &f
+			} else {
+				cur.stack = append(cur.stack, f)
+			}
+			fn = ""
+		default:
+			fn = line
+			if !strings.HasPrefix(fn, "created by ") {
+				// drop the argument words, they differ per goroutine
+				if i := strings.LastIndexByte(fn, '('); i > 0 && strings.HasSuffix(fn, ")") {
+					fn = fn[:i]
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]*Group{}
+	var groups []*Group
+	for _, p := range all {
+		var key strings.Builder
+		for _, f := range p.stack {
+			fmt.Fprintf(&key, "%s %s:%d\n", f.Func, f.File, f.Line)
+		}
+		if p.created != nil {
+			fmt.Fprintf(&key, "created by %s %s:%d\n", p.created.Func, p.created.File, p.created.Line)
+		}
+		g := byKey[key.String()]
+		if g == nil {
+			g = &Group{States: map[string]int{}, Stack: p.stack, CreatedBy: p.created}
+			byKey[key.String()] = g
+			groups = append(groups, g)
+		}
+		g.Count++
+		g.States[p.state]++
+		g.MaxWait = max(g.MaxWait, p.wait)
+		if len(g.IDs) < maxGroupIDs {
+			g.IDs = append(g.IDs, p.id)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	out := make([]Group, len(groups))
+	for i, g := range groups {
+		out[i] = *g
+	}
+	return out, nil
+}
+
+// states lists the states, most common first: "2 chan receive, 1 select"
+func (g Group) states() string {
+	names := make([]string, 0, len(g.States))
+	for s := range g.States {
+		names = append(names, s)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		return g.States[a] > g.States[b] || g.States[a] == g.States[b] && a < b
+	})
+	for i, s := range names {
+		names[i] = fmt.Sprintf("%d %s", g.States[s], s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteGroups writes groups as text, one stack per group
+func WriteGroups(w io.Writer, groups []Group) error {
+	bw := bufio.NewWriter(w)
+	for _, g := range groups {
+		fmt.Fprintf(bw, "%d goroutines [%s]", g.Count, g.states())
+		if g.MaxWait > 0 {
+			fmt.Fprintf(bw, " waiting up to %s", g.MaxWait)
+		}
+		fmt.Fprintln(bw)
+		for _, f := range g.Stack {
+			fmt.Fprintf(bw, "  %s\n", strings.ReplaceAll(f.String(), "\n", "\n  "))
+		}
+		if g.CreatedBy != nil {
+			fmt.Fprintf(bw, "  created by %s\n", strings.ReplaceAll(g.CreatedBy.String(), "\n", "\n  "))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
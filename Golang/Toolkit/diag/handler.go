@@ -0,0 +1,186 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// Options tunes NewHandler
+type Options struct {
+	// MaxProfileDuration caps ?seconds on profile requests, default 30s
+	MaxProfileDuration time.Duration
+	// Refresh is how often the HTML page reloads, default 5s
+	Refresh time.Duration
+}
+
+func (o *Options) defaults() {
+	if o.MaxProfileDuration <= 0 {
+		o.MaxProfileDuration = 30 * time.Second
+	}
+	if o.Refresh <= 0 {
+		o.Refresh = 5 * time.Second
+	}
+}
+
+type handler struct {
+	opts Options
+}
+
+// NewHandler serves
+//
+//	GET /                   HTML page with the stats and goroutine groups
+//	GET /api/stats          Stats as JSON
+//	GET /api/goroutines     goroutines grouped by stack; ?format=text for text
+//	GET /profile/{name}     a pprof profile: cpu, trace, heap, allocs,
+//	                        goroutine, block, mutex, threadcreate
+//
+// Links are relative, so mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/debug/diag/", http.StripPrefix("/debug/diag", diag.NewHandler(diag.Options{})))
+func NewHandler(opts Options) http.Handler {
+	opts.defaults()
+	h := &handler{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.page)
+	mux.HandleFunc("GET /api/stats", h.stats)
+	mux.HandleFunc("GET /api/goroutines", h.goroutines)
+	mux.HandleFunc("GET /profile/{name}", h.profile)
+	return mux
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Collect())
+}
+
+func (h *handler) goroutines(w http.ResponseWriter, r *http.Request) {
+	groups := Goroutines()
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		WriteGroups(w, groups)
+		return
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+func (h *handler) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := struct {
+		Stats   Stats
+		Groups  []Group
+		Refresh int
+	}{Collect(), Goroutines(), int(h.opts.Refresh / time.Second)}
+	if len(data.Groups) > 20 {
+		data.Groups = data.Groups[:20]
+	}
+	if err := pageTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func bytesize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var pageTemplate = template.Must(template.New("diag").Funcs(template.FuncMap{
+	"bytes": bytesize,
+	"round": func(d time.Duration) time.Duration {
+		switch {
+		case d > time.Second:
+			return d.Round(time.Second)
+		case d > time.Millisecond:
+			return d.Round(time.Microsecond)
+		}
+		return d
+	},
+	"states": Group.states,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Stats.Goroutines}} goroutines, {{bytes .Stats.Heap.Objects}} heap</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { padding: .3em .8em; border-bottom: 1px solid #ddd; text-align: left; vertical-align: top; }
+pre { margin: 0; font-size: .85em; }
+.warn { color: #cf222e; }
+</style>
+</head>
+<body>
+{{with .Stats}}
+<h1>{{.Goroutines}} goroutines, {{bytes .Heap.Objects}} heap</h1>
+{{range .Warnings}}<p class="warn">{{.}}</p>{{end}}
+<table>
+<tr><th>Go</th><td>{{.GoVersion}} {{.GOOS}}/{{.GOARCH}}</td></tr>
+<tr><th>Uptime</th><td>{{round .Uptime}}</td></tr>
+<tr><th>CPUs</th><td>{{.NumCPU}}</td></tr>
+<tr><th>GOMAXPROCS</th><td>{{.GOMAXPROCS}}</td></tr>
+<tr><th>CPU quota</th><td>{{with .CPUQuota}}{{printf "%.2f" .CPUs}} (cgroup v{{.Version}} {{.Cgroup}}){{else}}none{{end}}</td></tr>
+</table>
+<h2>Memory</h2>
+<table>
+<tr><th>Heap objects</th><td>{{bytes .Heap.Objects}} in {{.Heap.ObjectCount}} objects</td></tr>
+<tr><th>Next GC at</th><td>{{bytes .Heap.Goal}}</td></tr>
+<tr><th>Stacks</th><td>{{bytes .Heap.Stacks}}</td></tr>
+<tr><th>Free / released</th><td>{{bytes .Heap.Free}} / {{bytes .Heap.Released}}</td></tr>
+<tr><th>Mapped</th><td>{{bytes .Heap.Total}}</td></tr>
+<tr><th>Allocated in total</th><td>{{bytes .Heap.Allocated}}</td></tr>
+</table>
+<h2>GC</h2>
+<table>
+<tr><th>Cycles</th><td>{{.GC.Count}}</td></tr>
+<tr><th>GOGC</th><td>{{if lt .GC.GOGC 0}}off{{else}}{{.GC.GOGC}}{{end}}</td></tr>
+<tr><th>Pause total</th><td>{{round .GC.PauseTotal}}</td></tr>
+<tr><th>Pause p50 / p99 / max</th><td>{{round .GC.PauseP50}} / {{round .GC.PauseP99}} / {{round .GC.PauseMax}}</td></tr>
+<tr><th>Recent pauses</th><td>{{range .GC.RecentPause}}{{round .}} {{end}}</td></tr>
+</table>
+{{end}}
+<h2>Goroutines by stack</h2>
+<p><a href="api/goroutines?format=text">full dump</a></p>
+<table>
+<tr><th>Count</th><th>State</th><th>Stack</th></tr>
+{{range .Groups}}
+<tr>
+<td>{{.Count}}</td>
+<td>{{states .}}{{if .MaxWait}}<br>up to {{.MaxWait}}{{end}}</td>
+<td><pre>{{range .Stack}}{{.Func}}
+    {{.File}}:{{.Line}}
+{{end}}{{with .CreatedBy}}created by {{.Func}}{{end}}</pre></td>
+</tr>
+{{end}}
+</table>
+<h2>Profiles</h2>
+<p>
+<a href="profile/cpu?seconds=10">cpu (10s)</a> ·
+<a href="profile/heap">heap</a> ·
+<a href="profile/allocs">allocs</a> ·
+<a href="profile/goroutine?debug=1">goroutine</a> ·
+<a href="profile/block?seconds=10">block (10s)</a> ·
+<a href="profile/mutex?seconds=10">mutex (10s)</a> ·
+<a href="profile/threadcreate">threadcreate</a> ·
+<a href="profile/trace?seconds=5">trace (5s)</a>
+</p>
+<p>Open a profile with <code>go tool pprof</code>, a trace with <code>go tool trace</code>.
+Every <a href="api/stats">runtime/metrics</a> value is in the JSON.</p>
+</body>
+</html>
+`))
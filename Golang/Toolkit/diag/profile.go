@@ -0,0 +1,120 @@
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// only one CPU profile or trace can run at a time, process-wide
+var cpuBusy, traceBusy sync.Mutex
+
+// profile serves GET /profile/{name}. cpu and trace record for ?seconds
+// (default 5); the pprof.Lookup profiles are a snapshot, except that
+// ?seconds on block and mutex turns sampling on for that long first (block
+// sampling is left off afterwards, its old rate can't be read). ?debug=1
+// asks for text instead of the gzipped protobuf.
+func (h *handler) profile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	seconds, err := h.seconds(r, name)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+
+	switch name {
+	case "cpu":
+		if !cpuBusy.TryLock() {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "a CPU profile is already running"})
+			return
+		}
+		defer cpuBusy.Unlock()
+		h.download(w, name+".pprof")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			// started outside this handler
+			w.Header().Del("Content-Disposition")
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		sleep(r, seconds)
+		pprof.StopCPUProfile()
+	case "trace":
+		if !traceBusy.TryLock() {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "a trace is already running"})
+			return
+		}
+		defer traceBusy.Unlock()
+		h.download(w, name+".out")
+		if err := trace.Start(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		sleep(r, seconds)
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no profile " + strconv.Quote(name)})
+			return
+		}
+		switch {
+		case seconds == 0:
+		case name == "block":
+			runtime.SetBlockProfileRate(1)
+			defer runtime.SetBlockProfileRate(0)
+			sleep(r, seconds)
+		case name == "mutex":
+			defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+			sleep(r, seconds)
+		}
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			h.download(w, name+".pprof")
+		}
+		p.WriteTo(w, debug)
+	}
+}
+
+// seconds reads ?seconds, capped at MaxProfileDuration
+func (h *handler) seconds(r *http.Request, name string) (time.Duration, error) {
+	s := r.URL.Query().Get("seconds")
+	if s == "" {
+		if name == "cpu" || name == "trace" {
+			return min(5*time.Second, h.opts.MaxProfileDuration), nil
+		}
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("seconds must be a non-negative number")
+	}
+	d := time.Duration(n * float64(time.Second))
+	if d > h.opts.MaxProfileDuration {
+		return 0, fmt.Errorf("seconds is capped at %s", h.opts.MaxProfileDuration)
+	}
+	return d, nil
+}
+
+func (h *handler) download(w http.ResponseWriter, file string) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+file+`"`)
+}
+
+// sleep waits d or until the client goes away
+func sleep(r *http.Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,221 @@
+// Package diag is Golang/Goroutine/Nos.Of.CPUs.go grown into an embeddable
+// diagnostics handler: live runtime stats as JSON and as an HTML page,
+// goroutine dumps grouped by stack, and pprof profiles on demand.
+//
+// Stats come from runtime/metrics and debug.ReadGCStats, neither of which
+// stops the world, so polling the page is cheap. Stack dumps and profiles
+// reveal the program's internals; mount the handler behind authentication.
+package diag
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"time"
+)
+
+var started = time.Now()
+
+// Stats is a point-in-time view of the runtime
+type Stats struct {
+	Time       time.Time     `json:"time"`
+	Uptime     time.Duration `json:"uptime_ns"`
+	GoVersion  string        `json:"go_version"`
+	GOOS       string        `json:"goos"`
+	GOARCH     string        `json:"goarch"`
+	NumCPU     int           `json:"num_cpu"`
+	GOMAXPROCS int           `json:"gomaxprocs"`
+	// CPUQuota is the cgroup CPU limit, nil when there is none
+	CPUQuota   *CPUQuota `json:"cpu_quota,omitempty"`
+	Goroutines int       `json:"goroutines"`
+	Heap       Heap      `json:"heap"`
+	GC         GC        `json:"gc"`
+	// Warnings point out settings that hurt, like GOMAXPROCS above the quota
+	Warnings []string `json:"warnings,omitempty"`
+	// Metrics is every runtime/metrics value this Go version supports
+	Metrics []Metric `json:"metrics"`
+}
+
+// Heap is memory use in bytes
+type Heap struct {
+	Objects     uint64 `json:"objects_bytes"` // live and not yet swept
+	ObjectCount uint64 `json:"object_count"`
+	Goal        uint64 `json:"goal_bytes"` // the next GC starts here
+	Stacks      uint64 `json:"stacks_bytes"`
+	Free        uint64 `json:"free_bytes"`     // held, not in use
+	Released    uint64 `json:"released_bytes"` // returned to the OS
+	Total       uint64 `json:"total_bytes"`    // everything the runtime mapped
+	Allocated   uint64 `json:"allocated_bytes"`
+}
+
+// GC summarizes collections
+type GC struct {
+	Count       int64           `json:"count"`
+	Last        time.Time       `json:"last"`
+	PauseTotal  time.Duration   `json:"pause_total_ns"`
+	RecentPause []time.Duration `json:"recent_pauses_ns"` // newest first
+	PauseP50    time.Duration   `json:"pause_p50_ns"`
+	PauseP99    time.Duration   `json:"pause_p99_ns"`
+	PauseMax    time.Duration   `json:"pause_max_ns"`
+	GOGC        int64           `json:"gogc"` // -1 when off
+	MemoryLimit int64           `json:"memory_limit_bytes"`
+}
+
+// Metric is one runtime/metrics sample. Histograms are summarized by their
+// count and bucket-bounded quantiles.
+type Metric struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Cumulative  bool    `json:"cumulative"`
+	Value       float64 `json:"value"`
+	Histogram   bool    `json:"histogram,omitempty"`
+	Count       uint64  `json:"count,omitempty"`
+	P50         float64 `json:"p50,omitempty"`
+	P90         float64 `json:"p90,omitempty"`
+	P99         float64 `json:"p99,omitempty"`
+	Max         float64 `json:"max,omitempty"`
+}
+
+var descriptions = func() map[string]metrics.Description {
+	m := map[string]metrics.Description{}
+	for _, d := range metrics.All() {
+		m[d.Name] = d
+	}
+	return m
+}()
+
+// Collect reads the current Stats
+func Collect() Stats {
+	s := Stats{
+		Time:       time.Now(),
+		Uptime:     time.Since(started),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if q, err := DetectCPUQuota(); err == nil {
+		s.CPUQuota = q
+	}
+
+	samples := make([]metrics.Sample, 0, len(descriptions))
+	for name := range descriptions {
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+	metrics.Read(samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	values := map[string]uint64{} // integer metrics, unrounded
+	for _, sm := range samples {
+		d := descriptions[sm.Name]
+		m := Metric{Name: sm.Name, Description: d.Description, Cumulative: d.Cumulative}
+		switch sm.Value.Kind() {
+		case metrics.KindUint64:
+			values[m.Name] = sm.Value.Uint64()
+			m.Value = float64(values[m.Name])
+		case metrics.KindFloat64:
+			m.Value = sm.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			summarize(&m, sm.Value.Float64Histogram())
+		default:
+			continue // a kind newer than this code
+		}
+		s.Metrics = append(s.Metrics, m)
+	}
+
+	s.Heap = Heap{
+		Objects:     values["/memory/classes/heap/objects:bytes"],
+		ObjectCount: values["/gc/heap/objects:objects"],
+		Goal:        values["/gc/heap/goal:bytes"],
+		Stacks:      values["/memory/classes/heap/stacks:bytes"],
+		Free:        values["/memory/classes/heap/free:bytes"],
+		Released:    values["/memory/classes/heap/released:bytes"],
+		Total:       values["/memory/classes/total:bytes"],
+		Allocated:   values["/gc/heap/allocs:bytes"],
+	}
+
+	var gs debug.GCStats
+	gs.PauseQuantiles = make([]time.Duration, 101) // percentiles
+	debug.ReadGCStats(&gs)
+	s.GC = GC{
+		Count:       gs.NumGC,
+		Last:        gs.LastGC,
+		PauseTotal:  gs.PauseTotal,
+		RecentPause: gs.Pause[:min(len(gs.Pause), 16)],
+		GOGC:        100,
+		MemoryLimit: math.MaxInt64,
+	}
+	if v, ok := values["/gc/gogc:percent"]; ok {
+		// SetGCPercent(-1) reads back as the largest uint64
+		s.GC.GOGC = int64(v)
+	}
+	if v, ok := values["/gc/gomemlimit:bytes"]; ok {
+		s.GC.MemoryLimit = int64(v)
+	}
+	if gs.NumGC > 0 {
+		s.GC.PauseP50, s.GC.PauseP99, s.GC.PauseMax = gs.PauseQuantiles[50], gs.PauseQuantiles[99], gs.PauseQuantiles[100]
+	}
+
+	s.Warnings = warnings(s)
+	return s
+}
+
+// summarize fills m from h; quantiles are bucket upper bounds, or lower
+// bounds for the open-ended last bucket
+func summarize(m *Metric, h *metrics.Float64Histogram) {
+	m.Histogram = true
+	for _, c := range h.Counts {
+		m.Count += c
+	}
+	if m.Count == 0 {
+		return
+	}
+	bound := func(i int) float64 {
+		if b := h.Buckets[i+1]; !math.IsInf(b, 1) {
+			return b
+		}
+		return h.Buckets[i]
+	}
+	quantile := func(q float64) float64 {
+		want := uint64(math.Ceil(q * float64(m.Count)))
+		var seen uint64
+		for i, c := range h.Counts {
+			seen += c
+			if seen >= want {
+				return bound(i)
+			}
+		}
+		return bound(len(h.Counts) - 1)
+	}
+	m.P50, m.P90, m.P99 = quantile(.5), quantile(.9), quantile(.99)
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			m.Max = bound(i)
+			break
+		}
+	}
+	// the sum isn't tracked; Value is the mean estimate from bucket bounds
+	var sum float64
+	for i, c := range h.Counts {
+		if c > 0 {
+			sum += float64(c) * bound(i)
+		}
+	}
+	m.Value = sum / float64(m.Count)
+}
+
+func warnings(s Stats) []string {
+	var w []string
+	if q := s.CPUQuota; q != nil && float64(s.GOMAXPROCS) > math.Ceil(q.CPUs) {
+		w = append(w, fmt.Sprintf("GOMAXPROCS %d is above the cgroup limit of %.2f CPUs; the process will be throttled, set GOMAXPROCS to match",
+			s.GOMAXPROCS, q.CPUs))
+	}
+	if s.GC.GOGC < 0 && s.GC.MemoryLimit == math.MaxInt64 {
+		w = append(w, "GC is off and there is no memory limit; the heap only grows")
+	}
+	return w
+}
@@ -0,0 +1,435 @@
+// Package chunked downloads large files to disk in parallel byte ranges. An
+// interrupted download leaves dest.part and a dest.part.json journal behind and
+// the next call with the same dest picks up where it stopped.
+package chunked
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/workpool"
+)
+
+// ErrChanged means the remote file changed while it was being downloaded
+var ErrChanged = errors.New("chunked: remote file changed during download")
+
+// ChecksumError is returned when the finished file does not have the expected SHA-256
+type ChecksumError struct {
+	Want, Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("chunked: sha256 mismatch: want %s, got %s", e.Want, e.Got)
+}
+
+// Config controls a Download. The zero value is usable.
+type Config struct {
+	Client      *http.Client
+	ChunkSize   int64 // default 8 MiB
+	Concurrency int   // chunks fetched at once, default 4
+	// SHA256 is the expected hex digest; empty skips verification
+	SHA256 string
+	// Retries per chunk, resuming from the last byte received
+	Retries    int
+	RetryDelay time.Duration
+	// Progress is called at most every ProgressInterval and once at the end
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+	// CheckpointInterval is how often the journal is saved besides after each chunk
+	CheckpointInterval time.Duration
+}
+
+// Progress is reported while downloading
+type Progress struct {
+	URL     string
+	Total   int64 // -1 when the server did not say
+	Done    int64 // including Resumed
+	Resumed int64 // bytes that were already on disk from an earlier attempt
+}
+
+// Result describes a finished download
+type Result struct {
+	Path    string
+	Size    int64
+	SHA256  string
+	Resumed int64
+	Chunks  int // 1 when the server does not support ranges
+}
+
+func (c *Config) defaults() {
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = 8 << 20
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 500 * time.Millisecond
+	}
+	if c.ProgressInterval <= 0 {
+		c.ProgressInterval = 200 * time.Millisecond
+	}
+	if c.CheckpointInterval <= 0 {
+		c.CheckpointInterval = 2 * time.Second
+	}
+}
+
+// Download fetches rawURL into dest. Servers that support byte ranges get
+// parallel, resumable chunks; others are streamed in one request. dest only
+// appears once the file is complete and verified.
+func Download(ctx context.Context, rawURL, dest string, cfg Config) (Result, error) {
+	cfg.defaults()
+	partPath, journalPath := dest+".part", dest+".part.json"
+
+	// a one byte range request tells us the size, validators and range support in one go
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	probe, err := cfg.Client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch probe.StatusCode {
+	case http.StatusPartialContent:
+		probe.Body.Close()
+	case http.StatusOK:
+		// no range support: this response already is the whole file
+		defer probe.Body.Close()
+		return single(ctx, rawURL, dest, partPath, journalPath, probe, cfg)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// not even byte 0 exists: the file is empty
+		probe.Body.Close()
+		if cr := probe.Header.Get("Content-Range"); cr != "bytes */0" {
+			return Result{}, fmt.Errorf("chunked: GET %s: status %d, Content-Range %q", rawURL, probe.StatusCode, cr)
+		}
+		probe.Body, probe.ContentLength = http.NoBody, 0
+		return single(ctx, rawURL, dest, partPath, journalPath, probe, cfg)
+	default:
+		probe.Body.Close()
+		return Result{}, fmt.Errorf("chunked: GET %s: status %d", rawURL, probe.StatusCode)
+	}
+
+	size, err := totalSize(probe.Header.Get("Content-Range"))
+	if err != nil {
+		return Result{}, err
+	}
+	want := &journal{
+		URL:          rawURL,
+		Size:         size,
+		ETag:         probe.Header.Get("ETag"),
+		LastModified: probe.Header.Get("Last-Modified"),
+		ChunkSize:    cfg.ChunkSize,
+		Written:      make([]int64, (size+cfg.ChunkSize-1)/cfg.ChunkSize),
+	}
+
+	j, err := loadJournal(journalPath)
+	if err != nil {
+		return Result{}, err
+	}
+	flags := os.O_RDWR | os.O_CREATE
+	if j == nil || !j.matches(want) || !partIntact(partPath, size) {
+		// nothing to resume, the remote file is no longer the one we started,
+		// or the bytes the journal counts are gone
+		j = want
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return Result{}, err
+	}
+
+	d := &download{cfg: cfg, url: rawURL, f: f, j: j, journalPath: journalPath, resumed: j.done()}
+	d.progress = newReporter(cfg, rawURL, size, d.resumed)
+	if err := d.run(ctx); err != nil {
+		return Result{}, err
+	}
+
+	sum, err := hashFile(f)
+	if err != nil {
+		return Result{}, err
+	}
+	return finish(dest, partPath, journalPath, f, sum, Result{Size: size, Resumed: d.resumed, Chunks: len(j.Written)}, cfg)
+}
+
+// download is the state of one ranged download
+type download struct {
+	cfg         Config
+	url         string
+	f           *os.File
+	journalPath string
+	resumed     int64
+	progress    *reporter
+
+	mu sync.Mutex // guards j.Written and journal saves
+	j  *journal
+}
+
+func (d *download) run(ctx context.Context) error {
+	stop := make(chan struct{})
+	var ticker sync.WaitGroup
+	ticker.Add(1)
+	go func() {
+		defer ticker.Done()
+		t := time.NewTicker(d.cfg.CheckpointInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				d.checkpoint()
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(d.j.Written))
+	workpool.Run(ctx, len(d.j.Written), d.cfg.Concurrency, func(ctx context.Context, i int) {
+		errs[i] = d.chunk(ctx, i)
+		if errs[i] != nil {
+			// one failed chunk fails the download; stop the rest early
+			cancel()
+		}
+	})
+
+	close(stop)
+	ticker.Wait()
+	// whatever happened, keep what we have for the next attempt
+	cerr := d.checkpoint()
+	d.progress.final()
+
+	// report the chunk that failed rather than the ones we cancelled because of it
+	var first error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+	return cerr
+}
+
+// chunk fetches what is missing of chunk i, retrying from the last byte received
+func (d *download) chunk(ctx context.Context, i int) error {
+	start, end := d.j.chunkBounds(i)
+	d.mu.Lock()
+	complete := start+d.j.Written[i] > end
+	d.mu.Unlock()
+	if complete {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt <= d.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(d.cfg.RetryDelay << (attempt - 1)):
+			}
+		}
+		d.mu.Lock()
+		written := d.j.Written[i]
+		d.mu.Unlock()
+		err = d.fetchRange(ctx, i, start+written, end)
+		if err == nil || errors.Is(err, ErrChanged) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return d.checkpoint()
+}
+
+func (d *download) fetchRange(ctx context.Context, i int, from, to int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	// If-Range makes a changed file come back as a 200 instead of mixing versions
+	if v := d.j.ifRange(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+	res, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return ErrChanged
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("chunked: range %d-%d: status %d", from, to, res.StatusCode)
+	}
+	if got := res.Header.Get("Content-Range"); !strings.HasPrefix(got, fmt.Sprintf("bytes %d-", from)) {
+		return fmt.Errorf("chunked: asked for bytes %d-%d, got %q", from, to, got)
+	}
+
+	w := io.NewOffsetWriter(d.f, from)
+	buf := make([]byte, 32<<10)
+	remaining := to - from + 1
+	for remaining > 0 {
+		n, rerr := res.Body.Read(buf[:min(int64(len(buf)), remaining)])
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			remaining -= int64(n)
+			d.mu.Lock()
+			d.j.Written[i] += int64(n)
+			d.mu.Unlock()
+			d.progress.add(int64(n))
+		}
+		if rerr == io.EOF && remaining > 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if rerr != nil && rerr != io.EOF {
+			return rerr
+		}
+	}
+	return nil
+}
+
+// partIntact reports whether a .part file left by an earlier run is still
+// there at its full size; a missing or cut file would resume as zeros
+func partIntact(path string, size int64) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Size() >= size
+}
+
+// checkpoint syncs the data and then records it, so the journal never claims unsynced bytes
+func (d *download) checkpoint() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.f.Sync(); err != nil {
+		return err
+	}
+	return d.j.save(d.journalPath)
+}
+
+// single streams a response from a server without range support
+func single(ctx context.Context, rawURL, dest, partPath, journalPath string, res *http.Response, cfg Config) (Result, error) {
+	f, err := os.Create(partPath)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	os.Remove(journalPath)
+
+	progress := newReporter(cfg, rawURL, res.ContentLength, 0)
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h, progress), res.Body)
+	progress.final()
+	if err != nil {
+		return Result{}, err
+	}
+	if res.ContentLength >= 0 && n != res.ContentLength {
+		return Result{}, io.ErrUnexpectedEOF
+	}
+	if err := f.Sync(); err != nil {
+		return Result{}, err
+	}
+	return finish(dest, partPath, journalPath, f, hex.EncodeToString(h.Sum(nil)), Result{Size: n, Chunks: 1}, cfg)
+}
+
+// finish verifies the digest and moves the .part file into place
+func finish(dest, partPath, journalPath string, f *os.File, sum string, res Result, cfg Config) (Result, error) {
+	if cfg.SHA256 != "" && !strings.EqualFold(sum, cfg.SHA256) {
+		// corrupt data cannot be resumed into a good file; start over next time
+		f.Close()
+		os.Remove(partPath)
+		os.Remove(journalPath)
+		return Result{}, &ChecksumError{Want: strings.ToLower(cfg.SHA256), Got: sum}
+	}
+	if err := f.Close(); err != nil {
+		return Result{}, err
+	}
+	if err := os.Rename(partPath, dest); err != nil {
+		return Result{}, err
+	}
+	os.Remove(journalPath)
+	res.Path, res.SHA256 = dest, sum
+	return res, nil
+}
+
+func hashFile(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// totalSize reads the complete length from "bytes 0-0/12345"
+func totalSize(contentRange string) (int64, error) {
+	_, total, ok := strings.Cut(contentRange, "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if !ok || err != nil || size <= 0 {
+		return 0, fmt.Errorf("chunked: cannot tell the size from Content-Range %q", contentRange)
+	}
+	return size, nil
+}
+
+// reporter rate limits Progress calls
+type reporter struct {
+	cfg  Config
+	mu   sync.Mutex
+	p    Progress
+	last time.Time
+}
+
+func newReporter(cfg Config, url string, total, resumed int64) *reporter {
+	return &reporter{cfg: cfg, p: Progress{URL: url, Total: total, Done: resumed, Resumed: resumed}}
+}
+
+func (r *reporter) add(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.p.Done += n
+	if r.cfg.Progress != nil && time.Since(r.last) >= r.cfg.ProgressInterval {
+		r.last = time.Now()
+		r.cfg.Progress(r.p)
+	}
+}
+
+// Write lets the reporter count a stream
+func (r *reporter) Write(p []byte) (int, error) {
+	r.add(int64(len(p)))
+	return len(p), nil
+}
+
+func (r *reporter) final() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cfg.Progress != nil {
+		r.cfg.Progress(r.p)
+	}
+}
@@ -0,0 +1,304 @@
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// file serves content with range support
+type file struct {
+	content []byte
+	etag    atomic.Value
+	// dropFirst cuts the first response for each range start in half
+	dropFirst bool
+	dropped   sync.Map
+	noRanges  bool
+}
+
+func newFile(t *testing.T, size int) *file {
+	f := &file{content: make([]byte, size)}
+	if _, err := rand.Read(f.content); err != nil {
+		t.Fatal(err)
+	}
+	f.etag.Store(`"v1"`)
+	return f
+}
+
+func (f *file) sum() string {
+	s := sha256.Sum256(f.content)
+	return hex.EncodeToString(s[:])
+}
+
+func (f *file) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cw := &droppingWriter{ResponseWriter: w}
+	if f.noRanges {
+		w.Write(f.content)
+		return
+	}
+	if rng := r.Header.Get("Range"); f.dropFirst && rng != "bytes=0-0" {
+		if _, seen := f.dropped.LoadOrStore(rng, true); !seen {
+			cw.limit = 1000
+		}
+	}
+	w.Header().Set("ETag", f.etag.Load().(string))
+	http.ServeContent(cw, r, "big.bin", time.Time{}, bytes.NewReader(f.content))
+}
+
+// droppingWriter drops the connection after limit bytes
+type droppingWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (d *droppingWriter) Write(p []byte) (int, error) {
+	if d.limit > 0 && len(p) > d.limit {
+		d.ResponseWriter.Write(p[:d.limit])
+		panic(http.ErrAbortHandler)
+	}
+	return d.ResponseWriter.Write(p)
+}
+
+// countingTransport counts the response body bytes the client reads
+type countingTransport struct {
+	n *atomic.Int64
+}
+
+func (c countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		res.Body = &countingBody{ReadCloser: res.Body, n: c.n}
+	}
+	return res, err
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func TestParallelChunks(t *testing.T) {
+	f := newFile(t, 1<<20)
+	f.dropFirst = true
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "big.bin")
+	var last Progress
+	res, err := Download(context.Background(), srv.URL, dest, Config{
+		ChunkSize:   64 << 10,
+		Concurrency: 4,
+		SHA256:      f.sum(),
+		Retries:     2,
+		RetryDelay:  time.Millisecond,
+		Progress:    func(p Progress) { last = p },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Chunks != 16 || res.Size != 1<<20 || res.SHA256 != f.sum() {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if last.Done != 1<<20 || last.Total != 1<<20 {
+		t.Fatalf("final progress %+v", last)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, f.content) {
+		t.Fatal("content differs")
+	}
+	for _, leftover := range []string{dest + ".part", dest + ".part.json"} {
+		if _, err := os.Stat(leftover); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s should be gone", leftover)
+		}
+	}
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	const size = 4 << 20
+	f := newFile(t, size)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{
+		ChunkSize:        256 << 10,
+		Concurrency:      2,
+		SHA256:           f.sum(),
+		ProgressInterval: time.Nanosecond,
+		Progress: func(p Progress) {
+			if p.Done >= size/2 {
+				cancel()
+			}
+		},
+	}
+	if _, err := Download(ctx, srv.URL, dest, cfg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("an incomplete download must not appear at dest")
+	}
+	j, err := loadJournal(dest + ".part.json")
+	if err != nil || j == nil || j.done() < size/2 {
+		t.Fatalf("journal should record the first half: %+v %v", j, err)
+	}
+
+	var received atomic.Int64
+	cfg.Client = &http.Client{Transport: countingTransport{&received}}
+	cfg.Progress = nil
+	res, err := Download(context.Background(), srv.URL, dest, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Resumed != j.done() {
+		t.Fatalf("resumed %d bytes, journal had %d", res.Resumed, j.done())
+	}
+	// the probe's one byte is never read, so this is exactly what was missing
+	if got := received.Load(); got != size-res.Resumed {
+		t.Fatalf("second run downloaded %d bytes, only %d were missing", got, size-res.Resumed)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, f.content) {
+		t.Fatal("resumed content differs")
+	}
+}
+
+func TestChangedFileStartsOver(t *testing.T) {
+	f := newFile(t, 512<<10)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := Config{ChunkSize: 64 << 10, Concurrency: 1, ProgressInterval: time.Nanosecond,
+		Progress: func(p Progress) {
+			if p.Done > 100<<10 {
+				cancel()
+			}
+		}}
+	Download(ctx, srv.URL, dest, cfg)
+	cancel()
+
+	f.etag.Store(`"v2"`)
+	cfg.Progress = nil
+	res, err := Download(context.Background(), srv.URL, dest, cfg)
+	if err != nil || res.Resumed != 0 {
+		t.Fatalf("a new ETag must discard the partial file: %+v %v", res, err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	f := newFile(t, 100<<10)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	_, err := Download(context.Background(), srv.URL, dest, Config{ChunkSize: 32 << 10, SHA256: strings.Repeat("0", 64)})
+	var sumErr *ChecksumError
+	if !errors.As(err, &sumErr) || sumErr.Got != f.sum() {
+		t.Fatalf("expected a ChecksumError, got %v", err)
+	}
+	for _, p := range []string{dest, dest + ".part", dest + ".part.json"} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s should not exist after a bad checksum", p)
+		}
+	}
+}
+
+func TestServerWithoutRanges(t *testing.T) {
+	f := newFile(t, 300<<10)
+	f.noRanges = true
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	res, err := Download(context.Background(), srv.URL, dest, Config{SHA256: f.sum()})
+	if err != nil || res.Chunks != 1 || res.Size != 300<<10 {
+		t.Fatalf("%+v %v", res, err)
+	}
+}
+
+func TestWeakETagFallsBackToFullRanges(t *testing.T) {
+	f := newFile(t, 256<<10)
+	f.etag.Store(`W/"v1"`)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	// ServeContent answers a weak If-Range with the whole file, failing every chunk
+	res, err := Download(context.Background(), srv.URL, dest, Config{ChunkSize: 64 << 10, SHA256: f.sum()})
+	if err != nil || res.Chunks != 4 {
+		t.Fatalf("%+v %v", res, err)
+	}
+}
+
+func TestMissingPartFileStartsOver(t *testing.T) {
+	const size = 1 << 20
+	f := newFile(t, size)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "big.bin")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := Config{ChunkSize: 64 << 10, Concurrency: 1, ProgressInterval: time.Nanosecond,
+		Progress: func(p Progress) {
+			if p.Done >= size/2 {
+				cancel()
+			}
+		}}
+	Download(ctx, srv.URL, dest, cfg)
+	cancel()
+	if j, _ := loadJournal(dest + ".part.json"); j == nil || j.done() == 0 {
+		t.Fatalf("journal should record progress: %+v", j)
+	}
+	os.Remove(dest + ".part")
+
+	// no SHA256, so only the journal check stands between us and a file of zeros
+	cfg.Progress = nil
+	res, err := Download(context.Background(), srv.URL, dest, cfg)
+	if err != nil || res.Resumed != 0 {
+		t.Fatalf("%+v %v", res, err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, f.content) {
+		t.Fatal("content differs")
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// what nginx answers; ServeContent sends a 200 instead
+		w.Header().Set("Content-Range", "bytes */0")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "empty.bin")
+
+	empty := sha256.Sum256(nil)
+	res, err := Download(context.Background(), srv.URL, dest, Config{SHA256: hex.EncodeToString(empty[:])})
+	if err != nil || res.Size != 0 {
+		t.Fatalf("%+v %v", res, err)
+	}
+	if fi, err := os.Stat(dest); err != nil || fi.Size() != 0 {
+		t.Fatalf("%v %v", fi, err)
+	}
+}
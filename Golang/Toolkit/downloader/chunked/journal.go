@@ -0,0 +1,74 @@
+package chunked
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+// journal records how far each chunk of a .part file got. It is only written
+// after the .part file is synced, so every byte it claims is on disk.
+type journal struct {
+	URL          string  `json:"url"`
+	Size         int64   `json:"size"`
+	ETag         string  `json:"etag,omitempty"`
+	LastModified string  `json:"last_modified,omitempty"`
+	ChunkSize    int64   `json:"chunk_size"`
+	Written      []int64 `json:"written"` // bytes written per chunk
+}
+
+// matches reports whether a saved journal describes the same remote file
+func (j *journal) matches(other *journal) bool {
+	return j.URL == other.URL && j.Size == other.Size && j.ETag == other.ETag &&
+		j.LastModified == other.LastModified && j.ChunkSize == other.ChunkSize &&
+		len(j.Written) == len(other.Written)
+}
+
+// ifRange is the validator for If-Range. Only a strong ETag may be used
+// there; servers answer a weak one with the whole file.
+func (j *journal) ifRange() string {
+	if j.ETag != "" && !strings.HasPrefix(j.ETag, "W/") {
+		return j.ETag
+	}
+	return j.LastModified
+}
+
+func (j *journal) chunkBounds(i int) (start, end int64) {
+	start = int64(i) * j.ChunkSize
+	return start, min(start+j.ChunkSize, j.Size) - 1
+}
+
+func (j *journal) done() int64 {
+	var n int64
+	for _, w := range j.Written {
+		n += w
+	}
+	return n
+}
+
+func loadJournal(path string) (*journal, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var j journal
+	if err := json.Unmarshal(b, &j); err != nil {
+		// a torn or foreign journal only costs a fresh start
+		return nil, nil
+	}
+	return &j, nil
+}
+
+func (j *journal) save(path string) error {
+	return fsutil.WriteFileAtomic(path, 0o644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(j)
+	})
+}
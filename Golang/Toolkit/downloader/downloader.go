@@ -0,0 +1,187 @@
+// Package downloader fetches many URLs with a bounded worker pool, per-URL
+// timeouts and retries, and reports each outcome as a typed Result.
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one URL
+type Result struct {
+	URL        string
+	StatusCode int // zero when no response arrived
+	Bytes      int64
+	Duration   time.Duration // across all attempts
+	Attempts   int           // requests actually sent, zero if ctx was done first
+	Path       string        // where the Sink stored the body, if anywhere
+	Err        error
+}
+
+// OK reports whether the URL answered 2xx and its body was consumed
+func (r Result) OK() bool { return r.Err == nil }
+
+// StatusError is the Result error for a non-2xx response
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Sink consumes the body of a 2xx response. It returns the bytes read from
+// the body and, if it stored them, the path.
+type Sink func(ctx context.Context, url string, res *http.Response) (n int64, path string, err error)
+
+// Discard is the default Sink; it only counts the bytes
+func Discard(_ context.Context, _ string, res *http.Response) (int64, string, error) {
+	n, err := io.Copy(io.Discard, res.Body)
+	return n, "", err
+}
+
+// Downloader runs fetches on a fixed number of workers
+type Downloader struct {
+	client     *http.Client
+	workers    int
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	sink       Sink
+}
+
+// Option configures a Downloader
+type Option func(*Downloader)
+
+// WithClient sets the HTTP client; its own Timeout still applies per attempt
+func WithClient(c *http.Client) Option { return func(d *Downloader) { d.client = c } }
+
+// WithWorkers bounds how many URLs are fetched at once
+func WithWorkers(n int) Option { return func(d *Downloader) { d.workers = n } }
+
+// WithTimeout bounds each attempt, including reading the body
+func WithTimeout(t time.Duration) Option { return func(d *Downloader) { d.timeout = t } }
+
+// WithRetries retries connection errors, 429 and 5xx up to n times, waiting delay, 2*delay, ...
+func WithRetries(n int, delay time.Duration) Option {
+	return func(d *Downloader) { d.retries, d.retryDelay = n, delay }
+}
+
+// WithSink sets what happens to successful bodies, e.g. saving them to disk
+func WithSink(s Sink) Option { return func(d *Downloader) { d.sink = s } }
+
+// New returns a Downloader with 4 workers, a 30 second timeout and no retries unless configured
+func New(opts ...Option) *Downloader {
+	d := &Downloader{
+		client:  http.DefaultClient,
+		workers: 4,
+		timeout: 30 * time.Second,
+		sink:    Discard,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.workers < 1 {
+		d.workers = 1
+	}
+	return d
+}
+
+// Stream fetches urls and sends one Result per URL, in completion order. The
+// channel is closed when every URL is done. After ctx is cancelled the
+// remaining URLs are reported with ctx.Err() without being fetched. The caller
+// must drain the channel.
+func (d *Downloader) Stream(ctx context.Context, urls []string) <-chan Result {
+	results := make(chan Result, d.workers)
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	wg.Add(d.workers)
+	for w := 0; w < d.workers; w++ {
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				results <- d.Fetch(ctx, u)
+			}
+		}()
+	}
+
+	go func() {
+		for _, u := range urls {
+			jobs <- u
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// Run fetches urls and returns the aggregated Summary
+func (d *Downloader) Run(ctx context.Context, urls []string) Summary {
+	return Summarize(d.Stream(ctx, urls))
+}
+
+// Fetch downloads a single URL with the configured retries
+func (d *Downloader) Fetch(ctx context.Context, url string) (res Result) {
+	res.URL = url
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			res.Err = err
+			return res
+		}
+		res.Attempts++
+
+		retry := false
+		res.StatusCode, res.Bytes, res.Path, retry, res.Err = d.attempt(ctx, url)
+		if res.Err == nil || !retry || res.Attempts > d.retries {
+			return res
+		}
+
+		wait := d.retryDelay << min(res.Attempts-1, 20)
+		select {
+		case <-ctx.Done():
+			res.Err = ctx.Err()
+			return res
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt does one GET and reports whether a failure is worth retrying
+func (d *Downloader) attempt(ctx context.Context, url string) (status int, n int64, path string, retry bool, err error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, "", false, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, "", true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return resp.StatusCode, 0, "", retry, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	n, path, err = d.sink(ctx, url, resp)
+	if err != nil {
+		return resp.StatusCode, n, "", ctx.Err() != nil, fmt.Errorf("save body: %w", err)
+	}
+	return resp.StatusCode, n, path, false, nil
+}
@@ -0,0 +1,192 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamRetriesAndSummarizes(t *testing.T) {
+	var flaky atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("hello"))
+		case "/flaky":
+			if flaky.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("finally"))
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d := New(WithWorkers(2), WithTimeout(100*time.Millisecond), WithRetries(2, time.Millisecond))
+	results := map[string]Result{}
+	for r := range d.Stream(context.Background(), []string{srv.URL + "/ok", srv.URL + "/flaky", srv.URL + "/slow", srv.URL + "/missing"}) {
+		results[strings.TrimPrefix(r.URL, srv.URL)] = r
+	}
+
+	if r := results["/ok"]; !r.OK() || r.Bytes != 5 || r.Attempts != 1 {
+		t.Errorf("/ok: %+v", r)
+	}
+	if r := results["/flaky"]; !r.OK() || r.Attempts != 3 || r.Bytes != 7 {
+		t.Errorf("/flaky: %+v", r)
+	}
+	if r := results["/slow"]; r.OK() || !errors.Is(r.Err, context.DeadlineExceeded) || r.Attempts != 3 || r.Duration < 300*time.Millisecond {
+		t.Errorf("/slow: %+v", r)
+	}
+	var statusErr *StatusError
+	if r := results["/missing"]; !errors.As(r.Err, &statusErr) || statusErr.StatusCode != 404 || r.Attempts != 1 {
+		t.Errorf("/missing should fail once without retry: %+v", r)
+	}
+}
+
+func TestWorkerPoolIsBounded(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	urls := make([]string, 40)
+	for i := range urls {
+		urls[i] = srv.URL
+	}
+	summary := New(WithWorkers(3)).Run(context.Background(), urls)
+	if summary.Total != 40 || summary.Succeeded != 40 {
+		t.Fatalf("summary: %s", summary)
+	}
+	if peak.Load() > 3 {
+		t.Fatalf("expected at most 3 requests in flight, saw %d", peak.Load())
+	}
+}
+
+func TestCancelReportsRemainingURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	urls := make([]string, 20)
+	for i := range urls {
+		urls[i] = srv.URL
+	}
+
+	var summary Summary
+	for r := range New(WithWorkers(2)).Stream(ctx, urls) {
+		summary.Add(r)
+		cancel()
+	}
+	if summary.Total != 20 || summary.Failed == 0 {
+		t.Fatalf("every URL must be reported, cancelled ones as failures: %s", summary)
+	}
+	for _, r := range summary.Failures {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Fatalf("unexpected failure: %+v", r)
+		}
+	}
+	if r := New().Fetch(ctx, srv.URL); r.Attempts != 0 || !errors.Is(r.Err, context.Canceled) {
+		t.Fatalf("a fetch that never sent counted an attempt: %+v", r)
+	}
+}
+
+func TestFileNameIsSafeAndDistinct(t *testing.T) {
+	urls := []string{
+		"https://www.google.com",
+		"https://www.google.com/",
+		"https://www.google.com/?q=1",
+		"https://www.google.com/?q=2",
+		"https://example.com/../../etc/passwd",
+		"https://example.com/A/B",
+		"https://example.com/a/b",
+		"not a url/..",
+	}
+	seen := map[string]string{}
+	for _, u := range urls {
+		name := FileName(u, ".txt")
+		if name != FileName(u, ".txt") {
+			t.Fatalf("%s: name is not deterministic", u)
+		}
+		if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || strings.HasPrefix(name, ".") {
+			t.Errorf("%s: unsafe name %q", u, name)
+		}
+		if prev, ok := seen[name]; ok {
+			t.Errorf("%s and %s both map to %q", prev, u, name)
+		}
+		seen[name] = u
+	}
+	if name := FileName("https://www.google.com", ".txt"); !strings.HasPrefix(name, "www.google.com-") {
+		t.Errorf("expected a readable host prefix, got %q", name)
+	}
+}
+
+func TestSaverWritesAtomically(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<h1>hello</h1>"))
+		case "/broken":
+			// promise more than is sent so the body read fails midway
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("partial"))
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	saver := Saver{Dir: dir, ExtensionFromContentType: true, Gzip: true}
+	d := New(WithSink(saver.Sink))
+
+	r := d.Fetch(context.Background(), srv.URL+"/page")
+	if !r.OK() || r.Bytes != 14 || !strings.HasSuffix(r.Path, ".html.gz") {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	f, err := os.Open(r.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != "<h1>hello</h1>" {
+		t.Fatalf("saved body %q", body)
+	}
+
+	if r := d.Fetch(context.Background(), srv.URL+"/broken"); r.OK() || r.Path != "" {
+		t.Fatalf("a truncated body must fail: %+v", r)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("failed download left files behind: %v", entries)
+	}
+}
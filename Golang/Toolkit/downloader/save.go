@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+// maxSlug keeps file names well under the usual 255 byte limit
+const maxSlug = 100
+
+// extensions maps common content types to file extensions; anything else gets DefaultExtension
+var extensions = map[string]string{
+	"text/html":              ".html",
+	"text/plain":             ".txt",
+	"text/css":               ".css",
+	"text/csv":               ".csv",
+	"text/xml":               ".xml",
+	"application/xml":        ".xml",
+	"application/json":       ".json",
+	"application/javascript": ".js",
+	"text/javascript":        ".js",
+	"application/pdf":        ".pdf",
+	"image/png":              ".png",
+	"image/jpeg":             ".jpg",
+	"image/gif":              ".gif",
+	"image/svg+xml":          ".svg",
+	"image/webp":             ".webp",
+}
+
+// DefaultExtension matches what checkAndSaveBody always used
+const DefaultExtension = ".txt"
+
+// Saver is a Sink that writes each body to its own file in Dir
+type Saver struct {
+	Dir string
+	// ExtensionFromContentType picks .html, .json, ... from the response instead of DefaultExtension
+	ExtensionFromContentType bool
+	// Gzip compresses the file and appends .gz to its name
+	Gzip bool
+}
+
+// FileName returns a flat, deterministic name for rawURL: a readable slug of the
+// host and path followed by a hash of the whole URL, so URLs that differ only
+// in their query, case or punctuation never collide. Nothing in the URL can
+// add directories or "..".
+//
+//	https://www.google.com            -> www.google.com-<hash>.txt
+//	https://example.com/a/b?page=2    -> example.com-a-b-<hash>.txt
+func FileName(rawURL, ext string) string {
+	slug := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		slug = u.Host + u.Path
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return slugify(slug) + "-" + hex.EncodeToString(sum[:6]) + ext
+}
+
+// Path returns where the body of rawURL will be written for the given content type
+func (s Saver) Path(rawURL, contentType string) string {
+	ext := DefaultExtension
+	if s.ExtensionFromContentType {
+		ext = extensionFor(contentType)
+	}
+	if s.Gzip {
+		ext += ".gz"
+	}
+	return filepath.Join(s.Dir, FileName(rawURL, ext))
+}
+
+// Sink implements Sink
+func (s Saver) Sink(_ context.Context, rawURL string, res *http.Response) (int64, string, error) {
+	path := s.Path(rawURL, res.Header.Get("Content-Type"))
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return 0, "", err
+	}
+
+	counter := &countingReader{r: res.Body}
+	err := fsutil.WriteFileAtomic(path, 0o644, func(w io.Writer) error {
+		if !s.Gzip {
+			_, err := io.Copy(w, counter)
+			return err
+		}
+		zw := gzip.NewWriter(w)
+		if _, err := io.Copy(zw, counter); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+	if err != nil {
+		return counter.n, "", err
+	}
+	return counter.n, path, nil
+}
+
+func extensionFor(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return DefaultExtension
+	}
+	if ext, ok := extensions[mediaType]; ok {
+		return ext
+	}
+	return DefaultExtension
+}
+
+// slugify keeps lowercase letters, digits, dots and dashes and turns every other run into one dash
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	out := strings.Trim(b.String(), "-.")
+	// a run of dots could still spell ".."
+	for strings.Contains(out, "..") {
+		out = strings.ReplaceAll(out, "..", ".")
+	}
+	if len(out) > maxSlug {
+		out = strings.TrimRight(out[:maxSlug], "-.")
+	}
+	if out == "" {
+		out = "download"
+	}
+	return out
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
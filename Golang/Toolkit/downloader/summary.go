@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary aggregates a stream of Results
+type Summary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	Bytes     int64
+	Slowest   time.Duration
+	Statuses  map[int]int // status code -> count; 0 counts URLs that got no response
+	Failures  []Result
+	Elapsed   time.Duration // wall time from the first to the last result
+}
+
+// Summarize drains results into a Summary
+func Summarize(results <-chan Result) Summary {
+	s := Summary{Statuses: map[int]int{}}
+	start := time.Now()
+	for r := range results {
+		s.Add(r)
+	}
+	s.Elapsed = time.Since(start)
+	return s
+}
+
+// Add records one Result
+func (s *Summary) Add(r Result) {
+	if s.Statuses == nil {
+		s.Statuses = map[int]int{}
+	}
+	s.Total++
+	s.Statuses[r.StatusCode]++
+	s.Bytes += r.Bytes
+	if r.Duration > s.Slowest {
+		s.Slowest = r.Duration
+	}
+	if r.OK() {
+		s.Succeeded++
+	} else {
+		s.Failed++
+		s.Failures = append(s.Failures, r)
+	}
+}
+
+func (s Summary) String() string {
+	codes := make([]int, 0, len(s.Statuses))
+	for code := range s.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%d:%d", code, s.Statuses[code])
+	}
+	return fmt.Sprintf("%d URLs, %d ok, %d failed, %d bytes in %s (slowest %s) statuses [%s]",
+		s.Total, s.Succeeded, s.Failed, s.Bytes, s.Elapsed.Round(time.Millisecond),
+		s.Slowest.Round(time.Millisecond), strings.Join(parts, " "))
+}
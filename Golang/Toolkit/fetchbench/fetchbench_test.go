@@ -0,0 +1,100 @@
+package fetchbench
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func strategies() []Strategy {
+	return []Strategy{Sequential(), Unbounded(), WorkerPool(8), ErrGroup(8)}
+}
+
+func TestStrategiesFetchEveryURL(t *testing.T) {
+	srv := NewServer(ServerConfig{Latency: 5 * time.Millisecond, BodySize: 100})
+	defer srv.Close()
+	urls := URLs(srv, 40)
+
+	reports := map[string]Report{}
+	for _, s := range strategies() {
+		r := Measure(context.Background(), s, srv.Client(), urls)
+		if r.Err != nil || r.Requests != 40 || r.Failures != 0 {
+			t.Errorf("%s: %+v", s.Name, r)
+		}
+		if r.P50 < 5*time.Millisecond || r.P99 < r.P50 {
+			t.Errorf("%s: implausible latencies p50=%s p99=%s", s.Name, r.P50, r.P99)
+		}
+		reports[s.Name] = r
+	}
+
+	// timing comparisons between strategies belong in BenchmarkStrategies;
+	// the bound here holds however loaded the machine is. Each request in
+	// flight is at most five goroutines: a worker, a dial, the client
+	// connection's read and write loops and the test server's connection.
+	if pool := reports["pool-8"]; pool.PeakGoroutines > 8*5+8 {
+		t.Errorf("pool-8 peaked at %d goroutines", pool.PeakGoroutines)
+	}
+	t.Logf("peak goroutines: unbounded %d, pool-8 %d", reports["unbounded"].PeakGoroutines, reports["pool-8"].PeakGoroutines)
+
+	var table strings.Builder
+	if err := WriteTable(&table, []Report{reports["sequential"], reports["pool-8"]}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "pool-8") || strings.Count(table.String(), "\n") != 3 {
+		t.Fatalf("table:\n%s", table.String())
+	}
+}
+
+func TestFailureInjection(t *testing.T) {
+	srv := NewServer(ServerConfig{FailureRate: 0.3, Seed: 1})
+	defer srv.Close()
+	urls := URLs(srv, 100)
+
+	pool := Measure(context.Background(), WorkerPool(4), srv.Client(), urls)
+	if pool.Requests != 100 || pool.Failures < 15 || pool.Failures > 45 {
+		t.Fatalf("expected about 30 failures out of 100: %+v", pool)
+	}
+
+	// errgroup stops at the first failure
+	eg := Measure(context.Background(), ErrGroup(4), srv.Client(), urls)
+	if eg.Err == nil || eg.Requests == 100 {
+		t.Fatalf("errgroup should give up early: %+v", eg)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i))
+	}
+	if percentile(d, 50) != 50 || percentile(d, 99) != 99 || percentile(d[:1], 99) != 1 || percentile(nil, 50) != 0 {
+		t.Fatal("nearest rank percentiles are off")
+	}
+}
+
+// go test -bench . -benchmem ./fetchbench
+func BenchmarkStrategies(b *testing.B) {
+	srv := NewServer(ServerConfig{Latency: time.Millisecond, Jitter: time.Millisecond, FailureRate: 0.01, BodySize: 2048})
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 256}}
+	defer client.CloseIdleConnections()
+	urls := URLs(srv, 200)
+
+	for _, s := range []Strategy{Sequential(), Unbounded(), WorkerPool(8), WorkerPool(32), ErrGroup(32)} {
+		b.Run(s.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			var p50, p99 time.Duration
+			var peak int
+			for i := 0; i < b.N; i++ {
+				r := Measure(context.Background(), s, client, urls)
+				p50, p99, peak = p50+r.P50, p99+r.P99, max(peak, r.PeakGoroutines)
+			}
+			b.ReportMetric(float64(p50.Microseconds())/float64(b.N), "p50-µs")
+			b.ReportMetric(float64(p99.Microseconds())/float64(b.N), "p99-µs")
+			b.ReportMetric(float64(peak), "goroutines")
+			b.ReportMetric(float64(len(urls)*b.N)/b.Elapsed().Seconds(), "req/s")
+		})
+	}
+}
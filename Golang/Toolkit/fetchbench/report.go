@@ -0,0 +1,130 @@
+package fetchbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is what one Measure run observed. Allocations are process wide, so
+// when the server runs in the same process they include its share too.
+type Report struct {
+	Strategy   string
+	URLs       int
+	Requests   int // may be below URLs when a strategy gives up early
+	Failures   int
+	Elapsed    time.Duration
+	Throughput float64 // successful requests per second
+	P50, P99   time.Duration
+	Max        time.Duration
+	// PeakGoroutines is the highest goroutine count above the starting baseline
+	PeakGoroutines int
+	Allocs         uint64
+	AllocBytes     uint64
+	Err            error // what the strategy returned
+}
+
+// Measure runs s once over urls
+func Measure(ctx context.Context, s Strategy, client *http.Client, urls []string) Report {
+	var mu sync.Mutex
+	samples := make([]Sample, 0, len(urls))
+	record := func(sm Sample) {
+		mu.Lock()
+		samples = append(samples, sm)
+		mu.Unlock()
+	}
+
+	// settle leftovers from earlier runs so the baseline is honest
+	runtime.GC()
+	baseline := runtime.NumGoroutine()
+	peak := sampleGoroutines()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	err := s.Run(ctx, client, urls, record)
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	// the sampler itself is one goroutine above the baseline
+	peakCount := peak() - baseline - 1
+
+	r := Report{
+		Strategy:       s.Name,
+		URLs:           len(urls),
+		Requests:       len(samples),
+		Elapsed:        elapsed,
+		PeakGoroutines: max(peakCount, 0),
+		Allocs:         after.Mallocs - before.Mallocs,
+		AllocBytes:     after.TotalAlloc - before.TotalAlloc,
+		Err:            err,
+	}
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, sm := range samples {
+		if sm.Err != nil {
+			r.Failures++
+		}
+		latencies = append(latencies, sm.Latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50, r.P99 = percentile(latencies, 50), percentile(latencies, 99)
+	if len(latencies) > 0 {
+		r.Max = latencies[len(latencies)-1]
+	}
+	if elapsed > 0 {
+		r.Throughput = float64(r.Requests-r.Failures) / elapsed.Seconds()
+	}
+	return r
+}
+
+// sampleGoroutines polls runtime.NumGoroutine until the returned func is called, which reports the peak
+func sampleGoroutines() func() int {
+	stop := make(chan struct{})
+	done := make(chan int)
+	go func() {
+		peak := runtime.NumGoroutine()
+		t := time.NewTicker(500 * time.Microsecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				done <- max(peak, runtime.NumGoroutine())
+				return
+			case <-t.C:
+				peak = max(peak, runtime.NumGoroutine())
+			}
+		}
+	}()
+	return func() int {
+		close(stop)
+		return <-done
+	}
+}
+
+// percentile uses the nearest rank method on sorted values
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteTable prints reports as an aligned table
+func WriteTable(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "strategy\trequests\tfailed\telapsed\treq/s\tp50\tp99\tmax\tgoroutines\tallocs\tKiB\t")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d\t%s\t%.0f\t%s\t%s\t%s\t%d\t%d\t%d\t\n",
+			r.Strategy, r.Requests, r.URLs, r.Failures, r.Elapsed.Round(time.Millisecond), r.Throughput,
+			r.P50.Round(time.Microsecond*100), r.P99.Round(time.Microsecond*100), r.Max.Round(time.Microsecond*100),
+			r.PeakGoroutines, r.Allocs, r.AllocBytes>>10)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+// Package fetchbench compares ways of fetching many URLs — one after another,
+// a goroutine per URL, a worker pool and an errgroup — against a local server
+// with injected latency and failures.
+package fetchbench
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig shapes the fake upstream
+type ServerConfig struct {
+	Latency     time.Duration // base delay before every response
+	Jitter      time.Duration // extra uniformly random delay up to this
+	FailureRate float64       // fraction of requests answered with 503
+	BodySize    int
+	Seed        int64 // makes failures and jitter repeatable
+}
+
+// NewServer starts an httptest server that answers every path after the
+// configured delay. It stops waiting when the client goes away.
+func NewServer(cfg ServerConfig) *httptest.Server {
+	body := []byte(strings.Repeat("x", cfg.BodySize))
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delay := cfg.Latency
+		if cfg.Jitter > 0 {
+			delay += time.Duration(rng.Int63n(int64(cfg.Jitter)))
+		}
+		fail := rng.Float64() < cfg.FailureRate
+		mu.Unlock()
+
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			defer t.Stop()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-t.C:
+			}
+		}
+		if fail {
+			http.Error(w, "injected failure", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+}
+
+// URLs returns n distinct URLs on srv
+func URLs(srv *httptest.Server, n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/page/%d", srv.URL, i)
+	}
+	return urls
+}
@@ -0,0 +1,114 @@
+package fetchbench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/workpool"
+)
+
+// Sample is one request as the strategy saw it
+type Sample struct {
+	URL     string
+	Latency time.Duration
+	Bytes   int64
+	Err     error
+}
+
+// Strategy fetches every URL and calls record once per request it made.
+// record is safe for concurrent use.
+type Strategy struct {
+	Name string
+	Run  func(ctx context.Context, client *http.Client, urls []string, record func(Sample)) error
+}
+
+// Sequential is URL_TO_FILE.WithoutGoRoutines.go: one request at a time
+func Sequential() Strategy {
+	return Strategy{Name: "sequential", Run: func(ctx context.Context, client *http.Client, urls []string, record func(Sample)) error {
+		for _, u := range urls {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			record(fetch(ctx, client, u))
+		}
+		return nil
+	}}
+}
+
+// Unbounded is URL_TO_FILES.GoRoutineWait.go: a goroutine per URL and a WaitGroup
+func Unbounded() Strategy {
+	return Strategy{Name: "unbounded", Run: func(ctx context.Context, client *http.Client, urls []string, record func(Sample)) error {
+		var wg sync.WaitGroup
+		for _, u := range urls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				record(fetch(ctx, client, u))
+			}()
+		}
+		wg.Wait()
+		return nil
+	}}
+}
+
+// WorkerPool runs n long-lived workers pulling from a channel
+func WorkerPool(n int) Strategy {
+	return Strategy{Name: fmt.Sprintf("pool-%d", n), Run: func(ctx context.Context, client *http.Client, urls []string, record func(Sample)) error {
+		workpool.Run(ctx, len(urls), n, func(ctx context.Context, i int) {
+			if ctx.Err() == nil {
+				record(fetch(ctx, client, urls[i]))
+			}
+		})
+		return ctx.Err()
+	}}
+}
+
+// ErrGroup starts a goroutine per URL with at most limit running. The first
+// failure cancels the rest, so with injected failures it makes fewer requests
+// and returns that error.
+func ErrGroup(limit int) Strategy {
+	return Strategy{Name: fmt.Sprintf("errgroup-%d", limit), Run: func(ctx context.Context, client *http.Client, urls []string, record func(Sample)) error {
+		g, ctx := errgroup.WithContext(ctx)
+		g.SetLimit(limit)
+		for _, u := range urls {
+			g.Go(func() error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				s := fetch(ctx, client, u)
+				record(s)
+				return s.Err
+			})
+		}
+		return g.Wait()
+	}}
+}
+
+func fetch(ctx context.Context, client *http.Client, url string) (s Sample) {
+	s.URL = url
+	start := time.Now()
+	defer func() { s.Latency = time.Since(start) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	defer res.Body.Close()
+	s.Bytes, s.Err = io.Copy(io.Discard, res.Body)
+	if s.Err == nil && res.StatusCode != http.StatusOK {
+		s.Err = fmt.Errorf("%s: status %d", url, res.StatusCode)
+	}
+	return s
+}
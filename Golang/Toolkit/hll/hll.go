@@ -0,0 +1,135 @@
+// Package hll estimates how many distinct items were seen with a HyperLogLog
+// sketch: a fixed 2^precision bytes no matter how many items, about
+// 1.04/sqrt(2^precision) relative error, and sketches of the same precision
+// merge into the sketch of the union.
+package hll
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	MinPrecision = 4
+	MaxPrecision = 18
+)
+
+// ErrPrecision is returned when merging sketches of different precision
+var ErrPrecision = errors.New("hll: precision mismatch")
+
+// Sketch is a HyperLogLog sketch. It is not safe for concurrent use.
+type Sketch struct {
+	p   uint8
+	reg []uint8
+}
+
+// New returns an empty sketch with 2^precision registers
+func New(precision uint8) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("hll: precision %d outside [%d, %d]", precision, MinPrecision, MaxPrecision)
+	}
+	return &Sketch{p: precision, reg: make([]uint8, 1<<precision)}, nil
+}
+
+// Precision returns the precision the sketch was made with
+func (s *Sketch) Precision() uint8 { return s.p }
+
+// StdError is the expected relative standard error of Estimate
+func (s *Sketch) StdError() float64 { return 1.04 / math.Sqrt(float64(len(s.reg))) }
+
+// Add records an item
+func (s *Sketch) Add(item []byte) {
+	h := fnv.New64a()
+	h.Write(item)
+	s.addHash(mix(h.Sum64()))
+}
+
+// AddString records an item
+func (s *Sketch) AddString(item string) { s.Add([]byte(item)) }
+
+// mix is the splitmix64 finalizer; FNV alone leaves the top bits, which pick
+// the register, poorly spread for short similar keys
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+func (s *Sketch) addHash(h uint64) {
+	idx := h >> (64 - s.p)
+	// the sentinel bit caps rho when every remaining bit is zero
+	w := h<<s.p | 1<<(s.p-1)
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if rho > s.reg[idx] {
+		s.reg[idx] = rho
+	}
+}
+
+// Estimate returns the approximate number of distinct items added
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.reg))
+	var sum float64
+	zeros := 0
+	for _, r := range s.reg {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := alpha(len(s.reg)) * m * m / sum
+	// linear counting is far more accurate while many registers are empty
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// Merge folds o into s, so s estimates the union of both
+func (s *Sketch) Merge(o *Sketch) error {
+	if s.p != o.p {
+		return ErrPrecision
+	}
+	for i, r := range o.reg {
+		if r > s.reg[i] {
+			s.reg[i] = r
+		}
+	}
+	return nil
+}
+
+// Clone returns an independent copy
+func (s *Sketch) Clone() *Sketch {
+	return &Sketch{p: s.p, reg: append([]uint8(nil), s.reg...)}
+}
+
+// MarshalBinary encodes the precision followed by the registers
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	return append([]byte{s.p}, s.reg...), nil
+}
+
+// UnmarshalBinary replaces s with an encoded sketch
+func (s *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 || b[0] < MinPrecision || b[0] > MaxPrecision || len(b)-1 != 1<<b[0] {
+		return errors.New("hll: malformed sketch")
+	}
+	s.p, s.reg = b[0], append([]uint8(nil), b[1:]...)
+	return nil
+}
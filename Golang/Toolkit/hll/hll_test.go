@@ -0,0 +1,92 @@
+package hll
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func mustNew(t *testing.T, p uint8) *Sketch {
+	t.Helper()
+	s, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// checkEstimate checks the estimate against the standard error; four sigma keeps the
+// test deterministic in practice while still catching a broken estimator
+func checkEstimate(t *testing.T, s *Sketch, want int) {
+	t.Helper()
+	got := s.Estimate()
+	rel := math.Abs(float64(got)-float64(want)) / float64(want)
+	if bound := 4 * s.StdError(); rel > bound {
+		t.Errorf("p=%d n=%d: estimate %d is off by %.2f%%, bound %.2f%%", s.Precision(), want, got, rel*100, bound*100)
+	}
+}
+
+func TestErrorBound(t *testing.T) {
+	for _, p := range []uint8{10, 12, 14} {
+		for _, n := range []int{10, 100, 1_000, 10_000, 200_000} {
+			s := mustNew(t, p)
+			for i := range n {
+				s.AddString(fmt.Sprintf("visitor-%d", i))
+			}
+			checkEstimate(t, s, n)
+		}
+	}
+}
+
+func TestDuplicatesDoNotCount(t *testing.T) {
+	s := mustNew(t, 12)
+	for range 50 {
+		for i := range 1000 {
+			s.AddString(fmt.Sprintf("v%d", i))
+		}
+	}
+	checkEstimate(t, s, 1000)
+}
+
+func TestMergeIsUnion(t *testing.T) {
+	a, b := mustNew(t, 12), mustNew(t, 12)
+	// 0..5999 and 4000..9999 overlap by 2000
+	for i := range 6000 {
+		a.AddString(fmt.Sprint(i))
+	}
+	for i := 4000; i < 10_000; i++ {
+		b.AddString(fmt.Sprint(i))
+	}
+	u := a.Clone()
+	if err := u.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkEstimate(t, u, 10_000)
+	checkEstimate(t, a, 6000) // Clone left a alone
+
+	if err := a.Merge(mustNew(t, 10)); !errors.Is(err, ErrPrecision) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	s := mustNew(t, 8)
+	for i := range 500 {
+		s.AddString(fmt.Sprint(i))
+	}
+	b, _ := s.MarshalBinary()
+	var r Sketch
+	if err := r.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if r.Estimate() != s.Estimate() || r.Precision() != 8 {
+		t.Fatalf("%d != %d", r.Estimate(), s.Estimate())
+	}
+	if err := r.UnmarshalBinary(b[:10]); err == nil {
+		t.Fatal("truncated sketch accepted")
+	}
+	if _, err := New(3); err == nil {
+		t.Fatal("precision 3 accepted")
+	}
+}
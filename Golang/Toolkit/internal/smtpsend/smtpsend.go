@@ -0,0 +1,140 @@
+// Package smtpsend delivers one message over a fresh net/smtp connection
+// bounded by a context. mailqueue and the monitor's email alerts both use it.
+package smtpsend
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// TLSPolicy says what to do about STARTTLS
+type TLSPolicy int
+
+const (
+	// TLSOpportunistic upgrades when the server offers STARTTLS
+	TLSOpportunistic TLSPolicy = iota
+	// TLSRequired refuses to send over a connection that could not be upgraded
+	TLSRequired
+	// TLSDisabled never upgrades
+	TLSDisabled
+)
+
+var (
+	// ErrNoStartTLS is returned under TLSRequired when the server does not offer STARTTLS
+	ErrNoStartTLS = errors.New("smtp: server does not offer STARTTLS")
+	// ErrNoAuth is returned when Auth is set and the server does not offer AUTH.
+	// Sending without the credentials the caller asked for would only fail
+	// later, or worse, be relayed unauthenticated.
+	ErrNoAuth = errors.New("smtp: server does not offer AUTH")
+)
+
+// Config describes the server and how to talk to it
+type Config struct {
+	Addr      string // host:port
+	Host      string // name the certificate is checked against, default the host of Addr
+	HelloName string // default localhost
+	TLS       *tls.Config
+	StartTLS  TLSPolicy
+	Auth      smtp.Auth
+	Timeout   time.Duration // whole conversation, default 30s
+}
+
+// Send delivers msg from from to every address in to. ctx cancellation
+// closes the connection. Errors from the server wrap a *textproto.Error.
+func Send(ctx context.Context, cfg Config, from string, to []string, msg []byte) (err error) {
+	host := cfg.Host
+	if host == "" {
+		if host, _, err = net.SplitHostPort(cfg.Addr); err != nil {
+			return err
+		}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support; a deadline in the past unblocks it
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	step := func(name string, err error) error {
+		if err != nil {
+			return fmt.Errorf("smtp %s: %w", name, err)
+		}
+		return nil
+	}
+
+	hello := cfg.HelloName
+	if hello == "" {
+		hello = "localhost"
+	}
+	if err := step("HELO", c.Hello(hello)); err != nil {
+		return err
+	}
+	if cfg.StartTLS != TLSDisabled {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			tc := &tls.Config{}
+			if cfg.TLS != nil {
+				tc = cfg.TLS.Clone()
+			}
+			if tc.ServerName == "" {
+				tc.ServerName = host
+			}
+			if err := step("STARTTLS", c.StartTLS(tc)); err != nil {
+				return err
+			}
+		} else if cfg.StartTLS == TLSRequired {
+			return ErrNoStartTLS
+		}
+	}
+	if cfg.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return ErrNoAuth
+		}
+		if err := step("AUTH", c.Auth(cfg.Auth)); err != nil {
+			return err
+		}
+	}
+	if err := step("MAIL", c.Mail(from)); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := step("RCPT", c.Rcpt(addr)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err := step("DATA", err); err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return step("DATA", err)
+	}
+	if err := step("DATA", w.Close()); err != nil {
+		return err
+	}
+	// the message is accepted; a failed QUIT must not cause a second copy
+	c.Quit()
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package smtptest is a minimal in-process SMTP server for tests. It speaks
+// enough of RFC 5321 for net/smtp, optionally with STARTTLS and AUTH PLAIN,
+// and records every message it accepts.
+package smtptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one accepted mail
+type Message struct {
+	From string
+	To   []string
+	Data string // headers and body with CRLF line endings, without the final "."
+	TLS  bool   // sent after STARTTLS
+	User string // who authenticated, if anyone
+}
+
+// Server accepts SMTP connections on a loopback port
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+	// RejectRcpt makes RCPT TO fail with 550 for addresses it returns true for
+	RejectRcpt func(addr string) bool
+	// TempFailRcpt makes RCPT TO fail with 451, which senders should retry
+	TempFailRcpt func(addr string) bool
+	// TLS, when set, advertises STARTTLS; see Certificate
+	TLS *tls.Config
+	// Auth, when set, advertises AUTH PLAIN and refuses MAIL until a client
+	// authenticates with credentials it accepts
+	Auth func(user, pass string) bool
+}
+
+// NewServer starts a Server on 127.0.0.1 with a random port
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is host:port for net/smtp
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// Messages returns the accepted messages in arrival order
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops accepting and waits for open sessions to end
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 smtptest ready")
+	var msg Message
+	secure, user := false, ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-smtptest")
+			if s.TLS != nil && !secure {
+				reply("250-STARTTLS")
+			}
+			if s.Auth != nil {
+				reply("250-AUTH PLAIN")
+			}
+			reply("250 8BITMIME")
+		case "STARTTLS":
+			if s.TLS == nil || secure {
+				reply("502 STARTTLS not available")
+				continue
+			}
+			reply("220 go ahead")
+			tc := tls.Server(conn, s.TLS)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			// RFC 3207: forget everything from before the handshake
+			conn, r, secure, user, msg = tc, bufio.NewReader(tc), true, "", Message{}
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if s.Auth == nil || !strings.EqualFold(mech, "PLAIN") {
+				reply("504 unsupported mechanism")
+				continue
+			}
+			if initial == "" {
+				reply("334 ")
+				if initial, err = r.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			u, p, ok := plain(strings.TrimSpace(initial))
+			if !ok || !s.Auth(u, p) {
+				reply("535 authentication failed")
+				continue
+			}
+			user = u
+			reply("235 authenticated")
+		case "HELO":
+			reply("250 smtptest")
+		case "MAIL":
+			if s.Auth != nil && user == "" {
+				reply("530 authentication required")
+				continue
+			}
+			from, ok := address(arg)
+			if !ok {
+				reply("501 bad path %s", arg)
+				continue
+			}
+			msg = Message{From: from, TLS: secure, User: user}
+			reply("250 OK")
+		case "RCPT":
+			to, ok := address(arg)
+			if !ok {
+				reply("501 bad path %s", arg)
+				continue
+			}
+			if s.RejectRcpt != nil && s.RejectRcpt(to) {
+				reply("550 no such user %s", to)
+				continue
+			}
+			if s.TempFailRcpt != nil && s.TempFailRcpt(to) {
+				reply("451 try again later")
+				continue
+			}
+			msg.To = append(msg.To, to)
+			reply("250 OK")
+		case "DATA":
+			if len(msg.To) == 0 {
+				reply("503 need RCPT first")
+				continue
+			}
+			reply("354 end with .")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			msg.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = Message{TLS: secure, User: user}
+			reply("250 OK queued")
+		case "RSET":
+			msg = Message{TLS: secure, User: user}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 %s not implemented", verb)
+		}
+	}
+}
+
+// readData reads a dot-terminated DATA block and undoes dot-stuffing
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// address pulls user@host out of "FROM:<user@host> SIZE=123". A path with a
+// display name in it, "<Ann <ann@host>>", is refused as a real server would.
+func address(arg string) (string, bool) {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return "", false
+	}
+	addr := arg[start+1 : end]
+	return addr, !strings.ContainsAny(addr, "< ")
+}
+
+// plain decodes an AUTH PLAIN response, "authzid\x00user\x00pass" in base64
+func plain(b64 string) (user, pass string, ok bool) {
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(string(b), "\x00")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Certificate makes a throwaway self-signed certificate for 127.0.0.1 and
+// localhost, and a pool that trusts it for the client side
+func Certificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
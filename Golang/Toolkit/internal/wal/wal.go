@@ -0,0 +1,93 @@
+// Package wal is the entry framing the append-only logs share. An entry is
+// a 4 byte length, a 4 byte CRC-32 of the payload and the JSON payload. A
+// crash can leave a torn last entry; Replay stops there. Damage anywhere
+// else is an error, since dropping it would drop every entry after it.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const headerSize = 8
+
+// MaxEntrySize bounds a payload, so a damaged length can't make Replay
+// allocate gigabytes
+const MaxEntrySize = 16 << 20
+
+var (
+	// ErrTooLarge is returned by Append for a payload over MaxEntrySize
+	ErrTooLarge = errors.New("wal: entry too large")
+	// ErrCorrupt is returned by Replay for a damaged entry that isn't the
+	// last thing in the file
+	ErrCorrupt = errors.New("wal: corrupt entry")
+)
+
+// Append adds the entry for v to buf. On error buf is returned unchanged.
+func Append(buf []byte, v any) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return buf, err
+	}
+	if len(payload) > MaxEntrySize {
+		return buf, fmt.Errorf("%w: %d bytes", ErrTooLarge, len(payload))
+	}
+	var hdr [headerSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.ChecksumIEEE(payload))
+	return append(append(buf, hdr[:]...), payload...), nil
+}
+
+// Replay calls fn for every intact entry in f and returns the offset just
+// past the last one, where the next append belongs. An entry cut short by
+// the end of the file, or a bad checksum on the very last entry, is a torn
+// write and ends the log there. A bad entry with more data after it
+// returns ErrCorrupt.
+func Replay[T any](f *os.File, fn func(T)) (int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	var off int64
+	corrupt := func(why string) error {
+		return fmt.Errorf("%w at offset %d in %s: %s", ErrCorrupt, off, f.Name(), why)
+	}
+	for {
+		var hdr [headerSize]byte
+		if _, err := io.ReadFull(r, hdr[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			// clean end or a partial header
+			return off, nil
+		} else if err != nil {
+			return off, err
+		}
+		n := binary.LittleEndian.Uint32(hdr[0:4])
+		if n > MaxEntrySize {
+			return off, corrupt(fmt.Sprintf("length %d", n))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return off, nil
+		} else if err != nil {
+			return off, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(hdr[4:8]) {
+			if _, err := r.Peek(1); err == io.EOF {
+				// the last entry, written in full length but not in content
+				return off, nil
+			}
+			return off, corrupt("checksum mismatch")
+		}
+		var v T
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return off, corrupt(err.Error())
+		}
+		fn(v)
+		off += headerSize + int64(n)
+	}
+}
@@ -0,0 +1,34 @@
+// Package workpool runs indexed jobs on a bounded number of goroutines.
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Run calls fn for every index in [0, n) using at most concurrency goroutines
+// and returns once all calls have finished. Indexes not yet started when ctx is
+// cancelled are still passed to fn, so fn should check ctx.Err() and record it.
+func Run(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int)) {
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(ctx, i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,102 @@
+package jsonplaceholder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/workpool"
+)
+
+// DefaultBulkConcurrency is used when a bulk call is given a concurrency of zero
+const DefaultBulkConcurrency = 8
+
+// BulkResult is the outcome for one item of a bulk call
+type BulkResult[T any] struct {
+	Index int // position in the input
+	Id    int // id the operation targeted, zero for creates
+	Item  T   // item returned by the server, zero for deletes and failures
+	Err   error
+}
+
+// BulkReport holds one result per input item, in input order
+type BulkReport[T any] struct {
+	Results   []BulkResult[T]
+	Succeeded int
+	Failed    int
+}
+
+// Err joins every per-item error, or returns nil when all items succeeded
+func (r BulkReport[T]) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", res.Index, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateMany creates every item, running at most concurrency requests at once
+func (r *Resource[T]) CreateMany(ctx context.Context, items []T, concurrency int) BulkReport[T] {
+	return r.bulk(ctx, len(items), concurrency, func(ctx context.Context, i int) BulkResult[T] {
+		item, err := r.Create(ctx, items[i])
+		return BulkResult[T]{Item: item, Err: err}
+	})
+}
+
+// PatchMany sends the same partial update to every id
+func (r *Resource[T]) PatchMany(ctx context.Context, ids []int, partial any, concurrency int) BulkReport[T] {
+	return r.bulk(ctx, len(ids), concurrency, func(ctx context.Context, i int) BulkResult[T] {
+		item, err := r.Patch(ctx, ids[i], partial)
+		return BulkResult[T]{Id: ids[i], Item: item, Err: err}
+	})
+}
+
+// PatchWhere lists the items matching filter and patches each of them,
+// e.g. mark every todo of user 23 completed. idOf extracts the id of an item.
+func (r *Resource[T]) PatchWhere(ctx context.Context, filter url.Values, partial any, idOf func(T) int, concurrency int) (BulkReport[T], error) {
+	items, err := r.List(ctx, filter)
+	if err != nil {
+		return BulkReport[T]{}, err
+	}
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = idOf(item)
+	}
+	return r.PatchMany(ctx, ids, partial, concurrency), nil
+}
+
+// DeleteMany deletes every id
+func (r *Resource[T]) DeleteMany(ctx context.Context, ids []int, concurrency int) BulkReport[T] {
+	return r.bulk(ctx, len(ids), concurrency, func(ctx context.Context, i int) BulkResult[T] {
+		return BulkResult[T]{Id: ids[i], Err: r.Delete(ctx, ids[i])}
+	})
+}
+
+func (r *Resource[T]) bulk(ctx context.Context, n, concurrency int, op func(ctx context.Context, i int) BulkResult[T]) BulkReport[T] {
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+	report := BulkReport[T]{Results: make([]BulkResult[T], n)}
+	workpool.Run(ctx, n, concurrency, func(ctx context.Context, i int) {
+		var res BulkResult[T]
+		if err := ctx.Err(); err != nil {
+			res.Err = err
+		} else {
+			res = op(ctx, i)
+		}
+		res.Index = i
+		report.Results[i] = res
+	})
+
+	for _, res := range report.Results {
+		if res.Err != nil {
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+	}
+	return report
+}
@@ -0,0 +1,164 @@
+package jsonplaceholder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/pager"
+)
+
+// DefaultBaseURL is the public JSONPlaceholder API
+const DefaultBaseURL = "https://jsonplaceholder.typicode.com"
+
+// Client holds the base URL and the HTTP client used for every resource
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for baseURL. A nil httpClient gets a 10 second timeout.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// Todos returns the /todos resource
+func (c *Client) Todos() *Resource[Todo] { return NewResource[Todo](c, "todos") }
+
+// Posts returns the /posts resource
+func (c *Client) Posts() *Resource[Post] { return NewResource[Post](c, "posts") }
+
+// Users returns the /users resource
+func (c *Client) Users() *Resource[User] { return NewResource[User](c, "users") }
+
+// Comments returns the /comments resource
+func (c *Client) Comments() *Resource[Comment] { return NewResource[Comment](c, "comments") }
+
+// Resource is a typed REST collection such as /todos
+type Resource[T any] struct {
+	client *Client
+	path   string
+}
+
+// NewResource returns a Resource for path, relative to the client base URL
+func NewResource[T any](c *Client, path string) *Resource[T] {
+	return &Resource[T]{client: c, path: strings.Trim(path, "/")}
+}
+
+// Get fetches a single item by id
+func (r *Resource[T]) Get(ctx context.Context, id int) (T, error) {
+	var v T
+	err := r.client.do(ctx, http.MethodGet, r.itemURL(id), nil, &v)
+	return v, err
+}
+
+// List fetches the collection, narrowed by filter (for example userId=1)
+func (r *Resource[T]) List(ctx context.Context, filter url.Values) ([]T, error) {
+	u := r.collectionURL()
+	if len(filter) > 0 {
+		u += "?" + filter.Encode()
+	}
+	var items []T
+	err := r.client.do(ctx, http.MethodGet, u, nil, &items)
+	return items, err
+}
+
+// All walks the whole collection lazily, pageSize items per request, using the
+// json-server _page and _limit parameters; pageSize <= 0 means pager.DefaultPageSize
+func (r *Resource[T]) All(ctx context.Context, filter url.Values, pageSize int, opts ...pager.Option) iter.Seq2[T, error] {
+	u := r.collectionURL()
+	if len(filter) > 0 {
+		u += "?" + filter.Encode()
+	}
+	return pager.New[T](r.client.httpClient, u, pager.PageNumber("_page", "_limit", pageSize), opts...).All(ctx)
+}
+
+// Create POSTs v and returns the stored item with its assigned id
+func (r *Resource[T]) Create(ctx context.Context, v T) (T, error) {
+	var out T
+	err := r.client.do(ctx, http.MethodPost, r.collectionURL(), v, &out)
+	return out, err
+}
+
+// Update replaces the item with a PUT
+func (r *Resource[T]) Update(ctx context.Context, id int, v T) (T, error) {
+	var out T
+	err := r.client.do(ctx, http.MethodPut, r.itemURL(id), v, &out)
+	return out, err
+}
+
+// Patch sends only the fields in partial, e.g. map[string]any{"completed": true}
+func (r *Resource[T]) Patch(ctx context.Context, id int, partial any) (T, error) {
+	var out T
+	err := r.client.do(ctx, http.MethodPatch, r.itemURL(id), partial, &out)
+	return out, err
+}
+
+// Delete removes the item
+func (r *Resource[T]) Delete(ctx context.Context, id int) error {
+	return r.client.do(ctx, http.MethodDelete, r.itemURL(id), nil, nil)
+}
+
+func (r *Resource[T]) collectionURL() string {
+	return r.client.baseURL + "/" + r.path
+}
+
+func (r *Resource[T]) itemURL(id int) string {
+	return r.collectionURL() + "/" + strconv.Itoa(id)
+}
+
+// do sends body as JSON (when not nil) and decodes the response into out (when not nil)
+func (c *Client) do(ctx context.Context, method, rawURL string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal %s body: %w", method, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", method, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, rawURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return &StatusError{Method: method, URL: rawURL, StatusCode: res.StatusCode, Body: data}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return &DecodeError{Method: method, URL: rawURL, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package jsonplaceholder
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound matches a StatusError with a 404 status via errors.Is
+var ErrNotFound = errors.New("not found")
+
+// StatusError is returned when the server answers with a non-2xx status
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is reports a 404 as ErrNotFound
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// DecodeError is returned when a response body is not the expected JSON
+type DecodeError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s %s: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
@@ -0,0 +1,91 @@
+package placeholdertest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Same shape and counts as the real API: 10 users, 100 posts, 500 comments, 200 todos
+
+var users = []struct{ name, username, email, city string }{
+	{"Leanne Graham", "Bret", "Sincere@april.biz", "Gwenborough"},
+	{"Ervin Howell", "Antonette", "Shanna@melissa.tv", "Wisokyburgh"},
+	{"Clementine Bauch", "Samantha", "Nathan@yesenia.net", "McKenziehaven"},
+	{"Patricia Lebsack", "Karianne", "Julianne.OConner@kory.org", "South Elvis"},
+	{"Chelsey Dietrich", "Kamren", "Lucio_Hettinger@annie.ca", "Roscoeview"},
+	{"Mrs. Dennis Schulist", "Leopoldo_Corkery", "Karley_Dach@jasper.info", "South Christy"},
+	{"Kurtis Weissnat", "Elwyn.Skiles", "Telly.Hoeger@billy.biz", "Howemouth"},
+	{"Nicholas Runolfsdottir V", "Maxime_Nienow", "Sherwood@rosamond.me", "Aliyaview"},
+	{"Glenna Reichert", "Delphine", "Chaim_McDermott@dana.io", "Bartholomebury"},
+	{"Clementina DuBuque", "Moriah.Stanton", "Rey.Padberg@karina.biz", "Lebsackbury"},
+}
+
+var words = strings.Fields(`delectus aut autem quis ut nam facilis et officia qui fugiat veniam
+	sunt facere repellat provident occaecati excepturi optio reprehenderit est esse rerum tempore
+	vitae sequi sint nihil reprehenderit dolor beatae ea dolores neque voluptatem omnis eos dolorem
+	laboriosam odio magnam quo accusamus id labore ex eius molestiae`)
+
+// lorem returns n words picked deterministically from seed
+func lorem(seed, n int) string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = words[(seed*7+i*13)%len(words)]
+	}
+	return strings.Join(out, " ")
+}
+
+func seed() map[string][]Record {
+	c := map[string][]Record{}
+
+	for i, u := range users {
+		id := i + 1
+		c["users"] = append(c["users"], Record{
+			"id":       float64(id),
+			"name":     u.name,
+			"username": u.username,
+			"email":    u.email,
+			"address": map[string]any{
+				"street":  lorem(id, 2),
+				"suite":   fmt.Sprintf("Apt. %d", 500+id*17),
+				"city":    u.city,
+				"zipcode": fmt.Sprintf("%05d-%04d", 10000+id*3571, id*731),
+				"geo":     map[string]any{"lat": fmt.Sprintf("%.4f", -37.3+float64(id)*9.1), "lng": fmt.Sprintf("%.4f", 81.1-float64(id)*17.3)},
+			},
+			"phone":   fmt.Sprintf("1-770-736-%04d", 8031+id),
+			"website": strings.ToLower(u.username) + ".org",
+			"company": map[string]any{"name": strings.Fields(u.name)[len(strings.Fields(u.name))-1] + " Group", "catchPhrase": lorem(id+3, 4), "bs": lorem(id+5, 3)},
+		})
+	}
+
+	for id := 1; id <= 100; id++ {
+		c["posts"] = append(c["posts"], Record{
+			"userId": float64((id-1)/10 + 1),
+			"id":     float64(id),
+			"title":  lorem(id, 6),
+			"body":   lorem(id+1, 12),
+		})
+	}
+
+	for id := 1; id <= 500; id++ {
+		u := users[id%len(users)]
+		c["comments"] = append(c["comments"], Record{
+			"postId": float64((id-1)/5 + 1),
+			"id":     float64(id),
+			"name":   lorem(id+2, 4),
+			"email":  strings.ToLower(strings.Split(u.email, "@")[0]) + fmt.Sprintf("%d@", id) + strings.Split(u.email, "@")[1],
+			"body":   lorem(id+4, 10),
+		})
+	}
+
+	for id := 1; id <= 200; id++ {
+		c["todos"] = append(c["todos"], Record{
+			"userId":    float64((id-1)/20 + 1),
+			"id":        float64(id),
+			"title":     lorem(id-1, 3),
+			"completed": id%3 == 0 || id%5 == 0,
+		})
+	}
+	c["todos"][0]["title"] = "delectus aut autem"
+
+	return c
+}
@@ -0,0 +1,285 @@
+// Package placeholdertest is an in-memory fake of the JSONPlaceholder API
+// for tests and offline development.
+package placeholdertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Record is one stored JSON object
+type Record map[string]any
+
+// nested maps a parent collection to its child collections and their foreign key,
+// so /users/1/todos is /todos?userId=1
+var nested = map[string]map[string]string{
+	"users": {"todos": "userId", "posts": "userId"},
+	"posts": {"comments": "postId"},
+}
+
+// Server holds the collections and serves them over HTTP
+type Server struct {
+	mu          sync.Mutex
+	collections map[string][]Record
+}
+
+// New returns a Server seeded with users, posts, comments and todos
+func New() *Server {
+	return &Server{collections: seed()}
+}
+
+// NewServer starts an httptest.Server backed by a seeded fake. Close it when done.
+func NewServer() *httptest.Server {
+	return httptest.NewServer(New())
+}
+
+// Reset restores the seeded data
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collections = seed()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[parts[0]]; !ok {
+		writeJSON(w, http.StatusNotFound, Record{})
+		return
+	}
+
+	switch len(parts) {
+	case 1:
+		s.serveCollection(w, r, parts[0], nil)
+	case 2:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, Record{})
+			return
+		}
+		s.serveItem(w, r, parts[0], id)
+	case 3:
+		fk, ok := nested[parts[0]][parts[2]]
+		id, err := strconv.Atoi(parts[1])
+		if !ok || err != nil {
+			writeJSON(w, http.StatusNotFound, Record{})
+			return
+		}
+		s.serveCollection(w, r, parts[2], Record{fk: float64(id)})
+	default:
+		writeJSON(w, http.StatusNotFound, Record{})
+	}
+}
+
+// serveCollection handles GET and POST on a collection; parent holds fixed fields from a nested route
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request, name string, parent Record) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		for k, v := range parent {
+			query.Set(k, format(v))
+		}
+		items := []Record{}
+		for _, rec := range s.collections[name] {
+			if matches(rec, query) {
+				items = append(items, rec)
+			}
+		}
+		writeJSON(w, http.StatusOK, paginate(w, r, items))
+	case http.MethodPost:
+		rec, ok := decode(w, r)
+		if !ok {
+			return
+		}
+		for k, v := range parent {
+			rec[k] = v
+		}
+		rec["id"] = float64(s.nextID(name))
+		s.collections[name] = append(s.collections[name], rec)
+		writeJSON(w, http.StatusCreated, rec)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, Record{})
+	}
+}
+
+// serveItem handles GET, PUT, PATCH and DELETE on a single record
+func (s *Server) serveItem(w http.ResponseWriter, r *http.Request, name string, id int) {
+	items := s.collections[name]
+	i := sort.Search(len(items), func(i int) bool { return idOf(items[i]) >= id })
+	if i == len(items) || idOf(items[i]) != id {
+		writeJSON(w, http.StatusNotFound, Record{})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, items[i])
+	case http.MethodPut:
+		rec, ok := decode(w, r)
+		if !ok {
+			return
+		}
+		rec["id"] = float64(id)
+		items[i] = rec
+		writeJSON(w, http.StatusOK, rec)
+	case http.MethodPatch:
+		patch, ok := decode(w, r)
+		if !ok {
+			return
+		}
+		rec := Record{}
+		for k, v := range items[i] {
+			rec[k] = v
+		}
+		for k, v := range patch {
+			rec[k] = v
+		}
+		rec["id"] = float64(id)
+		items[i] = rec
+		writeJSON(w, http.StatusOK, rec)
+	case http.MethodDelete:
+		s.collections[name] = append(items[:i:i], items[i+1:]...)
+		writeJSON(w, http.StatusOK, Record{})
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, Record{})
+	}
+}
+
+func (s *Server) nextID(name string) int {
+	items := s.collections[name]
+	if len(items) == 0 {
+		return 1
+	}
+	return idOf(items[len(items)-1]) + 1
+}
+
+// paginate applies the json-server paging parameters: _page with _limit (default 10),
+// or _start with _end or _limit. Paged responses carry X-Total-Count and, for _page, a Link header.
+func paginate(w http.ResponseWriter, r *http.Request, items []Record) []Record {
+	q := r.URL.Query()
+	if !q.Has("_page") && !q.Has("_start") && !q.Has("_end") && !q.Has("_limit") {
+		return items
+	}
+	total := len(items)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	limit, err := strconv.Atoi(q.Get("_limit"))
+	if err != nil || limit <= 0 {
+		limit = -1
+	}
+
+	start, end := 0, total
+	if q.Has("_page") {
+		page, err := strconv.Atoi(q.Get("_page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		if limit < 0 {
+			limit = 10
+		}
+		start, end = (page-1)*limit, page*limit
+		setLinks(w, r, page, (total+limit-1)/limit)
+	} else {
+		start, _ = strconv.Atoi(q.Get("_start"))
+		if v, err := strconv.Atoi(q.Get("_end")); err == nil {
+			end = v
+		} else if limit >= 0 {
+			end = start + limit
+		}
+	}
+
+	start = max(0, min(start, total))
+	end = max(start, min(end, total))
+	return items[start:end]
+}
+
+func setLinks(w http.ResponseWriter, r *http.Request, page, last int) {
+	link := func(p int, rel string) string {
+		u := *r.URL
+		u.Scheme, u.Host = "http", r.Host
+		q := u.Query()
+		q.Set("_page", strconv.Itoa(p))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	if page < last {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(max(last, 1), "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// matches reports whether rec has every queried field. Repeated keys match any of their values.
+// Keys starting with "_" are reserved for paging and ignored here.
+func matches(rec Record, query map[string][]string) bool {
+	for key, want := range query {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		got, ok := rec[key]
+		if !ok {
+			return false
+		}
+		found := false
+		for _, v := range want {
+			if format(got) == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// format renders a decoded JSON scalar the way it would appear in a query string
+func format(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func idOf(rec Record) int {
+	id, _ := rec["id"].(float64)
+	return int(id)
+}
+
+func decode(w http.ResponseWriter, r *http.Request) (Record, bool) {
+	var rec Record
+	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || rec == nil {
+		writeJSON(w, http.StatusBadRequest, Record{"error": "body must be a JSON object"})
+		return nil, false
+	}
+	return rec, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
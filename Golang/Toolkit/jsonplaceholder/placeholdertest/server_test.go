@@ -0,0 +1,82 @@
+package placeholdertest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/Shehbab-Kakkar/toolkit/jsonplaceholder"
+)
+
+func TestClientAgainstFake(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := jsonplaceholder.NewClient(srv.URL, srv.Client())
+	todos := client.Todos()
+
+	todo, err := todos.Get(ctx, 1)
+	if err != nil || todo.Title != "delectus aut autem" || todo.UserID != 1 {
+		t.Fatalf("Get: %+v, %v", todo, err)
+	}
+
+	created, err := todos.Create(ctx, jsonplaceholder.Todo{UserID: 23, Title: "Prince Kumar", Completed: true})
+	if err != nil || created.Id != 201 {
+		t.Fatalf("Create: %+v, %v", created, err)
+	}
+	if got, err := todos.Get(ctx, 201); err != nil || got != created {
+		t.Fatalf("Get after Create: %+v, %v", got, err)
+	}
+
+	updated, err := todos.Update(ctx, 201, jsonplaceholder.Todo{UserID: 23, Title: "renamed"})
+	if err != nil || updated.Title != "renamed" || updated.Completed {
+		t.Fatalf("Update: %+v, %v", updated, err)
+	}
+
+	patched, err := todos.Patch(ctx, 201, map[string]any{"completed": true})
+	if err != nil || !patched.Completed || patched.Title != "renamed" {
+		t.Fatalf("Patch: %+v, %v", patched, err)
+	}
+
+	if err := todos.Delete(ctx, 201); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := todos.Get(ctx, 201); !errors.Is(err, jsonplaceholder.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after Delete, got %v", err)
+	}
+}
+
+func TestFilteringAndNestedRoutes(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := jsonplaceholder.NewClient(srv.URL, srv.Client())
+
+	list, err := client.Todos().List(ctx, url.Values{"userId": {"1"}, "completed": {"true"}})
+	if err != nil || len(list) == 0 {
+		t.Fatalf("List: %d items, %v", len(list), err)
+	}
+	for _, todo := range list {
+		if todo.UserID != 1 || !todo.Completed {
+			t.Fatalf("filter not applied: %+v", todo)
+		}
+	}
+
+	userTodos, err := jsonplaceholder.NewResource[jsonplaceholder.Todo](client, "users/1/todos").List(ctx, nil)
+	if err != nil || len(userTodos) != 20 {
+		t.Fatalf("nested todos: %d items, %v", len(userTodos), err)
+	}
+
+	comments, err := jsonplaceholder.NewResource[jsonplaceholder.Comment](client, "posts/3/comments").List(ctx, nil)
+	if err != nil || len(comments) != 5 || comments[0].PostID != 3 {
+		t.Fatalf("nested comments: %+v, %v", comments, err)
+	}
+
+	users, err := client.Users().List(ctx, url.Values{"username": {"Bret", "Delphine"}})
+	if err != nil || len(users) != 2 {
+		t.Fatalf("repeated key filter: %+v, %v", users, err)
+	}
+}
@@ -0,0 +1,305 @@
+//go:build lockdebug
+
+package lockdebug
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enabled reports whether the package was built with the lockdebug tag
+const Enabled = true
+
+// Mutex is a sync.Mutex that reports to the package registry. Locks with the
+// same name are one class for ordering, the way every post.mu is "post.mu".
+// The zero value is an unnamed Mutex with a class of its own.
+type Mutex struct {
+	mu   sync.Mutex
+	name string
+}
+
+// New returns a Mutex in the class name
+func New(name string) *Mutex { return &Mutex{name: name} }
+
+func (m *Mutex) class() string {
+	if m.name != "" {
+		return m.name
+	}
+	return fmt.Sprintf("mutex@%p", m)
+}
+
+// Lock checks the acquisition against the lock order seen so far, then locks
+func (m *Mutex) Lock() {
+	g, pcs := goid(), callers()
+	emit(reg.check(m, g, pcs))
+
+	start := time.Now()
+	contended := !m.mu.TryLock()
+	if contended {
+		m.mu.Lock()
+	}
+	reg.acquired(m, g, pcs, time.Since(start), contended)
+}
+
+// TryLock locks m if it is free. It cannot deadlock, so the order is recorded
+// but not checked.
+func (m *Mutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	reg.acquired(m, goid(), callers(), 0, false)
+	return true
+}
+
+// Unlock reports a long hold, if any, and unlocks
+func (m *Mutex) Unlock() {
+	reports, fn := reg.released(m)
+	m.mu.Unlock()
+	emit(reports, fn)
+}
+
+var reg = newRegistry()
+
+type holding struct {
+	g     int64
+	since time.Time
+	pcs   []uintptr
+}
+
+type registry struct {
+	mu       sync.Mutex
+	cfg      Config
+	stats    map[string]*Stats
+	held     map[int64][]*Mutex // per goroutine, in acquisition order
+	holding  map[*Mutex]holding
+	edges    map[[2]string][]uintptr // first class then second, where first seen
+	reported map[[2]string]bool
+	reports  []Report
+}
+
+func newRegistry() *registry {
+	r := &registry{}
+	r.reset(Config{})
+	return r
+}
+
+func (r *registry) reset(cfg Config) {
+	cfg.defaults()
+	r.cfg = cfg
+	r.stats = map[string]*Stats{}
+	r.held = map[int64][]*Mutex{}
+	r.holding = map[*Mutex]holding{}
+	r.edges = map[[2]string][]uintptr{}
+	r.reported = map[[2]string]bool{}
+	r.reports = nil
+}
+
+// Configure replaces the configuration and forgets everything recorded so
+// far. Call it before any Mutex is in use.
+func Configure(cfg Config) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.reset(cfg)
+}
+
+// Reset forgets the recorded order, stats and reports but keeps the configuration
+func Reset() {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.reset(reg.cfg)
+}
+
+func (r *registry) statsFor(class string) *Stats {
+	st := r.stats[class]
+	if st == nil {
+		st = &Stats{Name: class}
+		r.stats[class] = st
+	}
+	return st
+}
+
+// check runs before g blocks on m and returns what it found, plus the
+// callback to deliver it with
+func (r *registry) check(m *Mutex, g int64, pcs []uintptr) ([]Report, func(Report)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	class := m.class()
+	var out []Report
+	for _, h := range r.held[g] {
+		if h == m {
+			out = append(out, Report{Kind: Recursive, Lock: class, Goroutine: g, At: time.Now(), Stack: formatStack(pcs)})
+			continue
+		}
+		hc := h.class()
+		// nesting two locks of one class, like two posts, has no order to check
+		if hc == class {
+			continue
+		}
+		pair := [2]string{hc, class}
+		if path := r.path(class, hc); path != nil && !r.reported[pair] {
+			r.reported[pair] = true
+			r.reported[[2]string{class, hc}] = true
+			r.statsFor(class).Inversions++
+			r.statsFor(hc).Inversions++
+			out = append(out, Report{
+				Kind: Inversion, Lock: class, Other: hc, Goroutine: g, At: time.Now(),
+				Stack:      fmt.Sprintf("%s held since\n%s\nthen %s from\n%s", hc, formatStack(r.holding[h].pcs), class, formatStack(pcs)),
+				OtherStack: formatStack(r.edges[path[0]]),
+			})
+		}
+	}
+	for _, rep := range out {
+		r.keep(rep)
+	}
+	return out, r.cfg.OnReport
+}
+
+// path finds a chain of recorded orders from class a to class b and returns
+// its edges, or nil if a was never taken before b
+func (r *registry) path(a, b string) [][2]string {
+	seen := map[string]bool{a: true}
+	var walk func(from string) [][2]string
+	walk = func(from string) [][2]string {
+		for e := range r.edges {
+			if e[0] != from || seen[e[1]] {
+				continue
+			}
+			if e[1] == b {
+				return [][2]string{e}
+			}
+			seen[e[1]] = true
+			if rest := walk(e[1]); rest != nil {
+				return append([][2]string{e}, rest...)
+			}
+		}
+		return nil
+	}
+	return walk(a)
+}
+
+func (r *registry) acquired(m *Mutex, g int64, pcs []uintptr, wait time.Duration, contended bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	class := m.class()
+	for _, h := range r.held[g] {
+		if e := [2]string{h.class(), class}; e[0] != e[1] && r.edges[e] == nil {
+			r.edges[e] = pcs
+		}
+	}
+	r.held[g] = append(r.held[g], m)
+	r.holding[m] = holding{g: g, since: time.Now(), pcs: pcs}
+
+	st := r.statsFor(class)
+	st.Acquired++
+	if contended {
+		st.Contended++
+		st.WaitTotal += wait
+		st.WaitMax = max(st.WaitMax, wait)
+	}
+}
+
+func (r *registry) released(m *Mutex) ([]Report, func(Report)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.holding[m]
+	if !ok {
+		// unlocked without a recorded Lock, e.g. after Reset; sync.Mutex
+		// itself will complain if it really was unlocked
+		return nil, nil
+	}
+	delete(r.holding, m)
+	// any goroutine may unlock, so remove it from the holder's list
+	held := r.held[h.g]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i] == m {
+			held = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(r.held, h.g)
+	} else {
+		r.held[h.g] = held
+	}
+
+	d := time.Since(h.since)
+	st := r.statsFor(m.class())
+	st.HoldTotal += d
+	st.HoldMax = max(st.HoldMax, d)
+	if d < r.cfg.LongHold {
+		return nil, nil
+	}
+	st.LongHolds++
+	rep := Report{Kind: LongHold, Lock: m.class(), Held: d, Goroutine: h.g, At: time.Now(), Stack: formatStack(h.pcs)}
+	r.keep(rep)
+	return []Report{rep}, r.cfg.OnReport
+}
+
+func (r *registry) keep(rep Report) {
+	r.reports = append(r.reports, rep)
+	if over := len(r.reports) - r.cfg.MaxReports; over > 0 {
+		r.reports = append(r.reports[:0], r.reports[over:]...)
+	}
+}
+
+// emit delivers reports after the registry lock is released, so OnReport may
+// call Snapshot
+func emit(reports []Report, fn func(Report)) {
+	for _, rep := range reports {
+		fn(rep)
+	}
+}
+
+// Snapshot returns the stats of every class, current holders included, and
+// the kept reports
+func Snapshot() State {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	s := State{Enabled: true, Reports: append([]Report(nil), reg.reports...)}
+	for _, st := range reg.stats {
+		c := *st
+		c.Holders = nil
+		s.Locks = append(s.Locks, c)
+	}
+	sort.Slice(s.Locks, func(i, j int) bool { return s.Locks[i].Name < s.Locks[j].Name })
+	for m, h := range reg.holding {
+		class := m.class()
+		i := sort.Search(len(s.Locks), func(i int) bool { return s.Locks[i].Name >= class })
+		s.Locks[i].Holders = append(s.Locks[i].Holders, Holder{Goroutine: h.g, For: time.Since(h.since), Stack: formatStack(h.pcs)})
+	}
+	return s
+}
+
+// callers skips runtime.Callers, callers and the Mutex method
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(3, pcs)]
+}
+
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			return b.String()
+		}
+	}
+}
+
+// goid reads the current goroutine's id from its stack header, "goroutine 7 [running]:"
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:bytes.IndexByte(b, ' ')]
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
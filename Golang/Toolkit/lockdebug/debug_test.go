@@ -0,0 +1,158 @@
+//go:build lockdebug
+
+package lockdebug
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -tags lockdebug ./lockdebug
+
+// collect configures the registry afresh and returns what OnReport received
+func collect(t *testing.T, longHold time.Duration) func() []Report {
+	var mu sync.Mutex
+	var got []Report
+	Configure(Config{LongHold: longHold, OnReport: func(r Report) {
+		mu.Lock()
+		got = append(got, r)
+		mu.Unlock()
+	}})
+	t.Cleanup(func() { Configure(Config{}) })
+	return func() []Report {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Report(nil), got...)
+	}
+}
+
+func TestInversion(t *testing.T) {
+	reports := collect(t, time.Hour)
+	a, b, c := New("a"), New("b"), New("c")
+
+	// a then b, then b then c: no problem yet
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	b.Lock()
+	c.Lock()
+	c.Unlock()
+	b.Unlock()
+	if r := reports(); len(r) != 0 {
+		t.Fatalf("consistent order reported: %+v", r)
+	}
+
+	// c then a closes the cycle a -> b -> c -> a, and would deadlock against
+	// the goroutines above if they ran at the same time
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Lock()
+		a.Lock()
+		a.Unlock()
+		c.Unlock()
+	}()
+	<-done
+	r := reports()
+	if len(r) != 1 || r[0].Kind != Inversion || r[0].Lock != "a" || r[0].Other != "c" {
+		t.Fatalf("%+v", r)
+	}
+	if !strings.Contains(r[0].Stack, "TestInversion") || !strings.Contains(r[0].OtherStack, "TestInversion") {
+		t.Fatalf("stacks do not point at the test:\n%s\n---\n%s", r[0].Stack, r[0].OtherStack)
+	}
+
+	// reported once per pair
+	c.Lock()
+	a.Lock()
+	a.Unlock()
+	c.Unlock()
+	if n := len(reports()); n != 1 {
+		t.Fatalf("%d reports", n)
+	}
+}
+
+func TestSameClassNesting(t *testing.T) {
+	reports := collect(t, time.Hour)
+	p1, p2 := New("post.mu"), New("post.mu")
+	p1.Lock()
+	p2.Lock()
+	p2.Unlock()
+	p1.Unlock()
+	p2.Lock()
+	p1.Lock()
+	p1.Unlock()
+	p2.Unlock()
+	if r := reports(); len(r) != 0 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestRecursiveLock(t *testing.T) {
+	var m Mutex
+	Configure(Config{OnReport: func(r Report) {
+		// let the second Lock through instead of deadlocking the test
+		if r.Kind == Recursive {
+			m.Unlock()
+		}
+	}})
+	t.Cleanup(func() { Configure(Config{}) })
+	m.Lock()
+	m.Lock()
+	m.Unlock()
+	if st := Snapshot(); len(st.Reports) != 1 || st.Reports[0].Kind != Recursive {
+		t.Fatalf("%+v", st.Reports)
+	}
+}
+
+func TestLongHoldAndHolders(t *testing.T) {
+	reports := collect(t, 20*time.Millisecond)
+	mu := New("post.mu")
+
+	// the With-Mutex.go bug: Unlock commented out
+	mu.Lock()
+	st := Snapshot()
+	if len(st.Locks) != 1 || len(st.Locks[0].Holders) != 1 || !strings.Contains(st.Locks[0].Holders[0].Stack, "TestLongHoldAndHolders") {
+		t.Fatalf("holder not shown: %+v", st.Locks)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		mu.Lock()
+		mu.Unlock()
+		close(waited)
+	}()
+	time.Sleep(40 * time.Millisecond)
+	mu.Unlock()
+	<-waited
+
+	r := reports()
+	if len(r) != 1 || r[0].Kind != LongHold || r[0].Held < 40*time.Millisecond {
+		t.Fatalf("%+v", r)
+	}
+	s := Snapshot().Locks[0]
+	if s.Acquired != 2 || s.Contended != 1 || s.WaitMax < 30*time.Millisecond || s.LongHolds != 1 || len(s.Holders) != 0 {
+		t.Fatalf("%+v", s)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	collect(t, time.Hour)
+	m := New("x")
+	m.Lock()
+	defer m.Unlock()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/locks", nil))
+	var st State
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Enabled || len(st.Locks) != 1 || st.Locks[0].Name != "x" || len(st.Locks[0].Holders) != 1 {
+		t.Fatalf("%s", rec.Body)
+	}
+}
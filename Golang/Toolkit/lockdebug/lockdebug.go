@@ -0,0 +1,111 @@
+// Package lockdebug is a sync.Mutex that can explain itself. Built with
+//
+//	go build -tags lockdebug
+//
+// every Mutex records which goroutine holds it and from where, reports hold
+// times above Config.LongHold, and checks each acquisition against the order
+// locks were taken in before, so an A-then-B / B-then-A inversion is reported
+// the first time both orders are seen rather than the day they deadlock. The
+// forgotten p.mu.Unlock() from Golang/Mutex/With-Mutex.go shows up on the
+// debug page as a holder that never lets go.
+//
+// Without the tag Mutex is a plain sync.Mutex and the rest of the package does
+// nothing, so it can stay in release code.
+package lockdebug
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Kind says what a Report is about
+type Kind string
+
+const (
+	// Inversion is two locks taken in both orders
+	Inversion Kind = "lock-order-inversion"
+	// Recursive is a goroutine locking a Mutex it already holds, which
+	// deadlocks immediately
+	Recursive Kind = "recursive-lock"
+	// LongHold is a Mutex held longer than Config.LongHold
+	LongHold Kind = "long-hold"
+)
+
+// Report is one problem the debug build found
+type Report struct {
+	Kind      Kind          `json:"kind"`
+	Lock      string        `json:"lock"`
+	Other     string        `json:"other,omitempty"` // the lock taken in the opposite order
+	Held      time.Duration `json:"held,omitempty"`
+	Goroutine int64         `json:"goroutine"`
+	At        time.Time     `json:"at"`
+	Stack     string        `json:"stack"`
+	// OtherStack is where the opposite order was first seen
+	OtherStack string `json:"other_stack,omitempty"`
+}
+
+// Stats describes the locks sharing one name
+type Stats struct {
+	Name       string        `json:"name"`
+	Acquired   int64         `json:"acquired"`
+	Contended  int64         `json:"contended"` // acquisitions that had to wait
+	WaitTotal  time.Duration `json:"wait_total"`
+	WaitMax    time.Duration `json:"wait_max"`
+	HoldTotal  time.Duration `json:"hold_total"`
+	HoldMax    time.Duration `json:"hold_max"`
+	LongHolds  int64         `json:"long_holds"`
+	Inversions int64         `json:"inversions"`
+	Holders    []Holder      `json:"holders,omitempty"`
+}
+
+// Holder is a goroutine holding a Mutex right now
+type Holder struct {
+	Goroutine int64         `json:"goroutine"`
+	For       time.Duration `json:"for"`
+	Stack     string        `json:"stack"`
+}
+
+// State is everything the debug build knows
+type State struct {
+	Enabled bool     `json:"enabled"`
+	Locks   []Stats  `json:"locks"`
+	Reports []Report `json:"reports"`
+}
+
+// Config tunes the debug build; release builds ignore it
+type Config struct {
+	LongHold time.Duration // default 100ms
+	// OnReport is called for every Report, by default log.Print. It runs on
+	// the goroutine that found the problem, outside any of the package's locks.
+	OnReport func(Report)
+	// MaxReports caps how many reports State keeps, oldest dropped first
+	MaxReports int // default 100
+}
+
+func (c *Config) defaults() {
+	if c.LongHold <= 0 {
+		c.LongHold = 100 * time.Millisecond
+	}
+	if c.OnReport == nil {
+		c.OnReport = func(r Report) {
+			log.Printf("lockdebug: %s on %s (goroutine %d)\n%s", r.Kind, r.Lock, r.Goroutine, r.Stack)
+		}
+	}
+	if c.MaxReports <= 0 {
+		c.MaxReports = 100
+	}
+}
+
+// Handler serves Snapshot as JSON. Mount it next to pprof:
+//
+//	http.Handle("/debug/locks", lockdebug.Handler())
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(Snapshot())
+	})
+}
@@ -0,0 +1,25 @@
+//go:build !lockdebug
+
+package lockdebug
+
+import "sync"
+
+// Enabled reports whether the package was built with the lockdebug tag
+const Enabled = false
+
+// Mutex is a sync.Mutex; build with -tags lockdebug to instrument it
+type Mutex struct {
+	sync.Mutex
+}
+
+// New returns a Mutex; the name is only used by the debug build
+func New(name string) *Mutex { return new(Mutex) }
+
+// Configure does nothing in release builds
+func Configure(Config) {}
+
+// Snapshot returns an empty State in release builds
+func Snapshot() State { return State{} }
+
+// Reset does nothing in release builds
+func Reset() {}
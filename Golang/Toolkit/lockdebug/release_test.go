@@ -0,0 +1,33 @@
+//go:build !lockdebug
+
+package lockdebug
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestReleaseIsPlainMutex(t *testing.T) {
+	if unsafe.Sizeof(Mutex{}) != unsafe.Sizeof(sync.Mutex{}) {
+		t.Fatal("release Mutex carries extra state")
+	}
+	m := New("post.mu")
+	m.Lock()
+	if m.TryLock() {
+		t.Fatal("TryLock on a held Mutex")
+	}
+	m.Unlock()
+
+	Configure(Config{})
+	if st := Snapshot(); st.Enabled || len(st.Locks) != 0 {
+		t.Fatalf("%+v", st)
+	}
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/locks", nil))
+	if !strings.Contains(rec.Body.String(), `"enabled": false`) {
+		t.Fatal(rec.Body.String())
+	}
+}
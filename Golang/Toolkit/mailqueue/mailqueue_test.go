@@ -0,0 +1,320 @@
+package mailqueue
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/smtptest"
+)
+
+var welcome = MustParseTemplate("welcome",
+	"Welcome, {{.Name}}",
+	"Hi {{.Name}},\nthanks for signing up.\n",
+	"<p>Hi {{.Name}}, thanks for signing up.</p>")
+
+// secureServer requires STARTTLS and AUTH, like a real submission port
+func secureServer(t *testing.T) (*smtptest.Server, SMTP) {
+	t.Helper()
+	cert, pool, err := smtptest.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.Auth = func(user, pass string) bool { return user == "app" && pass == "secret" }
+	return srv, SMTP{
+		Addr:     srv.Addr(),
+		TLS:      &tls.Config{RootCAs: pool},
+		StartTLS: TLSRequired,
+		Username: "app",
+		Password: "secret",
+		Timeout:  5 * time.Second,
+	}
+}
+
+// run starts q and returns a func that stops it and waits
+func run(q *Queue) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverOverStartTLSWithAuth(t *testing.T) {
+	srv, sender := secureServer(t)
+	q, err := Open(Config{
+		Dir:       t.TempDir(),
+		Sender:    sender,
+		From:      "noreply@example.com",
+		Templates: map[string]*Template{"welcome": welcome},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run(q)()
+
+	to := []string{"a@example.com", "b@example.com", "c@example.com"}
+	id, err := q.Enqueue(context.Background(), Message{To: to, Template: "welcome", Data: map[string]string{"Name": "<Ann>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server records a message before it replies, so wait for the
+	// queue's own count rather than the server's
+	waitFor(t, "three deliveries", func() bool {
+		st := q.Stats()
+		return st.Sent == 3 && st.Pending == 0 && st.InFlight == 0
+	})
+	if n := len(srv.Messages()); n != 3 {
+		t.Fatalf("server got %d messages", n)
+	}
+
+	for _, m := range srv.Messages() {
+		if !m.TLS || m.User != "app" || len(m.To) != 1 {
+			t.Fatalf("%+v", m)
+		}
+		msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if subject != "Welcome, <Ann>" || msg.Header.Get("Message-Id") != "<"+id+">" {
+			t.Fatalf("headers: %v", msg.Header)
+		}
+		_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+		var parts []string
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(p) // NextPart undoes quoted-printable
+			parts = append(parts, string(b))
+		}
+		if len(parts) != 2 || !strings.Contains(parts[0], "Hi <Ann>,\r\n") || !strings.Contains(parts[1], "Hi &lt;Ann&gt;") {
+			t.Fatalf("parts: %q", parts)
+		}
+	}
+	if st := q.Stats(); st.Sent != 3 || st.Pending != 0 {
+		t.Fatalf("%+v", st)
+	}
+}
+
+func TestPerRecipientRetry(t *testing.T) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	var mu sync.Mutex
+	tries := map[string]int{}
+	srv.TempFailRcpt = func(addr string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		tries[addr]++
+		return addr == "slow@example.com" && tries[addr] < 3
+	}
+	srv.RejectRcpt = func(addr string) bool { return addr == "nobody@example.com" }
+
+	var retries []time.Duration
+	q, err := Open(Config{
+		Dir:       t.TempDir(),
+		Sender:    SMTP{Addr: srv.Addr()},
+		From:      "noreply@example.com",
+		BaseDelay: 20 * time.Millisecond,
+		OnRetry: func(d Delivery, wait time.Duration, err error) {
+			mu.Lock()
+			retries = append(retries, wait)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run(q)()
+
+	_, err = q.Enqueue(context.Background(), Message{
+		To:      []string{"ok@example.com", "slow@example.com", "nobody@example.com"},
+		Content: Content{Subject: "hi", Text: "hello"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the queue to drain", func() bool { return q.Stats().Pending == 0 })
+
+	var got []string
+	for _, m := range srv.Messages() {
+		got = append(got, m.To[0])
+	}
+	if len(got) != 2 || got[0] != "ok@example.com" || got[1] != "slow@example.com" {
+		t.Fatalf("delivered to %v", got)
+	}
+	mu.Lock()
+	if len(retries) != 2 || retries[0] != 20*time.Millisecond || retries[1] != 40*time.Millisecond {
+		t.Fatalf("retries %v", retries)
+	}
+	mu.Unlock()
+
+	failed, err := q.Failed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 550 is permanent: one attempt, no retries
+	if len(failed) != 1 || failed[0].To != "nobody@example.com" || failed[0].Attempts != 1 || !strings.Contains(failed[0].LastError, "550") {
+		t.Fatalf("%+v", failed)
+	}
+	if st := q.Stats(); st.Sent != 2 || st.Retried != 2 || st.Failed != 1 {
+		t.Fatalf("%+v", st)
+	}
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	srv, sender := secureServer(t)
+
+	// the first process can only reach a server without STARTTLS, which the
+	// policy refuses, so everything stays queued
+	plainSrv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plainSrv.Close()
+	noTLS := sender
+	noTLS.Addr = plainSrv.Addr()
+
+	attempted := make(chan Delivery, 10)
+	q, err := Open(Config{Dir: dir, Sender: noTLS, From: "noreply@example.com", BaseDelay: time.Hour,
+		OnRetry: func(d Delivery, _ time.Duration, err error) {
+			if !errors.Is(err, ErrNoStartTLS) {
+				t.Errorf("got %v", err)
+			}
+			attempted <- d
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := run(q)
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if _, err := q.Enqueue(context.Background(), Message{To: []string{to}, Content: Content{Subject: "s", Text: "t"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-attempted
+	<-attempted
+	// no Close: the process just goes away
+	stop()
+
+	// the next process finds both, with their attempts, and a fixed server
+	q, err = Open(Config{Dir: dir, Sender: sender, From: "noreply@example.com", MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := q.Stats(); st.Pending != 2 {
+		t.Fatalf("%+v", st)
+	}
+	for _, d := range q.pending {
+		if d.Attempts != 1 || d.Next.Before(time.Now()) {
+			t.Fatalf("%+v", d)
+		}
+		// don't make the test wait the hour
+		d.Next = time.Now()
+	}
+	defer run(q)()
+	waitFor(t, "delivery after restart", func() bool { return len(srv.Messages()) == 2 })
+	if len(plainSrv.Messages()) != 0 {
+		t.Fatal("sent without TLS")
+	}
+}
+
+func TestDisplayNamesStayInHeaders(t *testing.T) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	q, err := Open(Config{Dir: t.TempDir(), Sender: SMTP{Addr: srv.Addr()}, From: "Shop <shop@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run(q)()
+
+	_, err = q.Enqueue(context.Background(), Message{To: []string{"Ann <ann@example.com>"}, Content: Content{Subject: "hi", Text: "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the delivery", func() bool {
+		st := q.Stats()
+		return st.Sent+st.Failed == 1
+	})
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].From != "shop@example.com" || len(msgs[0].To) != 1 || msgs[0].To[0] != "ann@example.com" {
+		t.Fatalf("envelope: %+v, stats %+v", msgs, q.Stats())
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(msgs[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("From") != "Shop <shop@example.com>" || msg.Header.Get("To") != "Ann <ann@example.com>" {
+		t.Fatalf("headers: %v", msg.Header)
+	}
+}
+
+func TestEnqueueRejectsBadInput(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir(), Sender: SMTP{Addr: "127.0.0.1:1"}, From: "noreply@example.com",
+		Templates: map[string]*Template{"welcome": welcome}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for name, m := range map[string]Message{
+		"no recipients":    {Content: Content{Subject: "s"}},
+		"bad address":      {To: []string{"not an address"}},
+		"unknown template": {To: []string{"a@example.com"}, Template: "nope"},
+		"missing data":     {To: []string{"a@example.com"}, Template: "welcome", Data: map[string]string{}},
+	} {
+		if _, err := q.Enqueue(ctx, m); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if st := q.Stats(); st.Pending != 0 {
+		t.Fatalf("%+v", st)
+	}
+	// header injection through the subject is flattened
+	c, _ := MustParseTemplate("x", "{{.}}", "", "").Render("hi\r\nBcc: evil@example.com")
+	if strings.ContainsAny(c.Subject, "\r\n") {
+		t.Fatalf("%q", c.Subject)
+	}
+}
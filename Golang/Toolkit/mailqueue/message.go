@@ -0,0 +1,103 @@
+package mailqueue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is what Enqueue accepts. Either Template names one of
+// Config.Templates, rendered with Data, or Content is sent as is.
+type Message struct {
+	From     string // default Config.From
+	To       []string
+	Template string
+	Data     any
+	Content
+	Headers map[string]string // extra headers such as Reply-To
+}
+
+// envelope returns the bare addresses MAIL FROM and RCPT TO take. "Ann
+// <ann@example.com>" is fine in a header but a server rejects it as a path.
+func envelope(from string, to []string) (string, []string, error) {
+	addrs := make([]string, 0, 1+len(to))
+	for _, a := range append([]string{from}, to...) {
+		parsed, err := mail.ParseAddress(a)
+		if err != nil {
+			return "", nil, permanentError{fmt.Errorf("mailqueue: address %q: %w", a, err)}
+		}
+		addrs = append(addrs, parsed.Address)
+	}
+	return addrs[0], addrs[1:], nil
+}
+
+// build renders the RFC 5322 message every recipient of m receives
+func build(m Message, from, id string, date time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		// values come from callers and templates; CR or LF would inject headers
+		v = strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	header("From", from)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+">")
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(textproto.CanonicalMIMEHeaderKey(k), m.Headers[k])
+	}
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQP(&b, m.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&b)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	b.WriteString("\r\n")
+	for _, part := range []struct{ typ, body string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQP(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
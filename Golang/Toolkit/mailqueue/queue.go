@@ -0,0 +1,420 @@
+// Package mailqueue is emailSender from Golang/Channel/Bufferred.Channel.go
+// grown into a mail queue. Each recipient of a message becomes a delivery in
+// its own file on disk before Enqueue returns, a pool of workers sends due
+// deliveries over SMTP, and failures are retried with exponential backoff per
+// recipient, so one bad address neither blocks nor re-sends to the others.
+// Deliveries still queued when the process stops are picked up by the next Open.
+package mailqueue
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+const (
+	queueDir  = "queue"
+	failedDir = "failed"
+)
+
+// Config configures a Queue
+type Config struct {
+	Dir       string
+	Sender    Sender
+	From      string // default sender address
+	Templates map[string]*Template
+	Workers   int // default 4
+
+	MaxAttempts int           // per recipient, default 8
+	BaseDelay   time.Duration // first retry, doubled every attempt, default 1m
+	MaxDelay    time.Duration // backoff cap, default 1h
+	Timeout     time.Duration // per attempt, default 1m
+
+	// Hooks run on the worker goroutine; any may be nil
+	OnSent   func(Delivery)
+	OnRetry  func(d Delivery, wait time.Duration, err error)
+	OnFailed func(d Delivery, err error) // attempts used up or permanent error
+
+	Now func() time.Time
+}
+
+func (c *Config) defaults() {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 8
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = time.Minute
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = time.Hour
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = time.Minute
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+}
+
+// Delivery is one message to one recipient, as stored on disk
+type Delivery struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	From      string    `json:"from"` // envelope address, without a display name
+	To        string    `json:"to"`
+	Queued    time.Time `json:"queued"`
+	Attempts  int       `json:"attempts"`
+	Next      time.Time `json:"next"`
+	LastError string    `json:"last_error,omitempty"`
+	Raw       []byte    `json:"raw"`
+}
+
+// Stats counts deliveries
+type Stats struct {
+	Pending  int // queued, including in flight
+	InFlight int
+	Sent     int // since Open
+	Retried  int
+	Failed   int
+}
+
+// Queue is a persistent mail queue
+type Queue struct {
+	cfg Config
+
+	mu       sync.Mutex
+	pending  deliveryHeap
+	inFlight int
+	stats    Stats
+	wake     chan struct{}
+}
+
+// Open loads the deliveries left in cfg.Dir. Call Run to start sending.
+func Open(cfg Config) (*Queue, error) {
+	cfg.defaults()
+	if cfg.Sender == nil {
+		return nil, errors.New("mailqueue: no Sender")
+	}
+	for _, d := range []string{queueDir, failedDir} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, d), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	q := &Queue{cfg: cfg, wake: make(chan struct{}, 1)}
+
+	entries, err := os.ReadDir(filepath.Join(cfg.Dir, queueDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			// a temp file from a write a crash interrupted
+			os.Remove(filepath.Join(cfg.Dir, queueDir, name))
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(cfg.Dir, queueDir, name))
+		if err != nil {
+			return nil, err
+		}
+		d := new(Delivery)
+		if err := json.Unmarshal(b, d); err != nil {
+			return nil, fmt.Errorf("mailqueue: %s: %w", name, err)
+		}
+		heap.Push(&q.pending, d)
+	}
+	return q, nil
+}
+
+// Enqueue stores one delivery per recipient and returns the Message-ID.
+// Once it returns the message survives a crash.
+func (q *Queue) Enqueue(ctx context.Context, m Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if len(m.To) == 0 {
+		return "", errors.New("mailqueue: no recipients")
+	}
+	if m.Template != "" {
+		t, ok := q.cfg.Templates[m.Template]
+		if !ok {
+			return "", fmt.Errorf("mailqueue: no template %q", m.Template)
+		}
+		c, err := t.Render(m.Data)
+		if err != nil {
+			return "", err
+		}
+		m.Content = c
+	}
+	from := m.From
+	if from == "" {
+		from = q.cfg.From
+	}
+	envFrom, envTo, err := envelope(from, m.To)
+	if err != nil {
+		return "", err
+	}
+	now := q.cfg.Now()
+	msgID := newID() + "@mailqueue"
+	raw, err := build(m, from, msgID, now)
+	if err != nil {
+		return "", err
+	}
+
+	batch := make([]*Delivery, 0, len(m.To))
+	for _, to := range envTo {
+		d := &Delivery{ID: newID(), MessageID: msgID, From: envFrom, To: to, Queued: now, Next: now, Raw: raw}
+		if err := q.write(queueDir, d); err != nil {
+			q.discard(batch)
+			return "", err
+		}
+		batch = append(batch, d)
+	}
+	if err := fsutil.SyncDir(filepath.Join(q.cfg.Dir, queueDir)); err != nil {
+		q.discard(batch)
+		return "", err
+	}
+
+	q.mu.Lock()
+	for _, d := range batch {
+		heap.Push(&q.pending, d)
+	}
+	q.mu.Unlock()
+	q.notify()
+	return msgID, nil
+}
+
+// Stats returns the current counts
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.stats
+	s.Pending = len(q.pending) + q.inFlight
+	s.InFlight = q.inFlight
+	return s
+}
+
+// Failed lists the deliveries that gave up, from the failed directory
+func (q *Queue) Failed() ([]Delivery, error) {
+	dir := filepath.Join(q.cfg.Dir, failedDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Delivery
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var d Delivery
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// Run sends due deliveries with cfg.Workers workers until ctx is done, then
+// waits for the attempts in flight. Deliveries interrupted by ctx stay queued
+// without using up an attempt.
+func (q *Queue) Run(ctx context.Context) {
+	// the buffered channel of emailSender, fed by a dispatcher that knows
+	// what is due instead of by the caller
+	jobs := make(chan *Delivery, q.cfg.Workers)
+	var wg sync.WaitGroup
+	for range q.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				q.attempt(ctx, d)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		d, wait := q.due()
+		if d == nil {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-q.wake:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		select {
+		case jobs <- d:
+		case <-ctx.Done():
+			q.requeue(d)
+			return
+		}
+	}
+}
+
+// due pops the next delivery whose time has come, or says how long until one will
+func (q *Queue) due() (*Delivery, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil, time.Hour
+	}
+	if wait := q.pending[0].Next.Sub(q.cfg.Now()); wait > 0 {
+		return nil, wait
+	}
+	q.inFlight++
+	return heap.Pop(&q.pending).(*Delivery), 0
+}
+
+// requeue returns a popped delivery untouched
+func (q *Queue) requeue(d *Delivery) {
+	q.mu.Lock()
+	q.inFlight--
+	heap.Push(&q.pending, d)
+	q.mu.Unlock()
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) attempt(ctx context.Context, d *Delivery) {
+	if ctx.Err() != nil {
+		q.requeue(d)
+		return
+	}
+	actx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	err := q.cfg.Sender.Send(actx, d.From, d.To, d.Raw)
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		// shutting down, not the recipient's fault
+		q.requeue(d)
+		return
+	}
+
+	d.Attempts++
+	path := filepath.Join(q.cfg.Dir, queueDir, d.ID+".json")
+	switch {
+	case err == nil:
+		// if this fails the mail goes out again after a restart, which is
+		// the at-least-once SMTP gives us anyway
+		os.Remove(path)
+		q.finish(func(s *Stats) { s.Sent++ })
+		if q.cfg.OnSent != nil {
+			q.cfg.OnSent(*d)
+		}
+
+	case Permanent(err) || d.Attempts >= q.cfg.MaxAttempts:
+		d.LastError = err.Error()
+		if werr := q.write(failedDir, d); werr == nil {
+			os.Remove(path)
+		}
+		q.finish(func(s *Stats) { s.Failed++ })
+		if q.cfg.OnFailed != nil {
+			q.cfg.OnFailed(*d, err)
+		}
+
+	default:
+		wait := q.backoff(d.Attempts)
+		d.LastError = err.Error()
+		d.Next = q.cfg.Now().Add(wait)
+		// if this write fails the file keeps the previous attempt count,
+		// which only means one extra try after a restart
+		q.write(queueDir, d)
+		q.mu.Lock()
+		q.inFlight--
+		q.stats.Retried++
+		heap.Push(&q.pending, d)
+		q.mu.Unlock()
+		q.notify()
+		if q.cfg.OnRetry != nil {
+			q.cfg.OnRetry(*d, wait, err)
+		}
+	}
+}
+
+func (q *Queue) finish(count func(*Stats)) {
+	q.mu.Lock()
+	q.inFlight--
+	count(&q.stats)
+	q.mu.Unlock()
+}
+
+func (q *Queue) backoff(attempt int) time.Duration {
+	wait := q.cfg.BaseDelay << (attempt - 1)
+	if wait > q.cfg.MaxDelay || wait <= 0 {
+		wait = q.cfg.MaxDelay
+	}
+	return wait
+}
+
+// discard removes the deliveries of an Enqueue that failed, so a restart
+// does not send a message the caller was told was not queued
+func (q *Queue) discard(batch []*Delivery) {
+	for _, d := range batch {
+		os.Remove(filepath.Join(q.cfg.Dir, queueDir, d.ID+".json"))
+	}
+}
+
+func (q *Queue) write(dir string, d *Delivery) error {
+	return fsutil.WriteFileAtomic(filepath.Join(q.cfg.Dir, dir, d.ID+".json"), 0o600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(d)
+	})
+}
+
+// newID sorts by creation time, which keeps the queue directory in FIFO order
+func newID() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%d.%s", time.Now().UnixNano(), hex.EncodeToString(b[:]))
+}
+
+// deliveryHeap orders deliveries by when they are due
+type deliveryHeap []*Delivery
+
+func (h deliveryHeap) Len() int { return len(h) }
+func (h deliveryHeap) Less(i, j int) bool {
+	if h[i].Next.Equal(h[j].Next) {
+		return h[i].ID < h[j].ID
+	}
+	return h[i].Next.Before(h[j].Next)
+}
+func (h deliveryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *deliveryHeap) Push(x any)   { *h = append(*h, x.(*Delivery)) }
+func (h *deliveryHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
@@ -0,0 +1,81 @@
+package mailqueue
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/smtpsend"
+)
+
+// Sender delivers one message to one recipient
+type Sender interface {
+	Send(ctx context.Context, from, to string, msg []byte) error
+}
+
+// TLSPolicy says what to do about STARTTLS
+type TLSPolicy = smtpsend.TLSPolicy
+
+const (
+	// TLSOpportunistic upgrades when the server offers STARTTLS
+	TLSOpportunistic = smtpsend.TLSOpportunistic
+	// TLSRequired refuses to send over a connection that could not be upgraded
+	TLSRequired = smtpsend.TLSRequired
+	// TLSDisabled never upgrades
+	TLSDisabled = smtpsend.TLSDisabled
+)
+
+var (
+	// ErrNoStartTLS is returned under TLSRequired when the server does not offer STARTTLS
+	ErrNoStartTLS = smtpsend.ErrNoStartTLS
+	// ErrNoAuth is returned when Username is set and the server does not offer AUTH
+	ErrNoAuth = smtpsend.ErrNoAuth
+)
+
+// SMTP sends through one server with net/smtp, a new connection per message
+type SMTP struct {
+	Addr      string // host:port
+	Host      string // name the certificate and PLAIN auth are checked against, default the host of Addr
+	HelloName string // default localhost
+	TLS       *tls.Config
+	StartTLS  TLSPolicy
+	// Username and Password enable AUTH PLAIN. net/smtp refuses to send them
+	// over an unencrypted connection to anything but localhost.
+	Username, Password string
+	Timeout            time.Duration // whole conversation, default 30s
+}
+
+// Send implements Sender. ctx cancellation closes the connection.
+func (s SMTP) Send(ctx context.Context, from, to string, msg []byte) (err error) {
+	cfg := smtpsend.Config{Addr: s.Addr, Host: s.Host, HelloName: s.HelloName, TLS: s.TLS, StartTLS: s.StartTLS, Timeout: s.Timeout}
+	if s.Username != "" {
+		if cfg.Host == "" {
+			if cfg.Host, _, err = net.SplitHostPort(s.Addr); err != nil {
+				return err
+			}
+		}
+		cfg.Auth = smtp.PlainAuth("", s.Username, s.Password, cfg.Host)
+	}
+	return smtpsend.Send(ctx, cfg, from, []string{to}, msg)
+}
+
+// Permanent reports whether retrying err is pointless: a 5xx reply such as an
+// unknown recipient. Everything else, 4xx replies and network trouble
+// included, is worth another attempt.
+func Permanent(err error) bool {
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return te.Code >= 500
+	}
+	var pe permanentError
+	return errors.As(err, &pe)
+}
+
+// permanentError marks failures of our own that no retry can fix
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
@@ -0,0 +1,76 @@
+package mailqueue
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Content is a rendered message
+type Content struct {
+	Subject string
+	Text    string
+	HTML    string // optional; sent as multipart/alternative with Text
+}
+
+// Template renders Content from data. Subject and text use text/template,
+// the HTML part html/template so data is escaped.
+type Template struct {
+	name    string
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// ParseTemplate parses the three parts; html may be empty
+func ParseTemplate(name, subject, text, html string) (*Template, error) {
+	t := &Template{name: name}
+	var err error
+	if t.subject, err = texttemplate.New(name + ".subject").Option("missingkey=error").Parse(subject); err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	if t.text, err = texttemplate.New(name + ".txt").Option("missingkey=error").Parse(text); err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New(name + ".html").Option("missingkey=error").Parse(html); err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+// MustParseTemplate is ParseTemplate for templates known at compile time
+func MustParseTemplate(name, subject, text, html string) *Template {
+	t, err := ParseTemplate(name, subject, text, html)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the template with data
+func (t *Template) Render(data any) (Content, error) {
+	var c Content
+	var b bytes.Buffer
+	if err := t.subject.Execute(&b, data); err != nil {
+		return c, fmt.Errorf("template %s: %w", t.name, err)
+	}
+	// a newline in the subject would start a new header
+	c.Subject = strings.Join(strings.Fields(b.String()), " ")
+	b.Reset()
+	if err := t.text.Execute(&b, data); err != nil {
+		return c, fmt.Errorf("template %s: %w", t.name, err)
+	}
+	c.Text = b.String()
+	if t.html != nil {
+		b.Reset()
+		if err := t.html.Execute(&b, data); err != nil {
+			return c, fmt.Errorf("template %s: %w", t.name, err)
+		}
+		c.HTML = b.String()
+	}
+	return c, nil
+}
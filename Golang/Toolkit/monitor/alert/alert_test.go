@@ -0,0 +1,247 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/smtpsend"
+	"github.com/Shehbab-Kakkar/toolkit/internal/smtptest"
+	"github.com/Shehbab-Kakkar/toolkit/monitor"
+)
+
+// recorder collects alerts as "kind:target"
+type recorder struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (r *recorder) Notify(_ context.Context, a Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, string(a.Kind)+":"+a.Target)
+	return nil
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.got, ",")
+}
+
+func status(state monitor.State, failures int) monitor.Status {
+	return monitor.Status{Name: "api", URL: "http://api", State: state, ConsecutiveFailures: failures}
+}
+
+func TestDedupEscalationAndRecovery(t *testing.T) {
+	var primary, oncall recorder
+	m := NewManager(Policy{
+		Notifiers:     []Notifier{&primary},
+		Escalation:    []Notifier{&oncall},
+		EscalateAfter: 4,
+	})
+	ctx := context.Background()
+
+	m.Observe(ctx, status(monitor.Up, 0))
+	for f := 2; f <= 6; f++ {
+		m.Observe(ctx, status(monitor.Down, f))
+	}
+	m.Observe(ctx, status(monitor.Up, 0))
+	m.Observe(ctx, status(monitor.Up, 0))
+
+	if got := primary.String(); got != "down:api,recovered:api" {
+		t.Errorf("primary got %s", got)
+	}
+	if got := oncall.String(); got != "escalated:api,recovered:api" {
+		t.Errorf("escalation got %s", got)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	var primary recorder
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(Policy{Notifiers: []Notifier{&primary}, Repeat: time.Hour})
+	m.now = func() time.Time { return now }
+
+	for range 4 {
+		m.Observe(context.Background(), status(monitor.Down, 2))
+		now = now.Add(30 * time.Minute)
+	}
+	if got := primary.String(); got != "down:api,down:api" {
+		t.Fatalf("expected one reminder after an hour, got %s", got)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	q, err := ParseQuietHours("22:00-07:00", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "03:00": true, "06:59": true, "07:00": false} {
+		at, _ := time.Parse("15:04", clock)
+		if q.Contains(at) != want {
+			t.Errorf("%s: quiet=%v, want %v", clock, !want, want)
+		}
+	}
+	if _, err := ParseQuietHours("25:00-07:00", nil); err == nil {
+		t.Error("expected an error for 25:00")
+	}
+
+	var primary, oncall recorder
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	m := NewManager(Policy{
+		Notifiers:     []Notifier{&primary},
+		Escalation:    []Notifier{&oncall},
+		EscalateAfter: 3,
+		Quiet:         q,
+	})
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	m.Observe(ctx, status(monitor.Down, 2))
+	m.Observe(ctx, status(monitor.Down, 3))
+	if primary.String() != "" || oncall.String() != "escalated:api" {
+		t.Fatalf("quiet hours must hold the down alert but not the escalation: %q %q", primary.String(), oncall.String())
+	}
+
+	now = now.Add(9 * time.Hour) // 08:00
+	m.Observe(ctx, status(monitor.Down, 40))
+	if primary.String() != "down:api" {
+		t.Fatalf("held alert should go out after quiet hours: %q", primary.String())
+	}
+
+	// an outage that starts and ends inside quiet hours stays silent
+	var other recorder
+	m = NewManager(Policy{Notifiers: []Notifier{&other}, Quiet: q})
+	now = time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.Observe(ctx, status(monitor.Down, 2))
+	m.Observe(ctx, status(monitor.Up, 0))
+	now = now.Add(9 * time.Hour)
+	m.Observe(ctx, status(monitor.Up, 0))
+	if other.String() != "" {
+		t.Fatalf("nobody was told about the outage, so no recovery either: %q", other.String())
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	a := Alert{
+		Kind:     KindDown,
+		Target:   "golang",
+		URL:      "https://www.google1.com",
+		At:       time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Failures: 2,
+		Problems: []string{"https://www.google1.com is DOWN! no such host"},
+	}
+	ctx := context.Background()
+
+	mail, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mail.Close()
+	email := Email{Addr: mail.Addr(), From: "monitor@example.com", To: []string{"ops@example.com", "dev@example.com"}}
+	if err := email.Notify(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	msgs := mail.Messages()
+	if len(msgs) != 1 || len(msgs[0].To) != 2 || !strings.Contains(msgs[0].Data, "Subject: [DOWN] golang is DOWN!") ||
+		!strings.Contains(msgs[0].Data, "- https://www.google1.com is DOWN! no such host") {
+		t.Fatalf("unexpected mail %+v", msgs)
+	}
+
+	// credentials the server does not take are an error, not silently dropped
+	email.Auth = smtp.PlainAuth("", "u", "p", "127.0.0.1")
+	if err := email.Notify(ctx, a); !errors.Is(err, smtpsend.ErrNoAuth) || len(mail.Messages()) != 1 {
+		t.Fatalf("AUTH not offered: %v", err)
+	}
+
+	// a server that accepts and never greets must not hang the notifier
+	hung, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hung.Close()
+	go func() {
+		for {
+			conn, err := hung.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := (Email{Addr: hung.Addr().String(), To: []string{"ops@example.com"}}).Notify(short, a); err == nil || time.Since(start) > 2*time.Second {
+		t.Fatalf("hung server: %v after %s", err, time.Since(start))
+	}
+
+	var mu sync.Mutex
+	bodies := map[string]map[string]any{}
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		json.NewDecoder(r.Body).Decode(&v)
+		mu.Lock()
+		bodies[r.URL.Path] = v
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer hooks.Close()
+
+	if err := (Webhook{URL: hooks.URL + "/hook"}).Notify(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Slack{WebhookURL: hooks.URL + "/slack", Channel: "#ops"}).Notify(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Webhook{URL: hooks.URL + "/broken"}).Notify(ctx, a); err == nil {
+		t.Fatal("a 500 from the webhook must be an error")
+	}
+
+	if hook := bodies["/hook"]; hook["kind"] != "down" || hook["target"] != "golang" || hook["failures"] != 2.0 {
+		t.Errorf("webhook payload %v", hook)
+	}
+	slack := bodies["/slack"]
+	attachments, _ := slack["attachments"].([]any)
+	if slack["text"] != "[DOWN] golang is DOWN!" || slack["channel"] != "#ops" || len(attachments) != 1 ||
+		attachments[0].(map[string]any)["color"] != "danger" {
+		t.Errorf("slack payload %v", slack)
+	}
+}
+
+func TestMonitorHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	var primary recorder
+	var failed []error
+	alerts := NewManager(Policy{Notifiers: []Notifier{&primary, NotifierFunc(func(context.Context, Alert) error {
+		return context.DeadlineExceeded
+	})}})
+	alerts.OnError = func(_ Alert, err error) { failed = append(failed, err) }
+
+	m, err := monitor.New(monitor.Config{Targets: []monitor.Target{{Name: "api", URL: srv.URL}}}, alerts.Hook())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CheckAll(context.Background())
+	m.CheckAll(context.Background())
+
+	if primary.String() != "down:api" || len(failed) != 1 {
+		t.Fatalf("alerts %q, delivery errors %v", primary.String(), failed)
+	}
+}
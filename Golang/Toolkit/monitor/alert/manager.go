@@ -0,0 +1,188 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/monitor"
+)
+
+// QuietHours is a daily window, possibly crossing midnight, in which down and
+// recovery alerts are held back. Escalations are always sent.
+type QuietHours struct {
+	Start, End time.Duration // offsets from local midnight
+	Location   *time.Location
+}
+
+// ParseQuietHours reads "22:00-07:00"
+func ParseQuietHours(s string, loc *time.Location) (*QuietHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	start, err := clock(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := clock(to)
+	if err != nil {
+		return nil, err
+	}
+	return &QuietHours{Start: start, End: end, Location: loc}, nil
+}
+
+func clock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || hh > 23 || mm < 0 || mm > 59 {
+		return 0, fmt.Errorf("bad time of day %q", s)
+	}
+	return time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+	if q.Location != nil {
+		t = t.In(q.Location)
+	}
+	y, mo, d := t.Date()
+	offset := t.Sub(time.Date(y, mo, d, 0, 0, 0, 0, t.Location()))
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// Policy decides who hears about what
+type Policy struct {
+	// Notifiers get down and recovery alerts
+	Notifiers []Notifier
+	// Escalation gets an alert once a target has failed EscalateAfter checks in
+	// a row, and its recovery. Zero EscalateAfter disables escalation.
+	Escalation    []Notifier
+	EscalateAfter int
+	// Repeat re-sends the down alert while the outage lasts; zero sends it once
+	Repeat time.Duration
+	Quiet  *QuietHours
+	// Timeout bounds each delivery
+	Timeout time.Duration
+}
+
+// Manager deduplicates monitor results into alerts
+type Manager struct {
+	policy Policy
+	// OnError is told about failed deliveries; nil drops them
+	OnError func(a Alert, err error)
+	now     func() time.Time
+
+	mu        sync.Mutex
+	incidents map[string]*incident
+}
+
+// incident is an outage we may have told someone about
+type incident struct {
+	since     time.Time // when the target went down
+	notified  bool      // the down alert went out
+	escalated bool
+	lastSent  time.Time
+}
+
+// NewManager returns a Manager applying policy
+func NewManager(policy Policy) *Manager {
+	if policy.Timeout <= 0 {
+		policy.Timeout = 10 * time.Second
+	}
+	return &Manager{policy: policy, now: time.Now, incidents: map[string]*incident{}}
+}
+
+// Hook adapts the Manager to monitor.OnCheck
+func (m *Manager) Hook() monitor.Option {
+	return monitor.OnCheck(func(t monitor.Target, s monitor.Status) {
+		m.Observe(context.Background(), s)
+	})
+}
+
+// Observe feeds one check result to the Manager. It relies on the monitor's
+// flap damping for when a target counts as down, sends each alert at most once
+// per outage (plus Repeat reminders) and returns once delivery is done.
+func (m *Manager) Observe(ctx context.Context, s monitor.Status) {
+	now := m.now()
+	quiet := m.policy.Quiet.Contains(now)
+	a := Alert{Target: s.Name, URL: s.URL, At: now, Since: s.Since, Failures: s.ConsecutiveFailures}
+	if s.Last != nil {
+		a.Problems = s.Last.Failures
+	}
+
+	var sends []send
+	m.mu.Lock()
+	inc := m.incidents[s.Name]
+	switch s.State {
+	case monitor.Down:
+		if inc == nil {
+			inc = &incident{since: s.Since}
+			m.incidents[s.Name] = inc
+		}
+		due := !inc.notified || (m.policy.Repeat > 0 && now.Sub(inc.lastSent) >= m.policy.Repeat)
+		if due && !quiet {
+			a.Kind = KindDown
+			sends = append(sends, send{a, m.policy.Notifiers})
+			inc.notified, inc.lastSent = true, now
+		}
+		if !inc.escalated && m.policy.EscalateAfter > 0 && s.ConsecutiveFailures >= m.policy.EscalateAfter {
+			a.Kind = KindEscalated
+			sends = append(sends, send{a, m.policy.Escalation})
+			inc.escalated = true
+		}
+	case monitor.Up:
+		// a recovery that lands in quiet hours waits for the next check after them
+		if inc != nil && !quiet {
+			a.Kind, a.Since = KindRecovered, inc.since
+			if inc.notified {
+				sends = append(sends, send{a, m.policy.Notifiers})
+			}
+			if inc.escalated {
+				sends = append(sends, send{a, m.policy.Escalation})
+			}
+			delete(m.incidents, s.Name)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sd := range sends {
+		m.deliver(ctx, sd)
+	}
+}
+
+type send struct {
+	alert Alert
+	to    []Notifier
+}
+
+// deliver sends to every notifier concurrently so one slow channel does not delay the rest
+func (m *Manager) deliver(ctx context.Context, sd send) {
+	ctx, cancel := context.WithTimeout(ctx, m.policy.Timeout)
+	defer cancel()
+
+	errs := make([]error, len(sd.to))
+	var wg sync.WaitGroup
+	for i, n := range sd.to {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.Notify(ctx, sd.alert)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil && m.OnError != nil {
+		m.OnError(sd.alert, err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package alert turns monitor results into notifications: one alert when a
+// target goes down, an escalation if it stays down, and a recovery when it
+// comes back, delivered through pluggable Notifiers.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/smtpsend"
+)
+
+// Kind is what happened to the target
+type Kind string
+
+const (
+	KindDown      Kind = "down"
+	KindEscalated Kind = "escalated"
+	KindRecovered Kind = "recovered"
+)
+
+// Alert is one notification
+type Alert struct {
+	Kind     Kind      `json:"kind"`
+	Target   string    `json:"target"`
+	URL      string    `json:"url"`
+	At       time.Time `json:"at"`
+	Since    time.Time `json:"since"` // when the target went down
+	Failures int       `json:"failures"`
+	Problems []string  `json:"problems,omitempty"`
+}
+
+// Subject is a one line summary
+func (a Alert) Subject() string {
+	switch a.Kind {
+	case KindRecovered:
+		return fmt.Sprintf("[RECOVERED] %s is up after %s", a.Target, a.At.Sub(a.Since).Round(time.Second))
+	case KindEscalated:
+		return fmt.Sprintf("[ESCALATED] %s is still DOWN after %d checks", a.Target, a.Failures)
+	default:
+		return fmt.Sprintf("[DOWN] %s is DOWN!", a.Target)
+	}
+}
+
+// Text is the subject followed by the URL and the failed assertions
+func (a Alert) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", a.Subject(), a.URL)
+	for _, p := range a.Problems {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	return b.String()
+}
+
+// Notifier delivers an Alert somewhere
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// NotifierFunc adapts a function to Notifier
+type NotifierFunc func(ctx context.Context, a Alert) error
+
+func (f NotifierFunc) Notify(ctx context.Context, a Alert) error { return f(ctx, a) }
+
+// Email sends alerts through an SMTP server, upgrading to STARTTLS when the
+// server offers it as smtp.SendMail does. With Auth set, a server that does
+// not offer AUTH is an error rather than an unauthenticated send.
+type Email struct {
+	Addr string // host:port
+	Auth smtp.Auth
+	From string
+	To   []string
+	// Timeout bounds each send when ctx has no earlier deadline, default 30s
+	Timeout time.Duration
+}
+
+// Notify implements Notifier. ctx bounds the whole SMTP conversation, so a
+// server that stops answering can't hold up the caller.
+func (e Email) Notify(ctx context.Context, a Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", a.Subject())
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.At.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(a.Text(), "\n", "\r\n"))
+	cfg := smtpsend.Config{Addr: e.Addr, Auth: e.Auth, Timeout: e.Timeout}
+	if err := smtpsend.Send(ctx, cfg, e.From, e.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("email %s: %w", a.Target, err)
+	}
+	return nil
+}
+
+// Webhook POSTs the Alert as JSON
+type Webhook struct {
+	URL    string
+	Client *http.Client // nil means http.DefaultClient
+}
+
+func (w Webhook) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.Client, w.URL, a)
+}
+
+// Slack posts to a Slack incoming webhook, or anything that accepts the same payload
+type Slack struct {
+	WebhookURL string
+	Channel    string // optional override of the webhook's channel
+	Client     *http.Client
+}
+
+// slackMessage is the subset of the incoming webhook payload we use
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string `json:"color"`
+	Title  string `json:"title"`
+	Text   string `json:"text,omitempty"`
+	Footer string `json:"footer,omitempty"`
+	Ts     int64  `json:"ts"`
+}
+
+func (s Slack) Notify(ctx context.Context, a Alert) error {
+	color := "danger"
+	switch a.Kind {
+	case KindRecovered:
+		color = "good"
+	case KindEscalated:
+		color = "#8b0000"
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, slackMessage{
+		Channel: s.Channel,
+		Text:    a.Subject(),
+		Attachments: []slackAttachment{{
+			Color:  color,
+			Title:  a.URL,
+			Text:   strings.Join(a.Problems, "\n"),
+			Footer: fmt.Sprintf("%d consecutive failures", a.Failures),
+			Ts:     a.At.Unix(),
+		}},
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("POST %s: status %d", url, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxBody bounds how much of a page the body assertions look at
+const maxBody = 1 << 20
+
+// Result is the outcome of one check
+type Result struct {
+	Time       time.Time  `json:"time"`
+	StatusCode int        `json:"status_code,omitempty"`
+	Latency    Duration   `json:"latency"`
+	TLSExpiry  *time.Time `json:"tls_expiry,omitempty"`
+	// Failures lists every assertion that did not hold; empty means the check passed
+	Failures []string `json:"failures,omitempty"`
+}
+
+// OK reports whether every assertion held
+func (r Result) OK() bool { return len(r.Failures) == 0 }
+
+func (r *Result) fail(format string, args ...any) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// Check runs one check of t
+func Check(ctx context.Context, client *http.Client, t Target) Result {
+	res := Result{Time: time.Now()}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.Timeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		res.fail("%v", err)
+		return res
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Latency = Duration(time.Since(res.Time))
+		res.fail("%s is DOWN! %v", t.URL, err)
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	if !statusOK(resp.StatusCode, t.ExpectStatus) {
+		res.fail("status %d, want %s", resp.StatusCode, expected(t.ExpectStatus))
+	}
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		notAfter := resp.TLS.PeerCertificates[0].NotAfter
+		res.TLSExpiry = &notAfter
+		if left := time.Until(notAfter); t.TLSExpiry > 0 && left < time.Duration(t.TLSExpiry) {
+			res.fail("certificate expires in %s", left.Round(time.Hour))
+		}
+	}
+
+	if t.BodyContains != "" || t.bodyRE != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+		if err != nil {
+			res.fail("read body: %v", err)
+		}
+		if t.BodyContains != "" && !strings.Contains(string(body), t.BodyContains) {
+			res.fail("body does not contain %q", t.BodyContains)
+		}
+		if t.bodyRE != nil && !t.bodyRE.Match(body) {
+			res.fail("body does not match %q", t.BodyMatches)
+		}
+	}
+
+	// latency covers the body read when there are body assertions
+	res.Latency = Duration(time.Since(res.Time))
+	if t.MaxLatency > 0 && res.Latency > t.MaxLatency {
+		res.fail("latency %s over %s", time.Duration(res.Latency).Round(time.Millisecond), time.Duration(t.MaxLatency))
+	}
+	return res
+}
+
+func statusOK(code int, want []int) bool {
+	if len(want) == 0 {
+		return code >= 200 && code <= 299
+	}
+	return slices.Contains(want, code)
+}
+
+func expected(want []int) string {
+	if len(want) == 0 {
+		return "2xx"
+	}
+	return fmt.Sprint(want)
+}
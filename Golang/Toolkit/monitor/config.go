@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Duration reads "30s" style strings from JSON
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Target is one URL to watch. Zero values fall back to the Config defaults.
+type Target struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Interval     Duration `json:"interval,omitempty"`
+	Timeout      Duration `json:"timeout,omitempty"`
+	ExpectStatus []int    `json:"expect_status,omitempty"` // default any 2xx
+	BodyContains string   `json:"body_contains,omitempty"`
+	BodyMatches  string   `json:"body_matches,omitempty"` // regular expression
+	MaxLatency   Duration `json:"max_latency,omitempty"`
+	// TLSExpiry fails the check when the certificate expires sooner than this
+	TLSExpiry Duration `json:"tls_expiry,omitempty"`
+
+	bodyRE *regexp.Regexp
+}
+
+// Config is the monitor configuration file
+type Config struct {
+	Interval Duration `json:"interval"`
+	Timeout  Duration `json:"timeout"`
+	// FailAfter consecutive failures mark an up target down
+	FailAfter int `json:"fail_after"`
+	// RecoverAfter consecutive successes mark a down target up
+	RecoverAfter int `json:"recover_after"`
+	// History is how many recent checks are kept per target
+	History int      `json:"history"`
+	Targets []Target `json:"targets"`
+}
+
+// DefaultConfig checks every minute and needs two results in a row to change state
+var DefaultConfig = Config{
+	Interval:     Duration(time.Minute),
+	Timeout:      Duration(10 * time.Second),
+	FailAfter:    2,
+	RecoverAfter: 2,
+	History:      20,
+}
+
+// LoadConfig reads a JSON config file on top of DefaultConfig
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := DefaultConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// normalize fills defaults and compiles the body patterns
+func (c *Config) normalize() error {
+	if c.Interval <= 0 {
+		c.Interval = DefaultConfig.Interval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultConfig.Timeout
+	}
+	c.FailAfter = max(c.FailAfter, 1)
+	c.RecoverAfter = max(c.RecoverAfter, 1)
+	if c.History <= 0 {
+		c.History = DefaultConfig.History
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.URL == "" {
+			errs = append(errs, fmt.Errorf("target %d: url is required", i))
+			continue
+		}
+		if t.Name == "" {
+			t.Name = t.URL
+		}
+		if seen[t.Name] {
+			errs = append(errs, fmt.Errorf("target %q: duplicate name", t.Name))
+		}
+		seen[t.Name] = true
+		if t.Interval <= 0 {
+			t.Interval = c.Interval
+		}
+		if t.Timeout <= 0 {
+			t.Timeout = c.Timeout
+		}
+		if t.BodyMatches != "" {
+			re, err := regexp.Compile(t.BodyMatches)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("target %q: body_matches: %w", t.Name, err))
+			}
+			t.bodyRE = re
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// StatusReport is the body of GET /api/status
+type StatusReport struct {
+	Up      int      `json:"up"`
+	Down    int      `json:"down"`
+	Unknown int      `json:"unknown"`
+	Targets []Status `json:"targets"`
+}
+
+// Handler serves
+//
+//	GET /                    HTML status page
+//	GET /api/status          every target; 503 while any target is down
+//	GET /api/status/{name}   one target with its recent history
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", m.page)
+	mux.HandleFunc("GET /api/status", m.list)
+	mux.HandleFunc("GET /api/status/{name...}", m.get)
+	return mux
+}
+
+func (m *Monitor) report() StatusReport {
+	r := StatusReport{Targets: m.Statuses()}
+	for i := range r.Targets {
+		switch r.Targets[i].State {
+		case Up:
+			r.Up++
+		case Down:
+			r.Down++
+		default:
+			r.Unknown++
+		}
+		// the list stays small; ask for one target to see its history
+		r.Targets[i].History = nil
+	}
+	return r
+}
+
+func (m *Monitor) list(w http.ResponseWriter, r *http.Request) {
+	rep := m.report()
+	code := http.StatusOK
+	if rep.Down > 0 {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, rep)
+}
+
+func (m *Monitor) get(w http.ResponseWriter, r *http.Request) {
+	s, ok := m.Status(r.PathValue("name"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such target"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (m *Monitor) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(w, m.report()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+var pageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="15">
+<title>Status: {{.Up}} up, {{.Down}} down</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: .4em .8em; border-bottom: 1px solid #ddd; text-align: left; }
+.up { color: #1a7f37; } .down { color: #cf222e; } .unknown { color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>{{.Up}} up, {{.Down}} down, {{.Unknown}} unknown</h1>
+<table>
+<tr><th>Target</th><th>State</th><th>Since</th><th>Status</th><th>Latency</th><th>Uptime</th><th>Problems</th></tr>
+{{range .Targets}}
+<tr>
+<td><a href="{{.URL}}">{{.Name}}</a></td>
+<td class="{{.State}}">{{.State}}</td>
+<td>{{ago .Since}}</td>
+{{with .Last}}<td>{{.StatusCode}}</td><td>{{.Latency}}</td>{{else}}<td>-</td><td>-</td>{{end}}
+<td>{{printf "%.1f%%" .Uptime}}</td>
+<td>{{with .Last}}{{range .Failures}}{{.}}<br>{{end}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,239 @@
+// Package monitor is a long-running version of the "%s is DOWN!" checker: it
+// checks each target on its own interval, damps flapping before it changes a
+// target's state and serves the results as JSON and as an HTML page.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// State is whether a target is considered up
+type State int
+
+const (
+	Unknown State = iota
+	Up
+	Down
+)
+
+func (s State) String() string {
+	switch s {
+	case Up:
+		return "up"
+	case Down:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+func (s State) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+// Status is what the monitor knows about one target
+type Status struct {
+	Name                 string    `json:"name"`
+	URL                  string    `json:"url"`
+	State                State     `json:"state"`
+	Since                time.Time `json:"since"` // when State was entered
+	Last                 *Result   `json:"last,omitempty"`
+	ConsecutiveFailures  int       `json:"consecutive_failures"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes"`
+	Checks               int       `json:"checks"`
+	Passed               int       `json:"passed"`
+	History              []Result  `json:"history,omitempty"` // oldest first
+}
+
+// Uptime is the percentage of checks that passed
+func (s Status) Uptime() float64 {
+	if s.Checks == 0 {
+		return 0
+	}
+	return 100 * float64(s.Passed) / float64(s.Checks)
+}
+
+// Transition is reported when a target changes State
+type Transition struct {
+	Target Target
+	From   State
+	To     State
+	At     time.Time
+	Result Result // the check that caused it
+	// Failures is ConsecutiveFailures at the time, useful for escalation
+	Failures int
+}
+
+// Monitor checks a set of targets
+type Monitor struct {
+	cfg          Config
+	client       *http.Client
+	onTransition []func(Transition)
+	onCheck      []func(Target, Status)
+
+	mu     sync.RWMutex
+	status map[string]*Status
+}
+
+// Option configures a Monitor
+type Option func(*Monitor)
+
+// WithClient sets the HTTP client used for checks
+func WithClient(c *http.Client) Option { return func(m *Monitor) { m.client = c } }
+
+// OnTransition registers fn for every state change. It is called outside any
+// lock, possibly concurrently for different targets.
+func OnTransition(fn func(Transition)) Option {
+	return func(m *Monitor) { m.onTransition = append(m.onTransition, fn) }
+}
+
+// OnCheck registers fn to be called after every recorded check with the
+// target's updated Status, without its History. Like OnTransition it runs
+// outside any lock.
+func OnCheck(fn func(Target, Status)) Option {
+	return func(m *Monitor) { m.onCheck = append(m.onCheck, fn) }
+}
+
+// New validates cfg and returns a Monitor with every target in the Unknown state
+func New(cfg Config, opts ...Option) (*Monitor, error) {
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+	m := &Monitor{
+		cfg: cfg,
+		// checks must see redirects and status codes as the target returns them
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+		status: make(map[string]*Status, len(cfg.Targets)),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, t := range cfg.Targets {
+		m.status[t.Name] = &Status{Name: t.Name, URL: t.URL}
+	}
+	return m, nil
+}
+
+// Run checks every target immediately and then on its interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range m.cfg.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(time.Duration(t.Interval))
+			defer ticker.Stop()
+			for {
+				m.check(ctx, t)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// CheckAll checks every target once, concurrently, and returns when all are done
+func (m *Monitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range m.cfg.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.check(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) check(ctx context.Context, t Target) {
+	r := Check(ctx, m.client, t)
+	if ctx.Err() != nil {
+		// shutting down is not an outage
+		return
+	}
+	m.record(t, r)
+}
+
+// record applies r to the target's Status. An up target goes down after
+// FailAfter failures in a row and a down one comes back after RecoverAfter
+// passes in a row, so a single blip does not flip the state back and forth.
+// The first result decides the initial state straight away.
+func (m *Monitor) record(t Target, r Result) {
+	m.mu.Lock()
+	s := m.status[t.Name]
+	s.Checks++
+	s.Last = &r
+	s.History = append(s.History, r)
+	if over := len(s.History) - m.cfg.History; over > 0 {
+		s.History = append(s.History[:0], s.History[over:]...)
+	}
+
+	next := s.State
+	if r.OK() {
+		s.Passed++
+		s.ConsecutiveSuccesses++
+		s.ConsecutiveFailures = 0
+		if s.State == Unknown || (s.State == Down && s.ConsecutiveSuccesses >= m.cfg.RecoverAfter) {
+			next = Up
+		}
+	} else {
+		s.ConsecutiveFailures++
+		s.ConsecutiveSuccesses = 0
+		if s.State == Unknown || (s.State == Up && s.ConsecutiveFailures >= m.cfg.FailAfter) {
+			next = Down
+		}
+	}
+
+	var tr *Transition
+	if next != s.State {
+		tr = &Transition{Target: t, From: s.State, To: next, At: r.Time, Result: r, Failures: s.ConsecutiveFailures}
+		s.State, s.Since = next, r.Time
+	}
+	snapshot := *s
+	snapshot.History = nil
+	m.mu.Unlock()
+
+	if tr != nil {
+		for _, fn := range m.onTransition {
+			fn(*tr)
+		}
+	}
+	for _, fn := range m.onCheck {
+		fn(t, snapshot)
+	}
+}
+
+// Statuses returns a copy of every target's Status in config order
+func (m *Monitor) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Status, 0, len(m.cfg.Targets))
+	for _, t := range m.cfg.Targets {
+		out = append(out, m.copyStatus(t.Name))
+	}
+	return out
+}
+
+// Status returns a copy of one target's Status
+func (m *Monitor) Status(name string) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.status[name]; !ok {
+		return Status{}, false
+	}
+	return m.copyStatus(name), true
+}
+
+func (m *Monitor) copyStatus(name string) Status {
+	s := *m.status[name]
+	s.History = append([]Result(nil), s.History...)
+	return s
+}
@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckAssertions(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(30 * time.Millisecond)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		}
+		w.Write([]byte("build 1234 ok"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		target Target
+		fail   string
+	}{
+		{"pass", Target{URL: srv.URL, BodyContains: "ok", BodyMatches: `build \d+`}, ""},
+		{"status", Target{URL: srv.URL + "/gone"}, "status 410"},
+		{"expected status", Target{URL: srv.URL + "/gone", ExpectStatus: []int{410}}, ""},
+		{"substring", Target{URL: srv.URL, BodyContains: "healthy"}, "does not contain"},
+		{"regex", Target{URL: srv.URL, BodyMatches: `^version`}, "does not match"},
+		{"latency", Target{URL: srv.URL + "/slow", MaxLatency: Duration(time.Millisecond)}, "latency"},
+		// the test certificate is valid for years, so ask for more than that
+		{"tls expiry", Target{URL: srv.URL, TLSExpiry: Duration(200 * 365 * 24 * time.Hour)}, "certificate expires"},
+		{"down", Target{URL: "http://127.0.0.1:1"}, "is DOWN!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Targets: []Target{tt.target}}
+			if err := cfg.normalize(); err != nil {
+				t.Fatal(err)
+			}
+			r := Check(context.Background(), srv.Client(), cfg.Targets[0])
+			got := strings.Join(r.Failures, "; ")
+			if tt.fail == "" && !r.OK() {
+				t.Fatalf("expected pass, got %s", got)
+			}
+			if tt.fail != "" && !strings.Contains(got, tt.fail) {
+				t.Fatalf("expected failure %q, got %q", tt.fail, got)
+			}
+		})
+	}
+
+	r := Check(context.Background(), srv.Client(), Target{URL: srv.URL, Timeout: Duration(time.Second)})
+	if r.TLSExpiry == nil {
+		t.Fatal("TLS expiry should be reported for https targets")
+	}
+}
+
+func TestFlapDamping(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var transitions []string
+	m, err := New(Config{
+		FailAfter:    3,
+		RecoverAfter: 2,
+		Targets:      []Target{{Name: "api", URL: srv.URL}},
+	}, OnTransition(func(tr Transition) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, tr.From.String()+">"+tr.To.String())
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// up, then a blip of two failures that must not flip the state
+	for _, ok := range []bool{true, false, false, true, false, false, false, true, false, true, true} {
+		healthy.Store(ok)
+		m.CheckAll(context.Background())
+	}
+
+	want := "unknown>up,up>down,down>up"
+	if got := strings.Join(transitions, ","); got != want {
+		t.Fatalf("transitions %s, want %s", got, want)
+	}
+	s, _ := m.Status("api")
+	if s.State != Up || s.Checks != 11 || s.Passed != 5 || s.ConsecutiveSuccesses != 2 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
+
+func TestStatusAPI(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer up.Close()
+
+	m, err := New(Config{History: 2, Targets: []Target{
+		{Name: "up", URL: up.URL},
+		{Name: "down", URL: "http://127.0.0.1:1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		m.CheckAll(context.Background())
+	}
+	api := httptest.NewServer(m.Handler())
+	defer api.Close()
+
+	res, err := http.Get(api.URL + "/api/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep StatusReport
+	json.NewDecoder(res.Body).Decode(&rep)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || rep.Up != 1 || rep.Down != 1 || len(rep.Targets) != 2 {
+		t.Fatalf("%d %+v", res.StatusCode, rep)
+	}
+
+	res, err = http.Get(api.URL + "/api/status/up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var one map[string]any
+	json.NewDecoder(res.Body).Decode(&one)
+	res.Body.Close()
+	if one["state"] != "up" || len(one["history"].([]any)) != 2 {
+		t.Fatalf("single target: %v", one)
+	}
+
+	res, err = http.Get(api.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "1 up, 1 down") || !strings.Contains(string(page), `class="down"`) {
+		t.Fatalf("page:\n%s", page)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	_, err := New(Config{Targets: []Target{
+		{Name: "a", URL: "http://a"},
+		{Name: "a", URL: "http://b"},
+		{Name: "c"},
+		{Name: "d", URL: "http://d", BodyMatches: "("},
+	}})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"duplicate name", "url is required", "body_matches"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package pager walks paginated JSON list endpoints lazily as a Go 1.23 iterator.
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+)
+
+// Pager fetches pages of a JSON array endpoint
+type Pager[T any] struct {
+	client   *http.Client
+	url      *url.URL
+	err      error
+	strategy Strategy
+	prefetch int
+	header   http.Header
+}
+
+// Option configures a Pager
+type Option func(*options)
+
+type options struct {
+	prefetch int
+	header   http.Header
+}
+
+// WithPrefetch fetches up to n pages ahead of the consumer in a background goroutine
+func WithPrefetch(n int) Option {
+	return func(o *options) { o.prefetch = n }
+}
+
+// WithHeader sets a header on every page request, e.g. Authorization
+func WithHeader(key, value string) Option {
+	return func(o *options) { o.header.Set(key, value) }
+}
+
+// New returns a Pager for rawURL. A nil client means http.DefaultClient.
+func New[T any](client *http.Client, rawURL string, strategy Strategy, opts ...Option) *Pager[T] {
+	o := options{header: http.Header{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u, err := url.Parse(rawURL)
+	return &Pager[T]{client: client, url: u, err: err, strategy: strategy, prefetch: o.prefetch, header: o.header}
+}
+
+// All yields every item of every page. Iteration stops at the first error,
+// which is yielded once with a zero item.
+func (p *Pager[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for page, err := range p.Pages(ctx) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pages yields one slice per fetched page
+func (p *Pager[T]) Pages(ctx context.Context) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		if p.err != nil {
+			yield(nil, p.err)
+			return
+		}
+		if p.prefetch > 0 {
+			p.prefetched(ctx, yield)
+			return
+		}
+
+		u := p.strategy.First(p.url)
+		for {
+			items, next, more, err := p.fetch(ctx, u)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(items, nil) || !more {
+				return
+			}
+			u = next
+		}
+	}
+}
+
+type pageResult[T any] struct {
+	items []T
+	last  bool
+	err   error
+}
+
+// prefetched runs the fetch loop in a goroutine that stays up to p.prefetch pages ahead.
+// The goroutine always exits before prefetched returns.
+func (p *Pager[T]) prefetched(parent context.Context, yield func([]T, error) bool) {
+	ctx, cancel := context.WithCancel(parent)
+	results := make(chan pageResult[T], p.prefetch)
+	go func() {
+		defer close(results)
+		u := p.strategy.First(p.url)
+		for {
+			items, next, more, err := p.fetch(ctx, u)
+			select {
+			case results <- pageResult[T]{items, !more, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil || !more {
+				return
+			}
+			u = next
+		}
+	}()
+	defer func() {
+		cancel()
+		for range results {
+		}
+	}()
+
+	for res := range results {
+		if res.err != nil {
+			yield(nil, res.err)
+			return
+		}
+		if !yield(res.items, nil) || res.last {
+			return
+		}
+	}
+	// the producer stopped on cancellation without sending the error
+	if err := parent.Err(); err != nil {
+		yield(nil, err)
+	}
+}
+
+// fetch gets one page and asks the strategy for the next URL
+func (p *Pager[T]) fetch(ctx context.Context, u *url.URL) ([]T, *url.URL, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for k, v := range p.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+		return nil, nil, false, fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+	var items []T
+	if err := json.NewDecoder(res.Body).Decode(&items); err != nil {
+		return nil, nil, false, fmt.Errorf("decode page %s: %w", u, err)
+	}
+
+	next, more := p.strategy.Next(u, res, len(items))
+	return items, next, more, nil
+}
@@ -0,0 +1,176 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// numbers serves the integers 1..total as JSON arrays in every paging style
+func numbers(total int, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		q := r.URL.Query()
+		var start, size int
+		switch {
+		case q.Has("page"):
+			page, _ := strconv.Atoi(q.Get("page"))
+			size, _ = strconv.Atoi(q.Get("per_page"))
+			start = (page - 1) * size
+			if start+size < total {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d&per_page=%d>; rel="next", <%s?page=1>; rel="first"`,
+					r.URL.Path, page+1, size, r.URL.Path))
+			}
+		case q.Has("offset"):
+			start, _ = strconv.Atoi(q.Get("offset"))
+			size, _ = strconv.Atoi(q.Get("limit"))
+		}
+		items := []int{}
+		for i := start + 1; i <= min(start+size, total); i++ {
+			items = append(items, i)
+		}
+		fmt.Fprint(w, jsonInts(items))
+	}))
+}
+
+func jsonInts(items []int) string {
+	s := "["
+	for i, v := range items {
+		if i > 0 {
+			s += ","
+		}
+		s += strconv.Itoa(v)
+	}
+	return s + "]"
+}
+
+func collect(t *testing.T, p *Pager[int]) []int {
+	t.Helper()
+	var got []int
+	for v, err := range p.All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	return got
+}
+
+func TestStrategies(t *testing.T) {
+	var requests atomic.Int32
+	srv := numbers(23, &requests)
+	defer srv.Close()
+
+	strategies := map[string]Strategy{
+		"page":   PageNumber("page", "per_page", 5),
+		"offset": Offset("offset", "limit", 5),
+		"link":   LinkHeader(),
+	}
+	for name, s := range strategies {
+		for _, prefetch := range []int{0, 2} {
+			t.Run(fmt.Sprintf("%s/prefetch=%d", name, prefetch), func(t *testing.T) {
+				base := srv.URL + "/items"
+				if name == "link" {
+					base += "?page=1&per_page=5"
+				}
+				got := collect(t, New[int](srv.Client(), base, s, WithPrefetch(prefetch)))
+				if len(got) != 23 || got[0] != 1 || got[22] != 23 {
+					t.Fatalf("got %v", got)
+				}
+			})
+		}
+	}
+}
+
+func TestNonPositiveSizeStops(t *testing.T) {
+	var requests atomic.Int32
+	srv := numbers(45, &requests)
+	defer srv.Close()
+
+	for _, s := range []Strategy{PageNumber("page", "per_page", 0), Offset("offset", "limit", -1)} {
+		requests.Store(0)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var got []int
+		for v, err := range New[int](srv.Client(), srv.URL, s).All(ctx) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, v)
+		}
+		cancel()
+		if len(got) != 45 || requests.Load() != 2 {
+			t.Fatalf("%d items in %d requests", len(got), requests.Load())
+		}
+	}
+}
+
+func TestLazyAndStopsEarly(t *testing.T) {
+	var requests atomic.Int32
+	srv := numbers(1000, &requests)
+	defer srv.Close()
+
+	p := New[int](srv.Client(), srv.URL, PageNumber("page", "per_page", 10))
+	for v, err := range p.All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v == 15 {
+			break
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("expected 2 page requests for 15 items, got %d", n)
+	}
+}
+
+func TestPrefetchCancellationDoesNotLeak(t *testing.T) {
+	var requests atomic.Int32
+	srv := numbers(1_000_000, &requests)
+	defer srv.Close()
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := New[int](srv.Client(), srv.URL, PageNumber("page", "per_page", 10), WithPrefetch(3))
+
+	var lastErr error
+	for v, err := range p.All(ctx) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		if v == 25 {
+			cancel()
+		}
+	}
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", lastErr)
+	}
+
+	srv.Client().CloseIdleConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("goroutines leaked: %d before, %d after", before, after)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	h := http.Header{}
+	h.Add("Link", `<https://api.github.com/user/1/gists?page=3>; rel="next", <https://api.github.com/user/1/gists?page=9>; rel="last"`)
+	if got := NextLink(h); got != "https://api.github.com/user/1/gists?page=3" {
+		t.Fatalf("got %q", got)
+	}
+	if got := NextLink(http.Header{}); got != "" {
+		t.Fatalf("expected empty, got %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package pager
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Strategy decides which URL to fetch next
+type Strategy interface {
+	// First returns the URL of the first page
+	First(base *url.URL) *url.URL
+	// Next returns the URL after cur, given its response and item count, or false at the end
+	Next(cur *url.URL, res *http.Response, count int) (*url.URL, bool)
+}
+
+// DefaultPageSize replaces a page size or limit <= 0, which would never
+// produce the short page that ends the walk
+const DefaultPageSize = 30
+
+// PageNumber walks ?page=1,2,3... with a fixed page size and stops on a short page.
+// GitHub uses ("page", "per_page"), json-server uses ("_page", "_limit").
+func PageNumber(pageParam, sizeParam string, size int) Strategy {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return pageNumber{pageParam, sizeParam, size}
+}
+
+type pageNumber struct {
+	pageParam, sizeParam string
+	size                 int
+}
+
+func (s pageNumber) First(base *url.URL) *url.URL {
+	return withQuery(base, s.pageParam, 1, s.sizeParam, s.size)
+}
+
+func (s pageNumber) Next(cur *url.URL, _ *http.Response, count int) (*url.URL, bool) {
+	// a server that ignores the size can send more, never none
+	if count == 0 || count < s.size {
+		return nil, false
+	}
+	page, _ := strconv.Atoi(cur.Query().Get(s.pageParam))
+	return withQuery(cur, s.pageParam, page+1, s.sizeParam, s.size), true
+}
+
+// Offset walks ?offset=0,limit,2*limit... and stops on a short page.
+// json-server uses ("_start", "_limit").
+func Offset(offsetParam, limitParam string, limit int) Strategy {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return offset{offsetParam, limitParam, limit}
+}
+
+type offset struct {
+	offsetParam, limitParam string
+	limit                   int
+}
+
+func (s offset) First(base *url.URL) *url.URL {
+	return withQuery(base, s.offsetParam, 0, s.limitParam, s.limit)
+}
+
+func (s offset) Next(cur *url.URL, _ *http.Response, count int) (*url.URL, bool) {
+	if count == 0 || count < s.limit {
+		return nil, false
+	}
+	start, _ := strconv.Atoi(cur.Query().Get(s.offsetParam))
+	return withQuery(cur, s.offsetParam, start+count, s.limitParam, s.limit), true
+}
+
+// LinkHeader follows the rel="next" URL of the Link response header (RFC 8288), as GitHub sends.
+func LinkHeader() Strategy { return linkHeader{} }
+
+type linkHeader struct{}
+
+func (linkHeader) First(base *url.URL) *url.URL { return base }
+
+func (linkHeader) Next(cur *url.URL, res *http.Response, _ int) (*url.URL, bool) {
+	next := NextLink(res.Header)
+	if next == "" {
+		return nil, false
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return nil, false
+	}
+	return cur.ResolveReference(ref), true
+}
+
+// NextLink returns the rel="next" target of the Link headers, or ""
+func NextLink(h http.Header) string {
+	for _, header := range h.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func withQuery(u *url.URL, k1 string, v1 int, k2 string, v2 int) *url.URL {
+	out := *u
+	q := out.Query()
+	q.Set(k1, strconv.Itoa(v1))
+	q.Set(k2, strconv.Itoa(v2))
+	out.RawQuery = q.Encode()
+	return &out
+}
@@ -0,0 +1,329 @@
+// Package pipeline connects channel stages the way Golang/Channel does by
+// hand, without the hand-rolled closes and done channels. Every stage runs in
+// a Pipeline: the first error cancels all of them, every send also waits on
+// the context so nothing blocks once the pipeline is cancelled, and each
+// stage closes its output when it returns, so after Wait no goroutine is left.
+//
+//	p := pipeline.New(ctx)
+//	nums := pipeline.Source(p, 1, 2, 3)
+//	squares := pipeline.Map(p, nums, 4, func(_ context.Context, n int) (int, error) { return n * n, nil })
+//	pipeline.Sink(p, squares, func(_ context.Context, n int) error { fmt.Println(n); return nil })
+//	err := p.Wait()
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Pipeline owns the goroutines of its stages
+type Pipeline struct {
+	ctx    context.Context
+	g      *errgroup.Group
+	buffer int
+}
+
+// Option configures a Pipeline
+type Option func(*Pipeline)
+
+// WithBuffer sets the capacity of every stage's output channel, default 8.
+// A full buffer blocks the stage, which is how backpressure travels upstream.
+func WithBuffer(n int) Option {
+	return func(p *Pipeline) { p.buffer = max(n, 0) }
+}
+
+// New returns an empty Pipeline that stops when ctx does
+func New(ctx context.Context, opts ...Option) *Pipeline {
+	g, ctx := errgroup.WithContext(ctx)
+	p := &Pipeline{ctx: ctx, g: g, buffer: 8}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+// Context is cancelled on the first stage error or when the parent is done
+func (p *Pipeline) Context() context.Context { return p.ctx }
+
+// Wait blocks until every stage has returned and reports the first error
+func (p *Pipeline) Wait() error { return p.g.Wait() }
+
+// PanicError is a stage function's panic, returned by Wait
+type PanicError struct {
+	Value any
+}
+
+func (e PanicError) Error() string { return fmt.Sprintf("pipeline: stage panicked: %v", e.Value) }
+
+// stage runs fn in the pipeline, turning a panic into an error
+func (p *Pipeline) stage(fn func(ctx context.Context) error) {
+	p.g.Go(func() (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = PanicError{v}
+			}
+		}()
+		return fn(p.ctx)
+	})
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recv returns false once in is closed or ctx is done; in may come from
+// outside the pipeline, so a plain range could wait forever
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Source emits items in order
+func Source[T any](p *Pipeline, items ...T) <-chan T {
+	return Generate(p, func(ctx context.Context, emit func(T) error) error {
+		for _, v := range items {
+			if err := emit(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Generate emits whatever fn passes to emit. emit fails once the pipeline is
+// cancelled; fn should return that error.
+func Generate[T any](p *Pipeline, fn func(ctx context.Context, emit func(T) error) error) <-chan T {
+	out := make(chan T, p.buffer)
+	p.stage(func(ctx context.Context) error {
+		defer close(out)
+		return fn(ctx, func(v T) error { return send(ctx, out, v) })
+	})
+	return out
+}
+
+// Map applies fn to every item with workers goroutines. With more than one
+// worker the output order is not the input order.
+func Map[In, Out any](p *Pipeline, in <-chan In, workers int, fn func(context.Context, In) (Out, error)) <-chan Out {
+	out := make(chan Out, p.buffer)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		p.stage(func(ctx context.Context) error {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return ctx.Err()
+				}
+				r, err := fn(ctx, v)
+				if err != nil {
+					return err
+				}
+				if err := send(ctx, out, r); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	// the last worker out closes the channel
+	p.stage(func(context.Context) error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+	return out
+}
+
+// Filter passes on the items keep returns true for
+func Filter[T any](p *Pipeline, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T, p.buffer)
+	p.stage(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return ctx.Err()
+			}
+			if !keep(v) {
+				continue
+			}
+			if err := send(ctx, out, v); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// FanOut splits in across n outputs; each item goes to exactly one of them,
+// whichever is ready first
+func FanOut[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, max(n, 1))
+	for i := range outs {
+		out := make(chan T, p.buffer)
+		outs[i] = out
+		p.stage(func(ctx context.Context) error {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return ctx.Err()
+				}
+				if err := send(ctx, out, v); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	return outs
+}
+
+// FanIn merges ins into one channel that closes when all of them have
+func FanIn[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T, p.buffer)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		p.stage(func(ctx context.Context) error {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return ctx.Err()
+				}
+				if err := send(ctx, out, v); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	p.stage(func(context.Context) error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+	return out
+}
+
+// Batch groups items into slices of up to size, sending a short batch when
+// maxWait passes after its first item or when in closes. maxWait <= 0 waits
+// for full batches.
+func Batch[T any](p *Pipeline, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	size = max(size, 1)
+	out := make(chan []T, p.buffer)
+	p.stage(func(ctx context.Context) error {
+		defer close(out)
+		var batch []T
+		var timer *time.Timer
+		var expired <-chan time.Time
+		flush := func() error {
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			b := batch
+			batch = nil
+			return send(ctx, out, b)
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return flush()
+				}
+				batch = append(batch, v)
+				if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					expired = timer.C
+				}
+				if len(batch) == size {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			case <-expired:
+				if err := flush(); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return ctx.Err()
+			}
+		}
+	})
+	return out
+}
+
+// Throttle passes items on no faster than one per every
+func Throttle[T any](p *Pipeline, in <-chan T, every time.Duration) <-chan T {
+	out := make(chan T, p.buffer)
+	p.stage(func(ctx context.Context) error {
+		defer close(out)
+		var next time.Time
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return ctx.Err()
+			}
+			if wait := time.Until(next); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			next = time.Now().Add(every)
+			if err := send(ctx, out, v); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// Sink calls fn for every item; the pipeline is done when in closes
+func Sink[T any](p *Pipeline, in <-chan T, fn func(context.Context, T) error) {
+	p.stage(func(ctx context.Context) error {
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return ctx.Err()
+			}
+			if err := fn(ctx, v); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Collect gathers every item; the slice is complete once Wait returns
+func Collect[T any](p *Pipeline, in <-chan T) *[]T {
+	var items []T
+	Sink(p, in, func(_ context.Context, v T) error {
+		items = append(items, v)
+		return nil
+	})
+	return &items
+}
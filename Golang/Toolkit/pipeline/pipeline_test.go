@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// checkLeaks fails the test if goroutines running this package's stages are
+// still around shortly after it ends
+func checkLeaks(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		var stacks []string
+		for range 100 {
+			if stacks = stageGoroutines(); len(stacks) == 0 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("%d leaked goroutines:\n\n%s", len(stacks), strings.Join(stacks, "\n\n"))
+	})
+}
+
+func stageGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	var leaked []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		// a stage that has not been scheduled yet only shows errgroup frames
+		stage := strings.Contains(g, "toolkit/pipeline.") || strings.Contains(g, "errgroup.(*Group).Go")
+		if stage && !strings.Contains(g, "pipeline.Test") && !strings.Contains(g, "pipeline.stageGoroutines") {
+			leaked = append(leaked, g)
+		}
+	}
+	return leaked
+}
+
+func square(_ context.Context, n int) (int, error) { return n * n, nil }
+
+func TestStages(t *testing.T) {
+	checkLeaks(t)
+	p := New(context.Background(), WithBuffer(2))
+	nums := Generate(p, func(ctx context.Context, emit func(int) error) error {
+		for i := 1; i <= 20; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	even := Filter(p, nums, func(n int) bool { return n%2 == 0 })
+	parts := FanOut(p, even, 3)
+	var squared []<-chan int
+	for _, part := range parts {
+		squared = append(squared, Map(p, part, 2, square))
+	}
+	batches := Batch(p, FanIn(p, squared...), 4, 0)
+	got := Collect(p, batches)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	var flat []int
+	for i, b := range *got {
+		if len(b) != 4 && i != len(*got)-1 {
+			t.Errorf("batch %d has %d items", i, len(b))
+		}
+		flat = append(flat, b...)
+	}
+	slices.Sort(flat)
+	want := []int{4, 16, 36, 64, 100, 144, 196, 256, 324, 400}
+	if !slices.Equal(flat, want) {
+		t.Fatalf("got %v", flat)
+	}
+}
+
+func TestErrorCancelsEveryStage(t *testing.T) {
+	checkLeaks(t)
+	p := New(context.Background())
+	boom := errors.New("boom")
+	// an endless source: only cancellation can stop it
+	nums := Generate(p, func(ctx context.Context, emit func(int) error) error {
+		for i := 0; ; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+	})
+	mapped := Map(p, nums, 4, func(_ context.Context, n int) (int, error) {
+		if n == 100 {
+			return 0, boom
+		}
+		return n, nil
+	})
+	Sink(p, Throttle(p, mapped, time.Microsecond), func(context.Context, int) error { return nil })
+	if err := p.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestParentCancel(t *testing.T) {
+	checkLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(ctx)
+	// an input from outside the pipeline that is never closed
+	external := make(chan int)
+	var seen atomic.Int32
+	Sink(p, Batch(p, FanIn(p, external, Source(p, 1, 2, 3)), 10, 0), func(context.Context, []int) error {
+		seen.Add(1)
+		return nil
+	})
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v", err)
+	}
+	// the partial batch is dropped, not delivered after cancellation
+	if seen.Load() != 0 {
+		t.Fatal("batch delivered after cancel")
+	}
+}
+
+func TestPanicBecomesError(t *testing.T) {
+	checkLeaks(t)
+	p := New(context.Background())
+	Sink(p, Source(p, 1), func(context.Context, int) error { panic("oops") })
+	var pe PanicError
+	if err := p.Wait(); !errors.As(err, &pe) || pe.Value != "oops" {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	checkLeaks(t)
+	p := New(context.Background(), WithBuffer(1))
+	var produced atomic.Int32
+	nums := Generate(p, func(ctx context.Context, emit func(int) error) error {
+		for i := range 100 {
+			if err := emit(i); err != nil {
+				return err
+			}
+			produced.Add(1)
+		}
+		return nil
+	})
+	release := make(chan struct{})
+	Sink(p, nums, func(context.Context, int) error {
+		<-release
+		return nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	// one item in the sink, one in the buffer, one blocked in emit
+	if n := produced.Load(); n > 3 {
+		t.Fatalf("producer ran %d items ahead of a stuck consumer", n)
+	}
+	close(release)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchTimeoutAndThrottle(t *testing.T) {
+	checkLeaks(t)
+	p := New(context.Background())
+	slow := Generate(p, func(ctx context.Context, emit func(int) error) error {
+		for i := range 5 {
+			if err := emit(i); err != nil {
+				return err
+			}
+			if i == 1 {
+				time.Sleep(60 * time.Millisecond) // longer than maxWait
+			}
+		}
+		return nil
+	})
+	batches := Collect(p, Batch(p, slow, 10, 20*time.Millisecond))
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*batches) != 2 || len((*batches)[0]) != 2 || len((*batches)[1]) != 3 {
+		t.Fatalf("got %v", *batches)
+	}
+
+	p = New(context.Background())
+	start := time.Now()
+	out := Collect(p, Throttle(p, Source(p, 1, 2, 3, 4, 5), 10*time.Millisecond))
+	if err := p.Wait(); err != nil || len(*out) != 5 {
+		t.Fatal(err, *out)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("5 items at 10ms intervals took %s", d)
+	}
+}
@@ -0,0 +1,289 @@
+// Package pubsub is an in-process publish/subscribe broker, the select over
+// chan1 and chan2 in Golang/Channel/unbuffered.RecieveChannel.go turned
+// around: publishers don't know who listens, and each subscriber gets its own
+// bounded channel with a policy for when it falls behind. Server and Client
+// put the same broker on a TCP line protocol for other processes.
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned once the broker is closed
+var ErrClosed = errors.New("pubsub: broker closed")
+
+// ErrNoResponders is returned by Request when nobody subscribes to the topic
+var ErrNoResponders = errors.New("pubsub: no responders")
+
+// Message is one publication
+type Message struct {
+	Topic string
+	Data  []byte
+	Reply string // where a response should go, set by Request
+}
+
+// Policy says what Publish does when a subscriber's buffer is full
+type Policy int
+
+const (
+	// DropNewest discards the message for that subscriber
+	DropNewest Policy = iota
+	// DropOldest discards the oldest buffered message to make room
+	DropOldest
+	// Block waits for room, holding up the publisher until its ctx ends
+	Block
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop"
+	case DropOldest:
+		return "oldest"
+	case Block:
+		return "block"
+	}
+	return "unknown"
+}
+
+// SubOption configures a Subscription
+type SubOption func(*Subscription)
+
+// WithBuffer sets how many messages wait for the subscriber, default 64
+func WithBuffer(n int) SubOption {
+	return func(s *Subscription) { s.size = max(n, 1) }
+}
+
+// WithPolicy sets what happens when the buffer is full, default DropNewest
+func WithPolicy(p Policy) SubOption {
+	return func(s *Subscription) { s.policy = p }
+}
+
+// Subscription receives the messages matching its pattern on C until
+// Unsubscribe, which closes C
+type Subscription struct {
+	C       <-chan Message
+	Pattern string
+
+	c       chan Message
+	tokens  []string
+	size    int
+	policy  Policy
+	broker  *Broker
+	dropped atomic.Uint64
+
+	mu     sync.Mutex // serializes senders against close
+	done   chan struct{}
+	closed bool
+	once   sync.Once
+}
+
+// Dropped counts the messages this subscriber lost to its policy
+func (s *Subscription) Dropped() uint64 { return s.dropped.Load() }
+
+// Unsubscribe stops delivery and closes C. Messages already buffered stay
+// readable.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.broker.remove(s)
+		// wake Block publishers before waiting for them
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.c)
+		s.mu.Unlock()
+	})
+}
+
+// deliver applies the policy and reports whether m was buffered
+func (s *Subscription) deliver(ctx context.Context, m Message) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false, nil
+	}
+	select {
+	case s.c <- m:
+		return true, nil
+	default:
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.c <- m:
+			return true, nil
+		case <-s.done:
+			return false, nil
+		case <-ctx.Done():
+			s.dropped.Add(1)
+			return false, ctx.Err()
+		}
+	case DropOldest:
+		// the subscriber may be reading concurrently, so keep trying until
+		// there is room; only this goroutine sends while s.mu is held
+		for {
+			select {
+			case <-s.c:
+				s.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.c <- m:
+				return true, nil
+			default:
+			}
+		}
+	default:
+		s.dropped.Add(1)
+		return false, nil
+	}
+}
+
+// Broker routes messages from publishers to subscriptions
+type Broker struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// New returns an empty Broker
+func New() *Broker {
+	return &Broker{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe starts receiving messages whose topic matches pattern
+func (b *Broker) Subscribe(pattern string, opts ...SubOption) (*Subscription, error) {
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
+	}
+	s := &Subscription{
+		Pattern: pattern,
+		tokens:  strings.Split(pattern, "."),
+		size:    64,
+		broker:  b,
+		done:    make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	s.c = make(chan Message, s.size)
+	s.C = s.c
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+func (b *Broker) remove(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// Publish delivers to every matching subscription and returns how many
+// buffered it. Only Block subscribers can make it wait, and only until ctx
+// is done.
+func (b *Broker) Publish(ctx context.Context, topic string, data []byte) (int, error) {
+	return b.PublishMsg(ctx, Message{Topic: topic, Data: data})
+}
+
+// PublishMsg is Publish with a reply topic
+func (b *Broker) PublishMsg(ctx context.Context, m Message) (int, error) {
+	if err := checkTopic(m.Topic); err != nil {
+		return 0, err
+	}
+	tokens := strings.Split(m.Topic, ".")
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return 0, ErrClosed
+	}
+	var targets []*Subscription
+	for s := range b.subs {
+		if match(s.tokens, tokens) {
+			targets = append(targets, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	// deliver without the broker lock so a blocked subscriber only holds up
+	// this publisher, not Subscribe and Unsubscribe
+	n := 0
+	var errs []error
+	for _, s := range targets {
+		ok, err := s.deliver(ctx, m)
+		if ok {
+			n++
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// Request publishes data with a fresh reply topic and returns the first
+// response, which a subscriber sends with Respond
+func (b *Broker) Request(ctx context.Context, topic string, data []byte) (Message, error) {
+	inbox := NewInbox()
+	sub, err := b.Subscribe(inbox, WithBuffer(1))
+	if err != nil {
+		return Message{}, err
+	}
+	defer sub.Unsubscribe()
+
+	n, err := b.PublishMsg(ctx, Message{Topic: topic, Data: data, Reply: inbox})
+	if err != nil {
+		return Message{}, err
+	}
+	if n == 0 {
+		return Message{}, ErrNoResponders
+	}
+	select {
+	case m := <-sub.C:
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// Respond answers a message sent with Request
+func (b *Broker) Respond(ctx context.Context, to Message, data []byte) error {
+	if to.Reply == "" {
+		return errors.New("pubsub: message has no reply topic")
+	}
+	_, err := b.Publish(ctx, to.Reply, data)
+	return err
+}
+
+// NewInbox returns a unique topic for replies
+func NewInbox() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return "_INBOX." + hex.EncodeToString(id[:])
+}
+
+// Close unsubscribes everyone; Publish and Subscribe fail afterwards
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
@@ -0,0 +1,252 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrConnClosed is returned once a Client's connection is gone
+var ErrConnClosed = errors.New("pubsub: connection closed")
+
+// Client talks to a Server
+type Client struct {
+	conn net.Conn
+
+	wmu sync.Mutex // held while writing a command and queueing its ack
+
+	mu      sync.Mutex
+	acks    []chan ack
+	subs    map[string]*ClientSub
+	nextSID int
+	err     error
+	done    chan struct{}
+}
+
+type ack struct {
+	arg string // after "+OK "
+	err error
+}
+
+// ClientSub is a subscription held by a Server on a Client's behalf
+type ClientSub struct {
+	C       <-chan Message
+	Pattern string
+
+	c       chan Message
+	sid     string
+	client  *Client
+	dropped atomic.Uint64
+}
+
+// Dropped counts messages that arrived while C was full. The client never
+// blocks on a subscriber, since that would also hold up every reply.
+func (s *ClientSub) Dropped() uint64 { return s.dropped.Load() }
+
+// Unsubscribe ends the subscription on the server and closes C
+func (s *ClientSub) Unsubscribe() error {
+	_, err := s.client.command("UNSUB " + s.sid)
+	s.client.mu.Lock()
+	if s.client.subs[s.sid] == s {
+		delete(s.client.subs, s.sid)
+		close(s.c)
+	}
+	s.client.mu.Unlock()
+	return err
+}
+
+// Dial connects to a Server
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, subs: map[string]*ClientSub{}, done: make(chan struct{})}
+	go c.read()
+	return c, nil
+}
+
+// Close disconnects; the server drops this client's subscriptions
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Publish sends data to topic and returns how many subscribers took it
+func (c *Client) Publish(topic string, data []byte) (int, error) {
+	return c.publish(topic, "", data)
+}
+
+func (c *Client) publish(topic, reply string, data []byte) (int, error) {
+	if err := checkTopic(topic); err != nil {
+		return 0, err
+	}
+	if strings.ContainsAny(string(data), "\r\n") {
+		return 0, errors.New("pubsub: payload contains a newline")
+	}
+	line := "PUB " + topic + " " + string(data)
+	if reply != "" {
+		line = "PUBR " + topic + " " + reply + " " + string(data)
+	}
+	arg, err := c.command(line)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(arg)
+}
+
+// Subscribe asks the server for messages matching pattern; up to buffer of
+// them wait on C
+func (c *Client) Subscribe(pattern string, buffer int) (*ClientSub, error) {
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
+	}
+	sub := &ClientSub{Pattern: pattern, c: make(chan Message, max(buffer, 1)), client: c}
+	sub.C = sub.c
+	c.mu.Lock()
+	c.nextSID++
+	sub.sid = strconv.Itoa(c.nextSID)
+	// registered first: MSG may overtake the +OK
+	c.subs[sub.sid] = sub
+	c.mu.Unlock()
+
+	if _, err := c.command("SUB " + sub.sid + " " + pattern); err != nil {
+		c.mu.Lock()
+		if c.subs[sub.sid] == sub {
+			delete(c.subs, sub.sid)
+			close(sub.c)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Request publishes data with a reply topic and waits for the first answer
+func (c *Client) Request(ctx context.Context, topic string, data []byte) (Message, error) {
+	inbox := NewInbox()
+	sub, err := c.Subscribe(inbox, 1)
+	if err != nil {
+		return Message{}, err
+	}
+	defer sub.Unsubscribe()
+
+	n, err := c.publish(topic, inbox, data)
+	if err != nil {
+		return Message{}, err
+	}
+	if n == 0 {
+		return Message{}, ErrNoResponders
+	}
+	select {
+	case m, ok := <-sub.C:
+		if !ok {
+			return Message{}, ErrConnClosed
+		}
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// Respond answers a message that came with a reply topic
+func (c *Client) Respond(to Message, data []byte) error {
+	if to.Reply == "" {
+		return errors.New("pubsub: message has no reply topic")
+	}
+	_, err := c.Publish(to.Reply, data)
+	return err
+}
+
+// command writes one line and waits for its +OK or -ERR
+func (c *Client) command(line string) (string, error) {
+	ch := make(chan ack, 1)
+	c.wmu.Lock()
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return "", c.err
+	}
+	c.acks = append(c.acks, ch)
+	c.mu.Unlock()
+	_, err := c.conn.Write([]byte(line + "\n"))
+	c.wmu.Unlock()
+	if err != nil {
+		// the read loop fails the ack as the connection goes
+		c.conn.Close()
+	}
+	a := <-ch
+	return a.arg, a.err
+}
+
+// read dispatches acks and messages until the connection ends
+func (c *Client) read() {
+	sc := bufio.NewScanner(c.conn)
+	sc.Buffer(make([]byte, 4096), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		verb, rest, _ := strings.Cut(line, " ")
+		switch verb {
+		case "+OK", "-ERR":
+			a := ack{arg: rest}
+			if verb == "-ERR" {
+				a = ack{err: fmt.Errorf("pubsub: server: %s", rest)}
+			}
+			c.mu.Lock()
+			if len(c.acks) == 0 {
+				c.mu.Unlock()
+				// an error not tied to a command, like a line too long
+				continue
+			}
+			ch := c.acks[0]
+			c.acks = c.acks[1:]
+			c.mu.Unlock()
+			ch <- a
+		case "MSG", "MSGR":
+			var m Message
+			sid, rest, _ := strings.Cut(rest, " ")
+			m.Topic, rest, _ = strings.Cut(rest, " ")
+			if verb == "MSGR" {
+				m.Reply, rest, _ = strings.Cut(rest, " ")
+			}
+			m.Data = []byte(rest)
+			c.mu.Lock()
+			if sub := c.subs[sid]; sub != nil {
+				select {
+				case sub.c <- m:
+				default:
+					sub.dropped.Add(1)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+	err := ErrConnClosed
+	if e := sc.Err(); e != nil && !errors.Is(e, net.ErrClosed) {
+		err = fmt.Errorf("%w: %v", ErrConnClosed, e)
+	}
+	c.conn.Close()
+
+	c.mu.Lock()
+	c.err = err
+	acks := c.acks
+	c.acks = nil
+	for sid, sub := range c.subs {
+		close(sub.c)
+		delete(c.subs, sid)
+	}
+	c.mu.Unlock()
+	for _, ch := range acks {
+		ch <- ack{err: err}
+	}
+	close(c.done)
+}
@@ -0,0 +1,341 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.deleted", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{">", "anything.at.all", true},
+		{"*.*", "a", false},
+	} {
+		if got := match(strings.Split(c.pattern, "."), strings.Split(c.topic, ".")); got != c.want {
+			t.Errorf("%s ~ %s: got %v", c.pattern, c.topic, got)
+		}
+	}
+	for _, bad := range []string{"", "a..b", "a.>.b", "a.b*", "a b"} {
+		if checkPattern(bad) == nil {
+			t.Errorf("pattern %q accepted", bad)
+		}
+	}
+	if checkTopic("orders.*") == nil {
+		t.Error("wildcard topic accepted")
+	}
+}
+
+func drain(sub *Subscription) []string {
+	var got []string
+	for {
+		select {
+		case m := <-sub.C:
+			got = append(got, string(m.Data))
+		default:
+			return got
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		policy Policy
+		want   string
+	}{
+		{DropNewest, "1 2"},
+		{DropOldest, "3 4"},
+	} {
+		b := New()
+		sub, _ := b.Subscribe("t", WithBuffer(2), WithPolicy(c.policy))
+		for _, d := range []string{"1", "2", "3", "4"} {
+			b.Publish(ctx, "t", []byte(d))
+		}
+		if got := strings.Join(drain(sub), " "); got != c.want || sub.Dropped() != 2 {
+			t.Errorf("%s: got %q, %d dropped", c.policy, got, sub.Dropped())
+		}
+	}
+
+	b := New()
+	fast, _ := b.Subscribe("t", WithBuffer(8))
+	slow, _ := b.Subscribe("t", WithBuffer(1), WithPolicy(Block))
+	b.Publish(ctx, "t", []byte("1"))
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	// the Block subscriber holds the publisher until its deadline, but the
+	// other subscriber still got the message
+	if n, err := b.Publish(short, "t", []byte("2")); n != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if len(drain(fast)) != 2 {
+		t.Fatal("fast subscriber missed a message")
+	}
+
+	// room appears once the subscriber reads
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-slow.C
+	}()
+	if n, err := b.Publish(ctx, "t", []byte("3")); n != 2 || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+
+	// and Unsubscribe releases a publisher blocked on it
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		slow.Unsubscribe()
+	}()
+	if n, err := b.Publish(ctx, "t", []byte("4")); n != 1 || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+}
+
+func TestWildcardsAndUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	all, _ := b.Subscribe("orders.>")
+	eu, _ := b.Subscribe("orders.eu.*")
+	if n, _ := b.Publish(ctx, "orders.eu.created", []byte("a")); n != 2 {
+		t.Fatalf("delivered to %d", n)
+	}
+	if n, _ := b.Publish(ctx, "orders.us.created", []byte("b")); n != 1 {
+		t.Fatalf("delivered to %d", n)
+	}
+	eu.Unsubscribe()
+	eu.Unsubscribe()
+	if n, _ := b.Publish(ctx, "orders.eu.created", []byte("c")); n != 1 {
+		t.Fatalf("delivered to %d", n)
+	}
+	// buffered messages outlive Unsubscribe, then C closes
+	if m, ok := <-eu.C; !ok || string(m.Data) != "a" {
+		t.Fatal("lost a buffered message")
+	}
+	if _, ok := <-eu.C; ok {
+		t.Fatal("C still open")
+	}
+	if got := strings.Join(drain(all), " "); got != "a b c" {
+		t.Fatalf("got %q", got)
+	}
+
+	b.Close()
+	if _, ok := <-all.C; ok {
+		t.Fatal("Close left a subscription open")
+	}
+	if _, err := b.Publish(ctx, "orders.x", nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b := New()
+	if _, err := b.Request(ctx, "math.double", []byte("2")); !errors.Is(err, ErrNoResponders) {
+		t.Fatalf("got %v", err)
+	}
+
+	sub, _ := b.Subscribe("math.*")
+	go func() {
+		for m := range sub.C {
+			b.Respond(ctx, m, []byte(m.Topic+":"+string(m.Data)+string(m.Data)))
+		}
+	}()
+	defer sub.Unsubscribe()
+
+	var wg sync.WaitGroup
+	for _, in := range []string{"1", "2", "3"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := b.Request(ctx, "math.double", []byte(in))
+			if err != nil || string(m.Data) != "math.double:"+in+in {
+				t.Errorf("%s: got %q, %v", in, m.Data, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentPublishAndUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := New()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				b.Publish(ctx, "load.x", []byte("m"))
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				sub, _ := b.Subscribe("load.*", WithBuffer(4), WithPolicy(Policy(i%3)))
+				go func() {
+					for range sub.C {
+					}
+				}()
+				sub.Unsubscribe()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// serve starts a Server for b on a loopback port and returns its address
+func serve(t *testing.T, b *Broker) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Broker: b}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func recv(t *testing.T, c <-chan Message) Message {
+	t.Helper()
+	select {
+	case m := <-c:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+		return Message{}
+	}
+}
+
+func TestOverTCP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b := New()
+	addr := serve(t, b)
+	sub, pub := dial(t, addr), dial(t, addr)
+
+	orders, err := sub.Subscribe("orders.>", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Subscribe("bad..pattern", 1); err == nil {
+		t.Fatal("bad pattern accepted")
+	}
+	if n, err := pub.Publish("orders.eu.created", []byte("order 1 for ann")); n != 1 || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if m := recv(t, orders.C); m.Topic != "orders.eu.created" || string(m.Data) != "order 1 for ann" {
+		t.Fatalf("%+v", m)
+	}
+	// in-process publishers reach network subscribers too
+	b.Publish(ctx, "orders.us.created", []byte("2"))
+	if m := recv(t, orders.C); string(m.Data) != "2" {
+		t.Fatalf("%+v", m)
+	}
+	if _, err := pub.Publish("x", []byte("two\nlines")); err == nil {
+		t.Fatal("newline accepted")
+	}
+
+	// a responder on one connection answers requests from another, and from
+	// inside the process
+	reqs, _ := sub.Subscribe("rpc.upper", 16)
+	go func() {
+		for m := range reqs.C {
+			sub.Respond(m, []byte(strings.ToUpper(string(m.Data))))
+		}
+	}()
+	m, err := pub.Request(ctx, "rpc.upper", []byte("hello there"))
+	if err != nil || string(m.Data) != "HELLO THERE" {
+		t.Fatalf("got %q, %v", m.Data, err)
+	}
+	if m, err := b.Request(ctx, "rpc.upper", []byte("local")); err != nil || string(m.Data) != "LOCAL" {
+		t.Fatalf("got %q, %v", m.Data, err)
+	}
+	if _, err := pub.Request(ctx, "rpc.nobody", nil); !errors.Is(err, ErrNoResponders) {
+		t.Fatalf("got %v", err)
+	}
+
+	if err := orders.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := pub.Publish("orders.eu.created", nil); n != 0 {
+		t.Fatalf("delivered to %d after unsubscribe", n)
+	}
+
+	// a disconnected client's subscriptions go with it
+	sub.Close()
+	if _, err := sub.Publish("x", nil); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := pub.Publish("rpc.upper", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription outlived its connection")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerCloseDisconnectsClients(t *testing.T) {
+	b := New()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Broker: b}
+	go srv.Serve(ln)
+	c, err := Dial(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	sub, err := c.Subscribe("t", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("subscription survived server close")
+	}
+	if _, err := c.Publish("t", nil); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("got %v", err)
+	}
+}
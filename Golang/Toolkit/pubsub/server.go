@@ -0,0 +1,230 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The line protocol. Every line ends in "\n" (a preceding "\r" is ignored)
+// and payloads are the rest of the line, so they can't contain newlines.
+//
+//	client                          server
+//	SUB <sid> <pattern>             +OK | -ERR <reason>
+//	UNSUB <sid>                     +OK | -ERR <reason>
+//	PUB <topic> <payload>           +OK <delivered> | -ERR <reason>
+//	PUBR <topic> <reply> <payload>  +OK <delivered> | -ERR <reason>
+//	                                MSG <sid> <topic> <payload>
+//	                                MSGR <sid> <topic> <reply> <payload>
+//
+// Every command gets exactly one +OK or -ERR, in order. MSG and MSGR can
+// arrive at any time; sid is the client's name for the subscription.
+
+// Server serves a Broker to TCP clients
+type Server struct {
+	Broker *Broker
+	// Buffer is each network subscription's buffer, default 256
+	Buffer int
+	// Policy applies to network subscriptions, default DropNewest. Block lets
+	// one slow client hold up every publisher of its topics.
+	Policy Policy
+	// MaxLine limits a line in bytes, default 64 KiB
+	MaxLine int
+	// WriteTimeout drops a client that stops reading, default 10s
+	WriteTimeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	wg     sync.WaitGroup
+}
+
+func (s *Server) init() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		s.conns = map[net.Conn]struct{}{}
+	}
+}
+
+// ListenAndServe listens on addr and calls Serve
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections until Close, which makes it return nil
+func (s *Server) Serve(ln net.Listener) error {
+	s.init()
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		ln.Close()
+		return nil
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.ctx.Err() != nil {
+			// accepted just as Close ran
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			s.session(conn)
+			conn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting, disconnects every client and waits for their
+// sessions to end. The broker stays open.
+func (s *Server) Close() error {
+	s.init()
+	s.mu.Lock()
+	s.cancel()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// session runs one client's commands; its subscriptions end with it
+func (s *Server) session(conn net.Conn) {
+	buffer := orDefault(s.Buffer, 256)
+	writeTimeout := orDefault(s.WriteTimeout, 10*time.Second)
+
+	var wmu sync.Mutex
+	write := func(format string, args ...any) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprintf(conn, format+"\n", args...); err != nil {
+			// the read loop notices and cleans up
+			conn.Close()
+		}
+	}
+
+	subs := map[string]*Subscription{}
+	var forwarders sync.WaitGroup
+	defer func() {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+		forwarders.Wait()
+	}()
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 4096), orDefault(s.MaxLine, 64<<10))
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		verb, rest, _ := strings.Cut(line, " ")
+		switch verb {
+		case "SUB":
+			sid, pattern, ok := strings.Cut(rest, " ")
+			if !ok || sid == "" || strings.Contains(pattern, " ") {
+				write("-ERR usage: SUB <sid> <pattern>")
+				continue
+			}
+			if subs[sid] != nil {
+				write("-ERR sid %s in use", sid)
+				continue
+			}
+			sub, err := s.Broker.Subscribe(pattern, WithBuffer(buffer), WithPolicy(s.Policy))
+			if err != nil {
+				write("-ERR %v", err)
+				continue
+			}
+			subs[sid] = sub
+			forwarders.Add(1)
+			go func() {
+				defer forwarders.Done()
+				for m := range sub.C {
+					// a payload from an in-process publisher may not fit on
+					// a line; a network subscriber can't receive it
+					if strings.ContainsAny(string(m.Data), "\r\n") {
+						continue
+					}
+					if m.Reply != "" {
+						write("MSGR %s %s %s %s", sid, m.Topic, m.Reply, m.Data)
+					} else {
+						write("MSG %s %s %s", sid, m.Topic, m.Data)
+					}
+				}
+			}()
+			write("+OK")
+		case "UNSUB":
+			sub := subs[rest]
+			if sub == nil {
+				write("-ERR no subscription %s", rest)
+				continue
+			}
+			sub.Unsubscribe()
+			delete(subs, rest)
+			write("+OK")
+		case "PUB", "PUBR":
+			m := Message{}
+			m.Topic, rest, _ = strings.Cut(rest, " ")
+			if verb == "PUBR" {
+				m.Reply, rest, _ = strings.Cut(rest, " ")
+				if err := checkTopic(m.Reply); err != nil {
+					write("-ERR reply: %v", err)
+					continue
+				}
+			}
+			m.Data = []byte(rest)
+			n, err := s.Broker.PublishMsg(s.ctx, m)
+			if err != nil {
+				write("-ERR %v", err)
+				continue
+			}
+			write("+OK %d", n)
+		default:
+			write("-ERR unknown command %q", verb)
+		}
+	}
+	if sc.Err() == bufio.ErrTooLong {
+		write("-ERR line too long")
+	}
+}
+
+// orDefault returns v, or def when v is not positive
+func orDefault[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topics are dot separated tokens, "orders.eu.created". In a subscription
+// pattern "*" matches exactly one token and a final ">" matches one or more,
+// so "orders.*.created" and "orders.>" both match the topic above.
+
+func checkTopic(topic string) error {
+	return check(topic, false)
+}
+
+func checkPattern(pattern string) error {
+	return check(pattern, true)
+}
+
+func check(s string, wildcards bool) error {
+	if s == "" {
+		return fmt.Errorf("pubsub: empty topic")
+	}
+	if strings.ContainsAny(s, " \t\r\n") {
+		return fmt.Errorf("pubsub: topic %q contains whitespace", s)
+	}
+	tokens := strings.Split(s, ".")
+	for i, tok := range tokens {
+		switch {
+		case tok == "":
+			return fmt.Errorf("pubsub: topic %q has an empty token", s)
+		case tok == "*" || tok == ">":
+			if !wildcards {
+				return fmt.Errorf("pubsub: wildcard in topic %q", s)
+			}
+			if tok == ">" && i != len(tokens)-1 {
+				return fmt.Errorf("pubsub: %q: > must be the last token", s)
+			}
+		case strings.ContainsAny(tok, "*>"):
+			return fmt.Errorf("pubsub: %q: wildcards must be whole tokens", s)
+		}
+	}
+	return nil
+}
+
+// match reports whether topic, split into tokens, fits pattern
+func match(pattern, topic []string) bool {
+	for i, p := range pattern {
+		if p == ">" {
+			return len(topic) > i
+		}
+		if i >= len(topic) || (p != "*" && p != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
@@ -0,0 +1,133 @@
+package resilient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the circuit breaker state
+type State int
+
+const (
+	StateClosed   State = iota // requests flow normally
+	StateOpen                  // requests fail fast
+	StateHalfOpen              // one trial request is allowed through
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker opens after threshold consecutive failures and lets a trial
+// request through once openFor has elapsed.
+type Breaker struct {
+	threshold int
+	openFor   time.Duration
+	onChange  func(from, to State)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+	gen      uint64 // bumped on every state change
+	pending  [][2]State
+}
+
+// NewBreaker returns a closed Breaker. A threshold of zero or less disables it.
+func NewBreaker(threshold int, openFor time.Duration, onChange func(from, to State)) *Breaker {
+	return &Breaker{threshold: threshold, openFor: openFor, onChange: onChange, now: time.Now}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a request may be sent. If it may, the returned func
+// must be called with the outcome. Outcomes of requests allowed before the
+// breaker last changed state are ignored, so a slow request sent while closed
+// cannot close the breaker in place of the half-open trial.
+func (b *Breaker) Allow() (record func(success bool), err error) {
+	if b.threshold <= 0 {
+		return func(bool) {}, nil
+	}
+	b.mu.Lock()
+	defer b.unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openFor {
+			return nil, ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+		b.trial = true
+	case StateHalfOpen:
+		if b.trial {
+			return nil, ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	gen := b.gen
+	return func(success bool) { b.record(gen, success) }, nil
+}
+
+func (b *Breaker) record(gen uint64, success bool) {
+	b.mu.Lock()
+	defer b.unlock()
+	if gen != b.gen {
+		return
+	}
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		if b.state != StateClosed {
+			b.setState(StateClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		if b.state != StateOpen {
+			b.setState(StateOpen)
+		}
+	}
+}
+
+// setState must be called with b.mu held. The change hook fires in unlock.
+func (b *Breaker) setState(to State) {
+	b.pending = append(b.pending, [2]State{b.state, to})
+	b.state = to
+	b.gen++
+}
+
+// unlock releases b.mu and then fires the change hook, so hooks may call State
+func (b *Breaker) unlock() {
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if b.onChange == nil {
+		return
+	}
+	for _, p := range pending {
+		b.onChange(p[0], p[1])
+	}
+}
@@ -0,0 +1,168 @@
+// Package resilient is an http.RoundTripper with per-attempt timeouts,
+// retries for idempotent requests and a circuit breaker.
+package resilient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Policy configures timeouts, retries and the breaker
+type Policy struct {
+	Timeout          time.Duration // per attempt, zero means none
+	MaxRetries       int           // retries after the first attempt
+	BaseDelay        time.Duration // first backoff, doubled on every retry
+	MaxDelay         time.Duration // backoff cap, zero means no cap
+	FailureThreshold int           // consecutive failures that open the breaker, zero disables it
+	OpenTimeout      time.Duration // how long the breaker stays open before a trial request
+}
+
+// DefaultPolicy is a reasonable policy for small JSON APIs
+var DefaultPolicy = Policy{
+	Timeout:          10 * time.Second,
+	MaxRetries:       3,
+	BaseDelay:        200 * time.Millisecond,
+	MaxDelay:         5 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+// Hooks are called synchronously; any of them may be nil
+type Hooks struct {
+	OnAttempt      func(req *http.Request, attempt int)
+	OnRetry        func(req *http.Request, attempt int, wait time.Duration, res *http.Response, err error)
+	OnBreakerState func(from, to State)
+}
+
+// Transport wraps Base with the policy
+type Transport struct {
+	Base    http.RoundTripper
+	policy  Policy
+	hooks   Hooks
+	breaker *Breaker
+}
+
+// New returns a Transport around base (http.DefaultTransport when nil)
+func New(base http.RoundTripper, policy Policy, hooks Hooks) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		Base:    base,
+		policy:  policy,
+		hooks:   hooks,
+		breaker: NewBreaker(policy.FailureThreshold, policy.OpenTimeout, hooks.OnBreakerState),
+	}
+}
+
+// NewClient returns an http.Client using a new Transport over http.DefaultTransport
+func NewClient(policy Policy, hooks Hooks) *http.Client {
+	return &http.Client{Transport: New(nil, policy, hooks)}
+}
+
+// Breaker exposes the circuit breaker, mainly for status reporting
+func (t *Transport) Breaker() *Breaker { return t.breaker }
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(req) {
+		retries = t.policy.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		record, err := t.breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		if t.hooks.OnAttempt != nil {
+			t.hooks.OnAttempt(req, attempt)
+		}
+
+		res, err := t.attempt(req, attempt)
+		failed := err != nil || res.StatusCode >= 500
+		record(!failed)
+
+		if !failed || attempt > retries || req.Context().Err() != nil {
+			return res, err
+		}
+
+		wait := t.backoff(attempt)
+		if t.hooks.OnRetry != nil {
+			t.hooks.OnRetry(req, attempt, wait, res, err)
+		}
+		if res != nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends one copy of req, bounded by the per-attempt timeout
+func (t *Transport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if t.policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.policy.Timeout)
+	}
+
+	out := req.Clone(ctx)
+	if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			cancel()
+			return nil, errors.New("resilient: request body cannot be replayed for retry")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		out.Body = body
+	}
+
+	res, err := t.Base.RoundTrip(out)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// keep the attempt context alive until the caller is done with the body
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (t *Transport) backoff(attempt int) time.Duration {
+	wait := t.policy.BaseDelay << min(attempt-1, 20)
+	if t.policy.MaxDelay > 0 && (wait > t.policy.MaxDelay || wait <= 0) {
+		wait = t.policy.MaxDelay
+	}
+	return wait
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
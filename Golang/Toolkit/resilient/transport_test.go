@@ -0,0 +1,174 @@
+package resilient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastPolicy = Policy{
+	Timeout:    50 * time.Millisecond,
+	MaxRetries: 3,
+	BaseDelay:  time.Millisecond,
+	MaxDelay:   5 * time.Millisecond,
+}
+
+func TestRetriesIdempotentOn5xx(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var attempts, retries int
+	client := NewClient(fastPolicy, Hooks{
+		OnAttempt: func(*http.Request, int) { attempts++ },
+		OnRetry:   func(*http.Request, int, time.Duration, *http.Response, error) { retries++ },
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("expected replayed body on 3rd attempt, got %d %q", res.StatusCode, body)
+	}
+	if attempts != 3 || retries != 2 {
+		t.Fatalf("expected 3 attempts and 2 retries, got %d and %d", attempts, retries)
+	}
+}
+
+func TestDoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	res, err := NewClient(fastPolicy, Hooks{}).Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if calls.Load() != 1 {
+		t.Fatalf("POST must not be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestTimeoutIsRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	res, err := NewClient(fastPolicy, Hooks{}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "ok" || calls.Load() != 2 {
+		t.Fatalf("expected success on 2nd attempt, got %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var changes []string
+	policy := Policy{FailureThreshold: 2, OpenTimeout: time.Hour}
+	transport := New(nil, policy, Hooks{OnBreakerState: func(from, to State) {
+		changes = append(changes, from.String()+"->"+to.String())
+	}})
+	now := time.Now()
+	transport.Breaker().now = func() time.Time { return now }
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	healthy.Store(true)
+	now = now.Add(2 * time.Hour)
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	want := "closed->open,open->half-open,half-open->closed"
+	if got := strings.Join(changes, ","); got != want {
+		t.Fatalf("state changes = %s, want %s", got, want)
+	}
+}
+
+func TestBackoffDoesNotOverflow(t *testing.T) {
+	tr := New(nil, Policy{BaseDelay: time.Second}, Hooks{})
+	for _, attempt := range []int{1, 40, 64, 1000} {
+		if wait := tr.backoff(attempt); wait < time.Second {
+			t.Fatalf("attempt %d: wait %s", attempt, wait)
+		}
+	}
+}
+
+func TestLateResultDoesNotCloseBreaker(t *testing.T) {
+	b := NewBreaker(1, time.Hour, nil)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	slow, _ := b.Allow() // sent while closed, answers after the trip
+	fail, _ := b.Allow()
+	fail(false)
+	now = now.Add(2 * time.Hour)
+	trial, err := b.Allow()
+	if err != nil || b.State() != StateHalfOpen {
+		t.Fatalf("%v %s", err, b.State())
+	}
+	slow(true)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("a request from before the trip closed the breaker: %s", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request during the trial: %v", err)
+	}
+	trial(true)
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+}
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule says when a job runs next
+type Schedule interface {
+	// Next returns the first run strictly after t, or the zero time for never
+	Next(t time.Time) time.Time
+}
+
+// Every runs a job at a fixed interval, measured from the previous run's
+// due time so the runs don't drift
+func Every(d time.Duration) Schedule {
+	return every(max(d, time.Millisecond))
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+func (e every) String() string { return "@every " + time.Duration(e).String() }
+
+// Cron is a standard five field expression: minute, hour, day of month,
+// month and day of week. Fields take *, lists, ranges and steps ("*/15",
+// "1-5", "mon,wed,fri"). When both day fields are restricted, a day matching
+// either runs, as in cron(8).
+type Cron struct {
+	expr                     string
+	minute, hour, dom, month uint64
+	dow                      uint64
+	domAny, dowAny           bool
+	loc                      *time.Location
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Parse reads a cron expression, a macro like "@daily", or "@every 90s".
+// Cron times are in the local time zone; see Cron.In.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		dur, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || dur <= 0 {
+			return nil, fmt.Errorf("scheduler: %q: need a positive duration", expr)
+		}
+		return Every(dur), nil
+	}
+	return ParseCron(expr)
+}
+
+// MustParse is Parse for expressions known to be valid
+func MustParse(expr string) Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ParseCron reads a five field expression or a macro
+func ParseCron(expr string) (*Cron, error) {
+	spec := expr
+	if m, ok := macros[expr]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: %q: want 5 fields, got %d", expr, len(fields))
+	}
+	c := &Cron{expr: expr, loc: time.Local}
+	var err error
+	parse := func(field string, lo, hi int, names []string, nameBase int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var bits uint64
+		bits, err = parseField(field, lo, hi, names, nameBase)
+		if err != nil {
+			err = fmt.Errorf("scheduler: %q: %w", expr, err)
+		}
+		return bits
+	}
+	c.minute = parse(fields[0], 0, 59, nil, 0)
+	c.hour = parse(fields[1], 0, 23, nil, 0)
+	c.dom = parse(fields[2], 1, 31, nil, 0)
+	c.month = parse(fields[3], 1, 12, monthNames, 1)
+	c.dow = parse(fields[4], 0, 7, dayNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 7 is another Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domAny = fields[2] == "*" || fields[2] == "?"
+	c.dowAny = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// In returns a copy of c that reads times in loc
+func (c *Cron) In(loc *time.Location) *Cron {
+	cp := *c
+	cp.loc = loc
+	return &cp
+}
+
+func (c *Cron) String() string { return c.expr }
+
+func parseField(field string, lo, hi int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		switch {
+		case rng == "*" || rng == "?":
+		default:
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = value(a, lo, hi, names, nameBase); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = value(b, lo, hi, names, nameBase); err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("backwards range %q", part)
+				}
+			} else if hasStep {
+				// "5/15" is "5-max/15"
+				end = hi
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func value(s string, lo, hi int, names []string, nameBase int) (int, error) {
+	for i, n := range names {
+		if strings.EqualFold(s, n) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("%q is not in %d-%d", s, lo, hi)
+	}
+	return v, nil
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute after t. Wall clock times skipped
+// by a daylight saving change don't run that day.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	// an expression like "0 0 30 2 *" never matches; give up after 5 years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc))
+		case !c.dayMatches(t):
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc))
+		case c.hour&(1<<t.Hour()) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc))
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// forward returns n, the start of the next month, day or hour after t. When
+// that wall clock time falls in a daylight saving gap time.Date picks the
+// hour before it, which can be at or before t; step over the gap instead.
+func forward(t, n time.Time) time.Time {
+	if n.After(t) {
+		return n
+	}
+	return n.Add(time.Hour)
+}
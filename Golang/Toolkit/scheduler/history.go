@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+// Status is how a run ended
+type Status int
+
+const (
+	Succeeded Status = iota
+	Failed           // returned an error or panicked
+	TimedOut         // Job.Timeout passed
+	Cancelled        // the scheduler stopped
+	Skipped          // Overlap said no
+	Missed           // the scheduler wasn't running, CatchUp said skip
+)
+
+var statusNames = []string{"succeeded", "failed", "timed_out", "cancelled", "skipped", "missed"}
+
+func (s Status) String() string {
+	if int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return "unknown"
+}
+
+func (s Status) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+// what started a run
+const (
+	kindScheduled = "scheduled"
+	kindCatchUp   = "catch-up"
+	kindManual    = "manual"
+)
+
+// Run is one execution of a job, or a decision not to execute it
+type Run struct {
+	Job       string        `json:"job"`
+	Kind      string        `json:"kind"`      // scheduled, catch-up or manual
+	Scheduled time.Time     `json:"scheduled"` // when it was due
+	Started   time.Time     `json:"started"`   // zero if it never started
+	Finished  time.Time     `json:"finished"`
+	Duration  time.Duration `json:"duration_ns"`
+	Status    Status        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Late is how long after its due time a run started, pool waits and jitter
+// included
+func (r Run) Late() time.Duration {
+	if r.Started.IsZero() {
+		return 0
+	}
+	return r.Started.Sub(r.Scheduled)
+}
+
+// Handler serves the jobs and their history as JSON:
+//
+//	GET  /jobs             every job with its next and last run
+//	GET  /jobs/{name}/runs recent runs, newest first
+//	POST /jobs/{name}/run  trigger a run now
+func (s *Scheduler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Jobs())
+	})
+	mux.HandleFunc("GET /jobs/{name}/runs", func(w http.ResponseWriter, r *http.Request) {
+		runs, err := s.History(r.PathValue("name"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, runs)
+	})
+	mux.HandleFunc("POST /jobs/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if _, err := s.History(name); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := s.Trigger(name); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"triggered": name})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// stateFile holds each job's last scheduled run as a JSON object
+type stateFile struct {
+	path string
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func loadState(path string) (*stateFile, error) {
+	st := &stateFile{path: path, last: map[string]time.Time{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &st.last); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (st *stateFile) get(job string) (time.Time, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	t, ok := st.last[job]
+	return t, ok
+}
+
+func (st *stateFile) set(job string, t time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.last[job] = t
+	return fsutil.WriteFileAtomic(st.path, 0o644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(st.last)
+	})
+}
@@ -0,0 +1,437 @@
+// Package scheduler runs registered jobs on cron expressions and intervals,
+// what Concurrency/goroutines.go and Goroutine/sync.WaitGroup.go approximate
+// with time.Sleep. Jobs share a worker pool, get jittered start times and a
+// timeout each, don't overlap themselves unless allowed, and can catch up on
+// runs missed while the process was down. Recent runs are kept per job.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Overlap says what happens when a job is due while its last run is still
+// going
+type Overlap int
+
+const (
+	// SkipIfRunning records the run as skipped
+	SkipIfRunning Overlap = iota
+	// AllowOverlap starts another run alongside
+	AllowOverlap
+)
+
+// CatchUp says what happens to runs missed while the scheduler was stopped
+// or stalled
+type CatchUp int
+
+const (
+	// SkipMissed forgets them and waits for the next one
+	SkipMissed CatchUp = iota
+	// RunOnce runs once for all of them
+	RunOnce
+	// RunAll runs every missed run, up to Config.MaxCatchUp
+	RunAll
+)
+
+// Job is a function and when to run it
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Func     func(ctx context.Context) error
+	// Timeout cancels a run's context after this long; 0 for none
+	Timeout time.Duration
+	// Jitter delays each run by a random amount up to this, spreading jobs
+	// that share a schedule
+	Jitter  time.Duration
+	Overlap Overlap
+	CatchUp CatchUp
+}
+
+// Config configures a Scheduler
+type Config struct {
+	// MaxConcurrent bounds runs in progress across all jobs, default 4. Runs
+	// that find the pool full wait for a slot.
+	MaxConcurrent int
+	// HistorySize is how many runs are kept per job, default 50
+	HistorySize int
+	// StateFile, if set, remembers each job's last scheduled run so a restart
+	// knows what it missed
+	StateFile string
+	// Grace is how late a run can be before it counts as missed, default 1s
+	Grace time.Duration
+	// MaxCatchUp bounds RunAll, default 100
+	MaxCatchUp int
+	// OnRun is called after every run, skipped ones included
+	OnRun func(Run)
+	// OnError is told when StateFile can't be written
+	OnError func(job string, err error)
+}
+
+func (c *Config) defaults() {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 4
+	}
+	if c.HistorySize <= 0 {
+		c.HistorySize = 50
+	}
+	if c.Grace <= 0 {
+		c.Grace = time.Second
+	}
+	if c.MaxCatchUp <= 0 {
+		c.MaxCatchUp = 100
+	}
+}
+
+// Scheduler runs jobs; add them with Add and start it with Run
+type Scheduler struct {
+	cfg   Config
+	slots chan struct{}
+	state *stateFile
+
+	mu   sync.Mutex
+	jobs map[string]*entry
+	ctx  context.Context // set while Run is running
+	wg   sync.WaitGroup
+}
+
+type entry struct {
+	job     Job
+	running int
+	next    time.Time
+	history []Run // ring, oldest first once full
+	start   int
+	trigger chan time.Time
+}
+
+// New returns a Scheduler; StateFile, if set, is read here
+func New(cfg Config) (*Scheduler, error) {
+	cfg.defaults()
+	s := &Scheduler{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxConcurrent),
+		jobs:  map[string]*entry{},
+	}
+	if cfg.StateFile != "" {
+		st, err := loadState(cfg.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		s.state = st
+	}
+	return s, nil
+}
+
+// Add registers a job; if the scheduler is running the job starts at once
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" || j.Schedule == nil || j.Func == nil {
+		return errors.New("scheduler: job needs a name, a schedule and a func")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[j.Name]; ok {
+		return fmt.Errorf("scheduler: duplicate job %q", j.Name)
+	}
+	e := &entry{job: j, trigger: make(chan time.Time, 1)}
+	s.jobs[j.Name] = e
+	if s.ctx != nil && s.ctx.Err() == nil {
+		s.start(s.ctx, e)
+	}
+	return nil
+}
+
+// Run schedules every job until ctx is done, then cancels runs in progress
+// and waits for them
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return errors.New("scheduler: already running")
+	}
+	s.ctx = ctx
+	for _, e := range s.jobs {
+		s.start(ctx, e)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	// nothing new starts once ctx is cleared, so Wait can't race an Add
+	s.mu.Lock()
+	s.ctx = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Trigger runs a job now, outside its schedule. The run still takes a pool
+// slot and respects Overlap.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	running := s.ctx != nil
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("scheduler: no job %q", name)
+	}
+	if !running {
+		return errors.New("scheduler: not running")
+	}
+	select {
+	case e.trigger <- time.Now():
+	default:
+		// one is already pending
+	}
+	return nil
+}
+
+// start launches e's loop; s.mu is held
+func (s *Scheduler) start(ctx context.Context, e *entry) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, e)
+	}()
+}
+
+// loop waits for each of a job's runs and dispatches it
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	name := e.job.Name
+	last := time.Now()
+	if s.state != nil {
+		if t, ok := s.state.get(name); ok {
+			last = t
+		}
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		next := e.job.Schedule.Next(last)
+		if next.IsZero() {
+			s.setNext(e, time.Time{})
+			select {
+			case at := <-e.trigger:
+				s.dispatch(ctx, e, at, kindManual)
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if now := time.Now(); now.Sub(next) > s.cfg.Grace {
+			last = s.catchUp(ctx, e, next, now)
+			continue
+		}
+
+		wait := time.Until(next)
+		if e.job.Jitter > 0 {
+			wait += rand.N(e.job.Jitter)
+		}
+		s.setNext(e, next)
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+			s.dispatch(ctx, e, next, kindScheduled)
+			last = next
+			s.saveState(name, last)
+		case at := <-e.trigger:
+			timer.Stop()
+			s.dispatch(ctx, e, at, kindManual)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// catchUp handles runs from first up to now that were missed and returns
+// the last of them
+func (s *Scheduler) catchUp(ctx context.Context, e *entry, first, now time.Time) time.Time {
+	missed, count := []time.Time{first}, 1
+	for t := e.job.Schedule.Next(first); !t.IsZero() && !t.After(now); t = e.job.Schedule.Next(t) {
+		count++
+		missed = append(missed, t)
+		// don't let a long outage of a short interval build a huge slice
+		if len(missed) > s.cfg.MaxCatchUp {
+			missed = missed[1:]
+		}
+	}
+	last := missed[len(missed)-1]
+
+	switch e.job.CatchUp {
+	case RunOnce:
+		s.dispatch(ctx, e, last, kindCatchUp)
+	case RunAll:
+		// one after another, or SkipIfRunning would skip all but the first
+		for _, t := range missed {
+			if ctx.Err() != nil {
+				break
+			}
+			if e.job.Overlap == AllowOverlap {
+				s.dispatch(ctx, e, t, kindCatchUp)
+			} else {
+				s.run(ctx, e, t, kindCatchUp)
+			}
+		}
+	default:
+		s.record(e, Run{Job: e.job.Name, Scheduled: last, Status: Missed, Kind: kindCatchUp,
+			Error: fmt.Sprintf("%d runs missed", count)})
+	}
+	s.saveState(e.job.Name, last)
+	return last
+}
+
+func (s *Scheduler) setNext(e *entry, t time.Time) {
+	s.mu.Lock()
+	e.next = t
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) saveState(name string, t time.Time) {
+	if s.state == nil {
+		return
+	}
+	// the schedule goes on regardless; the worst case is a repeat run after
+	// a restart
+	if err := s.state.set(name, t); err != nil && s.cfg.OnError != nil {
+		s.cfg.OnError(name, err)
+	}
+}
+
+// dispatch starts a run in its own goroutine unless Overlap forbids it
+func (s *Scheduler) dispatch(ctx context.Context, e *entry, at time.Time, kind string) {
+	s.mu.Lock()
+	if e.running > 0 && e.job.Overlap == SkipIfRunning {
+		s.mu.Unlock()
+		s.record(e, Run{Job: e.job.Name, Scheduled: at, Status: Skipped, Kind: kind, Error: "previous run still going"})
+		return
+	}
+	// counted from now, so a run waiting for a slot also blocks overlap
+	e.running++
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(ctx, e, at, kind)
+	}()
+}
+
+// run is dispatch that waits for the run and doesn't check Overlap
+func (s *Scheduler) run(ctx context.Context, e *entry, at time.Time, kind string) {
+	s.mu.Lock()
+	e.running++
+	s.mu.Unlock()
+	s.execute(ctx, e, at, kind)
+}
+
+// execute waits for a pool slot, runs the job and records it; e.running was
+// incremented for it
+func (s *Scheduler) execute(ctx context.Context, e *entry, at time.Time, kind string) {
+	defer func() {
+		s.mu.Lock()
+		e.running--
+		s.mu.Unlock()
+	}()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		s.record(e, Run{Job: e.job.Name, Scheduled: at, Status: Cancelled, Kind: kind, Error: ctx.Err().Error()})
+		return
+	}
+	r := call(ctx, e.job, at, kind)
+	<-s.slots
+	s.record(e, r)
+}
+
+// call runs j.Func once under its timeout
+func call(ctx context.Context, j Job, at time.Time, kind string) (r Run) {
+	r = Run{Job: j.Name, Scheduled: at, Kind: kind, Started: time.Now()}
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		r.Finished = time.Now()
+		r.Duration = r.Finished.Sub(r.Started)
+		if v := recover(); v != nil {
+			r.Status, r.Error = Failed, fmt.Sprintf("panic: %v", v)
+		}
+	}()
+
+	err := j.Func(ctx)
+	switch {
+	case err == nil:
+		r.Status = Succeeded
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		r.Status, r.Error = TimedOut, err.Error()
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		r.Status, r.Error = Cancelled, err.Error()
+	default:
+		r.Status, r.Error = Failed, err.Error()
+	}
+	return r
+}
+
+func (s *Scheduler) record(e *entry, r Run) {
+	s.mu.Lock()
+	if len(e.history) < s.cfg.HistorySize {
+		e.history = append(e.history, r)
+	} else {
+		e.history[e.start] = r
+		e.start = (e.start + 1) % len(e.history)
+	}
+	s.mu.Unlock()
+	if s.cfg.OnRun != nil {
+		s.cfg.OnRun(r)
+	}
+}
+
+// History returns a job's recent runs, newest first
+func (s *Scheduler) History(name string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("scheduler: no job %q", name)
+	}
+	return e.runs(), nil
+}
+
+// runs is e.history newest first; s.mu is held
+func (e *entry) runs() []Run {
+	out := make([]Run, 0, len(e.history))
+	for i := len(e.history) - 1; i >= 0; i-- {
+		out = append(out, e.history[(e.start+i)%len(e.history)])
+	}
+	return out
+}
+
+// JobStatus summarizes one job
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Next     time.Time `json:"next"` // zero when it never runs again
+	Running  int       `json:"running"`
+	Last     *Run      `json:"last,omitempty"`
+}
+
+// Jobs lists every job by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		st := JobStatus{Name: e.job.Name, Schedule: fmt.Sprint(e.job.Schedule), Next: e.next, Running: e.running}
+		if runs := e.runs(); len(runs) > 0 {
+			st.Last = &runs[0]
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
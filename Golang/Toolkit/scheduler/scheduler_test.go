@@ -0,0 +1,325 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, c := range []struct{ expr, from, want string }{
+		{"*/15 * * * *", "2026-03-02 10:07", "2026-03-02 10:15"},
+		{"*/15 * * * *", "2026-03-02 10:15", "2026-03-02 10:30"},
+		// Saturday to Monday
+		{"0 9 * * mon-fri", "2026-03-07 12:00", "2026-03-09 09:00"},
+		// both day fields restricted: the 1st or the 15th or any Friday
+		{"0 0 1,15 * 5", "2026-03-02 00:00", "2026-03-06 00:00"},
+		{"0 0 1,15 * 5", "2026-03-13 00:00", "2026-03-15 00:00"},
+		{"@monthly", "2026-03-02 10:00", "2026-04-01 00:00"},
+		{"0 12 * jan,jul *", "2026-03-02 10:00", "2026-07-01 12:00"},
+		{"5/20 * * * *", "2026-03-02 10:26", "2026-03-02 10:45"},
+		{"0 0 * * 7", "2026-03-02 10:00", "2026-03-08 00:00"},
+		{"0 0 29 2 *", "2026-03-02 10:00", "2028-02-29 00:00"},
+		{"0 0 30 2 *", "2026-03-02 10:00", ""},
+	} {
+		cron, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := cron.In(time.UTC).Next(utc(c.from))
+		if c.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%s: got %v, want never", c.expr, got)
+			}
+			continue
+		}
+		if !got.Equal(utc(c.want)) {
+			t.Errorf("%s from %s: got %v, want %s", c.expr, c.from, got, c.want)
+		}
+	}
+
+	// 02:30 doesn't exist on the day New York springs forward
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	cron, _ := ParseCron("30 2 * * *")
+	got := cron.In(ny).Next(time.Date(2026, 3, 8, 0, 0, 0, 0, ny))
+	if want := time.Date(2026, 3, 9, 2, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("DST: got %v", got)
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@every -1s"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if s := MustParse("@every 90s"); s.Next(utc("2026-03-02 10:00")) != utc("2026-03-02 10:01").Add(30*time.Second) {
+		t.Error("@every")
+	}
+}
+
+// never only runs when triggered
+type never struct{}
+
+func (never) Next(time.Time) time.Time { return time.Time{} }
+
+// start runs s until the test ends
+func start(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func count(runs []Run, st Status) int {
+	n := 0
+	for _, r := range runs {
+		if r.Status == st {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPoolAndOverlap(t *testing.T) {
+	s, _ := New(Config{MaxConcurrent: 2})
+	var running, peak atomic.Int32
+	work := func(ctx context.Context) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(40 * time.Millisecond)
+		return nil
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		// due every 10ms but each run takes 40ms
+		if err := s.Add(Job{Name: name, Schedule: Every(10 * time.Millisecond), Func: work, Jitter: 5 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(Job{Name: "a", Schedule: Every(time.Second), Func: work}); err == nil {
+		t.Fatal("duplicate name accepted")
+	}
+	start(t, s)
+	waitFor(t, "runs of every job", func() bool {
+		for _, j := range s.Jobs() {
+			runs, _ := s.History(j.Name)
+			if count(runs, Succeeded) < 2 || count(runs, Skipped) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	if p := peak.Load(); p > 2 {
+		t.Fatalf("%d runs at once with a pool of 2", p)
+	}
+	for _, j := range s.Jobs() {
+		if j.Running > 1 {
+			t.Fatalf("%s overlaps itself: %+v", j.Name, j)
+		}
+	}
+}
+
+func TestTimeoutPanicAndTrigger(t *testing.T) {
+	var mu sync.Mutex
+	var seen []Run
+	s, _ := New(Config{OnRun: func(r Run) {
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+	}})
+	s.Add(Job{Name: "slow", Schedule: never{}, Timeout: 20 * time.Millisecond, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Add(Job{Name: "boom", Schedule: never{}, Func: func(context.Context) error { panic("boom") }})
+	s.Add(Job{Name: "fails", Schedule: never{}, Func: func(context.Context) error { return errors.New("nope") }})
+
+	if err := s.Trigger("slow"); err == nil {
+		t.Fatal("triggered before Run")
+	}
+	start(t, s)
+	waitFor(t, "Run to start", func() bool { return s.Trigger("slow") == nil })
+	s.Trigger("boom")
+	s.Trigger("fails")
+	if err := s.Trigger("nope"); err == nil {
+		t.Fatal("unknown job triggered")
+	}
+	waitFor(t, "three runs", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) == 3
+	})
+
+	for name, want := range map[string]string{"slow": "timed_out", "boom": "failed panic: boom", "fails": "failed nope"} {
+		runs, _ := s.History(name)
+		if len(runs) != 1 {
+			t.Fatalf("%s: %+v", name, runs)
+		}
+		r := runs[0]
+		if got := strings.TrimSpace(r.Status.String() + " " + strings.TrimPrefix(r.Error, "context deadline exceeded")); got != want || r.Kind != kindManual {
+			t.Errorf("%s: got %q, %+v", name, got, r)
+		}
+	}
+	if runs, _ := s.History("slow"); runs[0].Duration < 20*time.Millisecond {
+		t.Errorf("timed out after %s", runs[0].Duration)
+	}
+}
+
+func TestCatchUpAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	// the last runs were ten hours ago
+	last := time.Now().Add(-10*time.Hour - time.Minute)
+	b, _ := json.Marshal(map[string]time.Time{"skip": last, "once": last, "all": last})
+	os.WriteFile(path, b, 0o644)
+
+	s, err := New(Config{StateFile: path, MaxConcurrent: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran sync.Map
+	job := func(name string, c CatchUp) Job {
+		return Job{Name: name, Schedule: Every(time.Hour), CatchUp: c, Func: func(context.Context) error {
+			v, _ := ran.LoadOrStore(name, new(atomic.Int32))
+			v.(*atomic.Int32).Add(1)
+			return nil
+		}}
+	}
+	s.Add(job("skip", SkipMissed))
+	s.Add(job("once", RunOnce))
+	s.Add(job("all", RunAll))
+	start(t, s)
+
+	runs := func(name string) int {
+		h, _ := s.History(name)
+		return len(h)
+	}
+	waitFor(t, "catch-up", func() bool { return runs("skip") == 1 && runs("once") == 1 && runs("all") == 10 })
+	time.Sleep(20 * time.Millisecond)
+
+	h, _ := s.History("skip")
+	if h[0].Status != Missed || h[0].Error != "10 runs missed" {
+		t.Fatalf("%+v", h[0])
+	}
+	if _, ok := ran.Load("skip"); ok {
+		t.Fatal("SkipMissed ran")
+	}
+	h, _ = s.History("all")
+	if count(h, Succeeded) != 10 || !h[9].Scheduled.Equal(last.Add(time.Hour)) || h[0].Kind != kindCatchUp {
+		t.Fatalf("%+v", h)
+	}
+
+	// the state now points at the last missed run, not ten hours back
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.get("all"); !got.Equal(last.Add(10 * time.Hour)) {
+		t.Fatalf("state %v", got)
+	}
+	for _, j := range s.Jobs() {
+		if time.Until(j.Next) < 50*time.Minute {
+			t.Fatalf("%s next at %v", j.Name, j.Next)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s, _ := New(Config{})
+	done := make(chan struct{}, 1)
+	s.Add(Job{Name: "report", Schedule: MustParse("@daily"), Func: func(context.Context) error {
+		done <- struct{}{}
+		return nil
+	}})
+	start(t, s)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	var jobs []JobStatus
+	waitFor(t, "the job to be scheduled", func() bool {
+		res, err := http.Get(srv.URL + "/jobs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		json.NewDecoder(res.Body).Decode(&jobs)
+		return len(jobs) == 1 && !jobs[0].Next.IsZero()
+	})
+	if jobs[0].Schedule != "@daily" || jobs[0].Last != nil {
+		t.Fatalf("%+v", jobs)
+	}
+
+	res, err := http.Post(srv.URL+"/jobs/report/run", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatal(res.Status)
+	}
+	<-done
+	var runs []map[string]any
+	waitFor(t, "the run to be recorded", func() bool {
+		res, err := http.Get(srv.URL + "/jobs/report/runs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		json.NewDecoder(res.Body).Decode(&runs)
+		return len(runs) == 1
+	})
+	if runs[0]["status"] != "succeeded" || runs[0]["kind"] != "manual" {
+		t.Fatalf("%v", runs)
+	}
+
+	for _, path := range []string{"/jobs/nope/runs", "/jobs/nope/run"} {
+		method := http.MethodGet
+		if strings.HasSuffix(path, "/run") {
+			method = http.MethodPost
+		}
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: %s", path, res.Status)
+		}
+	}
+}
@@ -0,0 +1,708 @@
+// Package taskqueue is a persistent version of the buffered channel in
+// Golang/Channel/Bufferred.Channel.go: producers Enqueue, consumers Lease a
+// task and Ack it when done. Every change is in a write-ahead log on disk
+// before the call returns, so a crash loses nothing that was acknowledged.
+//
+// A lease hides its task from other consumers until its visibility timeout;
+// a consumer that dies or stalls lets the task go back to the queue, so
+// delivery is at least once. Tasks have priorities and can be delayed, and a
+// task that fails MaxAttempts times moves to a dead letter queue.
+//
+// Consumers live in the same process as the queue, so leases don't survive
+// a restart: on Open every task that was leased is ready again, with the
+// interrupted attempt counted.
+package taskqueue
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+	"github.com/Shehbab-Kakkar/toolkit/internal/wal"
+)
+
+var (
+	// ErrClosed is returned after Close
+	ErrClosed = errors.New("taskqueue: closed")
+	// ErrLeaseLost means the lease expired or was already settled; another
+	// consumer may have the task now
+	ErrLeaseLost = errors.New("taskqueue: lease lost")
+	// ErrNotFound means no task has the ID
+	ErrNotFound = errors.New("taskqueue: no such task")
+	// ErrDuplicate is returned by Enqueue for an ID that is still queued
+	ErrDuplicate = errors.New("taskqueue: duplicate task ID")
+)
+
+const logName = "tasks.log"
+
+// State is where a task is
+type State string
+
+const (
+	Ready   State = "ready"
+	Delayed State = "delayed" // not due yet
+	Leased  State = "leased"
+	Dead    State = "dead"
+)
+
+// Task is a unit of work
+type Task struct {
+	ID          string    `json:"id"`
+	Payload     []byte    `json:"payload"`
+	Priority    int       `json:"priority"` // higher first
+	State       State     `json:"state"`
+	Attempts    int       `json:"attempts"` // leases so far
+	MaxAttempts int       `json:"max_attempts"`
+	Enqueued    time.Time `json:"enqueued"`
+	NotBefore   time.Time `json:"not_before"` // when a delayed task is due
+	LastError   string    `json:"last_error,omitempty"`
+	Seq         uint64    `json:"seq"` // FIFO order within a priority
+
+	token      uint64 // current lease
+	leaseUntil time.Time
+}
+
+// Options configures a Queue
+type Options struct {
+	Dir string
+	// VisibilityTimeout is how long a lease hides its task, default 30s
+	VisibilityTimeout time.Duration
+	// MaxAttempts is the default for tasks that don't set one, default 5
+	MaxAttempts int
+	// RetryDelay is how long Nack waits before a task's next attempt; the
+	// default doubles from 1s up to 5m
+	RetryDelay func(attempts int) time.Duration
+	// CompactEvery rewrites the log with only the live tasks once it has
+	// this many records and most are obsolete, default 10000
+	CompactEvery int
+}
+
+func (o *Options) defaults() {
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = 30 * time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.RetryDelay == nil {
+		o.RetryDelay = func(attempts int) time.Duration {
+			return min(time.Second<<min(max(attempts-1, 0), 20), 5*time.Minute)
+		}
+	}
+	if o.CompactEvery <= 0 {
+		o.CompactEvery = 10_000
+	}
+}
+
+// Queue is a durable task queue in one directory. Use one Queue per
+// directory.
+type Queue struct {
+	opts Options
+	path string
+
+	mu      sync.Mutex
+	f       logFile
+	tasks   map[string]*Task
+	ready   readyHeap
+	waiting waitHeap
+	seq     uint64
+	token   uint64
+	records int // in the log
+	changed chan struct{}
+	closed  bool
+}
+
+// Open replays the log in opts.Dir, creating it if needed
+func Open(opts Options) (*Queue, error) {
+	opts.defaults()
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		opts:    opts,
+		path:    filepath.Join(opts.Dir, logName),
+		tasks:   map[string]*Task{},
+		changed: make(chan struct{}),
+	}
+	f, err := os.OpenFile(q.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	end, err := wal.Replay(f, func(r record) {
+		q.apply(r)
+		q.records++
+	})
+	if err == nil {
+		// drop a torn tail so new records follow the last good one
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	q.f = f
+
+	// the consumers holding leases went away with the last process
+	now := time.Now()
+	for _, t := range q.sorted() {
+		if t.State != Leased {
+			q.place(t, now)
+			continue
+		}
+		r := record{Op: opNack, ID: t.ID, At: now.UnixNano(), Err: "lease lost when the queue was reopened"}
+		if t.Attempts >= t.MaxAttempts {
+			r.Op, r.At = opDead, 0
+		}
+		if err := q.commit(r); err != nil {
+			f.Close()
+			return nil, err
+		}
+		q.place(t, now)
+	}
+	return q, nil
+}
+
+// apply makes the change r records to the task map. It doesn't touch the
+// heaps, so replay can build them once at the end.
+func (q *Queue) apply(r record) {
+	if r.Op == opPut {
+		t := *r.Task
+		q.tasks[t.ID] = &t
+		q.seq = max(q.seq, t.Seq)
+		return
+	}
+	t := q.tasks[r.ID]
+	if t == nil {
+		return
+	}
+	switch r.Op {
+	case opLease:
+		t.Attempts++
+		t.State = Leased
+		t.token, t.leaseUntil = r.Token, time.Unix(0, r.At)
+		q.token = max(q.token, r.Token)
+	case opNack:
+		t.State = Delayed
+		t.NotBefore = time.Unix(0, r.At)
+		t.LastError = r.Err
+		t.token = 0
+	case opDead:
+		t.State = Dead
+		t.LastError = r.Err
+		t.token = 0
+	case opRequeue:
+		t.State = Delayed
+		t.Attempts = 0
+		t.NotBefore = time.Unix(0, r.At)
+	case opAck, opDelete:
+		delete(q.tasks, r.ID)
+	}
+}
+
+// commit logs r, syncs it and applies it; q.mu is held
+func (q *Queue) commit(r record) error {
+	buf, err := wal.Append(nil, r)
+	if err != nil {
+		// nothing was written; the log is still good
+		return err
+	}
+	_, err = q.f.Write(buf)
+	if err == nil {
+		err = q.f.Sync()
+	}
+	if err != nil {
+		// a partial record would hide everything appended after it, so stop
+		// writing; Open drops the torn tail
+		q.f.Close()
+		q.f = brokenLog{err}
+		return err
+	}
+	q.apply(r)
+	q.records++
+	if q.records >= q.opts.CompactEvery && q.records > 2*len(q.tasks) {
+		// the record is durable; a failed compaction leaves the old log,
+		// which is still correct, and is retried on the next commit
+		q.compact()
+	}
+	return nil
+}
+
+// compact replaces the log with one put per live task; q.mu is held
+func (q *Queue) compact() error {
+	var buf []byte
+	for _, t := range q.sorted() {
+		var err error
+		if buf, err = wal.Append(buf, record{Op: opPut, Task: t}); err != nil {
+			return err
+		}
+	}
+	err := fsutil.WriteFileAtomic(q.path, 0o644, func(w io.Writer) error {
+		_, err := w.Write(buf)
+		return err
+	})
+	if err == nil {
+		err = fsutil.SyncDir(q.opts.Dir)
+	}
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		// the new log is in place but can't be appended to; fail the next
+		// commits rather than write to the replaced file
+		q.f.Close()
+		q.f = brokenLog{err}
+		return err
+	}
+	q.f.Close()
+	q.f = f
+	q.records = len(q.tasks)
+	return nil
+}
+
+// logFile is the open log; brokenLog stands in once writing it failed
+type logFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
+
+type brokenLog struct{ err error }
+
+func (b brokenLog) Write([]byte) (int, error) { return 0, b.err }
+func (b brokenLog) Sync() error               { return b.err }
+func (b brokenLog) Close() error              { return nil }
+
+// place puts a pending task in the ready or waiting heap; q.mu is held
+func (q *Queue) place(t *Task, now time.Time) {
+	switch t.State {
+	case Ready, Delayed:
+		if t.NotBefore.After(now) {
+			t.State = Delayed
+			heap.Push(&q.waiting, waitEntry{at: t.NotBefore, task: t})
+			return
+		}
+		t.State = Ready
+		heap.Push(&q.ready, t)
+		q.broadcast()
+	case Leased:
+		heap.Push(&q.waiting, waitEntry{at: t.leaseUntil, task: t, token: t.token})
+	}
+}
+
+// broadcast wakes every Lease waiting for a task; q.mu is held
+func (q *Queue) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// promote moves due delayed tasks to ready and expires leases past their
+// visibility timeout; q.mu is held
+func (q *Queue) promote(now time.Time) error {
+	for len(q.waiting) > 0 && !q.waiting[0].at.After(now) {
+		e := heap.Pop(&q.waiting).(waitEntry)
+		t := e.task
+		if q.tasks[t.ID] != t {
+			continue // acked or purged since
+		}
+		switch {
+		case t.State == Delayed && t.NotBefore.Equal(e.at):
+			q.place(t, now)
+		case t.State == Leased && t.token == e.token && t.leaseUntil.Equal(e.at):
+			r := record{Op: opNack, ID: t.ID, At: now.UnixNano(), Err: "visibility timeout"}
+			if t.Attempts >= t.MaxAttempts {
+				r.Op, r.At = opDead, 0
+			}
+			if err := q.commit(r); err != nil {
+				heap.Push(&q.waiting, e)
+				return err
+			}
+			q.place(t, now)
+		}
+	}
+	return nil
+}
+
+// EnqueueOption configures one task
+type EnqueueOption func(*Task)
+
+// WithID names the task; while a task with that ID is queued, leased or
+// dead, Enqueue returns ErrDuplicate. The default is a random ID.
+func WithID(id string) EnqueueOption { return func(t *Task) { t.ID = id } }
+
+// WithPriority orders the task before those with lower priority, default 0
+func WithPriority(p int) EnqueueOption { return func(t *Task) { t.Priority = p } }
+
+// WithDelay keeps the task from being leased for d
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(t *Task) { t.NotBefore = t.NotBefore.Add(max(d, 0)) }
+}
+
+// WithMaxAttempts overrides Options.MaxAttempts
+func WithMaxAttempts(n int) EnqueueOption { return func(t *Task) { t.MaxAttempts = n } }
+
+// Enqueue adds a task and returns its ID once it is on disk. A task whose
+// encoding exceeds wal.MaxEntrySize fails with wal.ErrTooLarge.
+func (q *Queue) Enqueue(payload []byte, opts ...EnqueueOption) (string, error) {
+	now := time.Now()
+	t := &Task{Payload: payload, State: Ready, Enqueued: now, NotBefore: now, MaxAttempts: q.opts.MaxAttempts}
+	for _, o := range opts {
+		o(t)
+	}
+	if t.ID == "" {
+		t.ID = newID()
+	}
+	if t.MaxAttempts <= 0 {
+		t.MaxAttempts = q.opts.MaxAttempts
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return "", ErrClosed
+	}
+	if _, ok := q.tasks[t.ID]; ok {
+		return "", fmt.Errorf("%w: %s", ErrDuplicate, t.ID)
+	}
+	t.Seq = q.seq + 1
+	if err := q.commit(record{Op: opPut, Task: t}); err != nil {
+		return "", err
+	}
+	// commit stored a copy
+	q.place(q.tasks[t.ID], now)
+	return t.ID, nil
+}
+
+// Lease waits for the most urgent ready task and hides it from other
+// consumers for Options.VisibilityTimeout
+func (q *Queue) Lease(ctx context.Context) (*Lease, error) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		l, wake, next, err := q.tryLease()
+		if l != nil || err != nil {
+			return l, err
+		}
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer.Reset(wait)
+		select {
+		case <-wake:
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryLease is Lease without waiting; it returns nil when nothing is ready
+func (q *Queue) TryLease() (*Lease, error) {
+	l, _, _, err := q.tryLease()
+	return l, err
+}
+
+// tryLease leases a ready task, or says what to wait for: a channel closed
+// when a task becomes ready and when the next delayed task or lease is due
+func (q *Queue) tryLease() (*Lease, <-chan struct{}, time.Time, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, nil, time.Time{}, ErrClosed
+	}
+	now := time.Now()
+	if err := q.promote(now); err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	for len(q.ready) > 0 {
+		t := heap.Pop(&q.ready).(*Task)
+		if q.tasks[t.ID] != t || t.State != Ready {
+			continue
+		}
+		until := now.Add(q.opts.VisibilityTimeout)
+		token := q.token + 1
+		if err := q.commit(record{Op: opLease, ID: t.ID, Token: token, At: until.UnixNano()}); err != nil {
+			heap.Push(&q.ready, t)
+			return nil, nil, time.Time{}, err
+		}
+		q.place(t, now)
+		return &Lease{Task: t.snapshot(), Token: token, Until: until, q: q}, nil, time.Time{}, nil
+	}
+	var next time.Time
+	if len(q.waiting) > 0 {
+		next = q.waiting[0].at
+	}
+	return nil, q.changed, next, nil
+}
+
+func (t *Task) snapshot() Task {
+	c := *t
+	c.token, c.leaseUntil = 0, time.Time{}
+	return c
+}
+
+// Lease is a consumer's claim on a task. Settle it with Ack, Nack, NackAfter
+// or Fail before Until, or extend it.
+type Lease struct {
+	Task  Task
+	Token uint64
+	Until time.Time
+
+	q *Queue
+}
+
+// Ack removes the task for good
+func (l *Lease) Ack() error {
+	return l.settle(func(*Task, time.Time) record { return record{Op: opAck, ID: l.Task.ID} })
+}
+
+// Nack returns the task after Options.RetryDelay, or to the dead letter queue
+// when it has used its attempts
+func (l *Lease) Nack(reason error) error {
+	return l.retry(-1, reason)
+}
+
+// NackAfter returns the task to the queue after d
+func (l *Lease) NackAfter(d time.Duration, reason error) error {
+	return l.retry(max(d, 0), reason)
+}
+
+func (l *Lease) retry(d time.Duration, reason error) error {
+	return l.settle(func(t *Task, now time.Time) record {
+		if t.Attempts >= t.MaxAttempts {
+			return record{Op: opDead, ID: t.ID, Err: errString(reason)}
+		}
+		if d < 0 {
+			d = l.q.opts.RetryDelay(t.Attempts)
+		}
+		return record{Op: opNack, ID: t.ID, At: now.Add(d).UnixNano(), Err: errString(reason)}
+	})
+}
+
+// Fail moves the task to the dead letter queue without further attempts,
+// for errors retrying won't fix
+func (l *Lease) Fail(reason error) error {
+	return l.settle(func(t *Task, _ time.Time) record {
+		return record{Op: opDead, ID: t.ID, Err: errString(reason)}
+	})
+}
+
+// Extend pushes Until to d from now, for work that takes longer than the
+// visibility timeout
+func (l *Lease) Extend(d time.Duration) error {
+	q := l.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, now, err := l.current()
+	if err != nil {
+		return err
+	}
+	// not logged: a lease doesn't outlive the process anyway
+	t.leaseUntil = now.Add(d)
+	l.Until = t.leaseUntil
+	q.place(t, now)
+	return nil
+}
+
+// current returns the leased task if l still holds it; q.mu is held
+func (l *Lease) current() (*Task, time.Time, error) {
+	if l.q.closed {
+		return nil, time.Time{}, ErrClosed
+	}
+	now := time.Now()
+	t := l.q.tasks[l.Task.ID]
+	if t == nil || t.State != Leased || t.token != l.Token || now.After(t.leaseUntil) {
+		return nil, time.Time{}, ErrLeaseLost
+	}
+	return t, now, nil
+}
+
+func (l *Lease) settle(change func(t *Task, now time.Time) record) error {
+	q := l.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, now, err := l.current()
+	if err != nil {
+		return err
+	}
+	if err := q.commit(change(t, now)); err != nil {
+		return err
+	}
+	if q.tasks[t.ID] == t {
+		q.place(t, now)
+	}
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Get returns a task by ID
+func (q *Queue) Get(id string) (Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.tasks[id]
+	if !ok {
+		return Task{}, ErrNotFound
+	}
+	return t.snapshot(), nil
+}
+
+// DeadLetters lists the dead tasks, oldest first
+func (q *Queue) DeadLetters() []Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Task
+	for _, t := range q.sorted() {
+		if t.State == Dead {
+			out = append(out, t.snapshot())
+		}
+	}
+	return out
+}
+
+// Requeue gives a dead task a fresh set of attempts
+func (q *Queue) Requeue(id string) error {
+	return q.dead(id, func(now time.Time) record { return record{Op: opRequeue, ID: id, At: now.UnixNano()} })
+}
+
+// Purge deletes a dead task
+func (q *Queue) Purge(id string) error {
+	return q.dead(id, func(time.Time) record { return record{Op: opDelete, ID: id} })
+}
+
+func (q *Queue) dead(id string, change func(now time.Time) record) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	t, ok := q.tasks[id]
+	if !ok || t.State != Dead {
+		return ErrNotFound
+	}
+	now := time.Now()
+	if err := q.commit(change(now)); err != nil {
+		return err
+	}
+	if q.tasks[id] == t {
+		q.place(t, now)
+	}
+	return nil
+}
+
+// Stats counts tasks by state
+type Stats struct {
+	Ready   int `json:"ready"`
+	Delayed int `json:"delayed"`
+	Leased  int `json:"leased"`
+	Dead    int `json:"dead"`
+}
+
+// Stats returns the current counts
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.promote(time.Now())
+	var s Stats
+	for _, t := range q.tasks {
+		switch t.State {
+		case Ready:
+			s.Ready++
+		case Delayed:
+			s.Delayed++
+		case Leased:
+			s.Leased++
+		case Dead:
+			s.Dead++
+		}
+	}
+	return s
+}
+
+// Close releases the log. Outstanding leases fail with ErrClosed; their
+// tasks are ready again on the next Open.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.broadcast()
+	return q.f.Close()
+}
+
+// sorted returns the tasks in enqueue order; q.mu is held
+func (q *Queue) sorted() []*Task {
+	out := make([]*Task, 0, len(q.tasks))
+	for _, t := range q.tasks {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// readyHeap orders ready tasks by priority, then FIFO
+type readyHeap []*Task
+
+func (h readyHeap) Len() int { return len(h) }
+func (h readyHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].Seq < h[j].Seq
+}
+func (h readyHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *readyHeap) Push(x any)   { *h = append(*h, x.(*Task)) }
+func (h *readyHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// waitEntry is a delayed task's due time or a lease's expiry. Entries go
+// stale when the task moves on; promote checks them against the task.
+type waitEntry struct {
+	at    time.Time
+	task  *Task
+	token uint64 // for a lease
+}
+
+type waitHeap []waitEntry
+
+func (h waitHeap) Len() int           { return len(h) }
+func (h waitHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h waitHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *waitHeap) Push(x any)        { *h = append(*h, x.(waitEntry)) }
+func (h *waitHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
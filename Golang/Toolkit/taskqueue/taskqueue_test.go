@@ -0,0 +1,414 @@
+package taskqueue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/wal"
+)
+
+func open(t *testing.T, opts Options) *Queue {
+	t.Helper()
+	q, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func lease(t *testing.T, q *Queue) *Lease {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	l, err := q.Lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestPriorityFIFOAndDelay(t *testing.T) {
+	q := open(t, Options{Dir: t.TempDir()})
+	q.Enqueue([]byte("low-1"))
+	q.Enqueue([]byte("later"), WithDelay(80*time.Millisecond), WithPriority(100))
+	q.Enqueue([]byte("high"), WithPriority(5))
+	q.Enqueue([]byte("low-2"))
+	if _, err := q.Enqueue([]byte("once"), WithID("only-once")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue([]byte("twice"), WithID("only-once")); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("got %v", err)
+	}
+	if st := q.Stats(); st.Ready != 4 || st.Delayed != 1 {
+		t.Fatalf("%+v", st)
+	}
+
+	var got []string
+	for range 4 {
+		l := lease(t, q)
+		got = append(got, string(l.Task.Payload))
+		l.Ack()
+	}
+	if strings.Join(got, " ") != "high low-1 low-2 once" {
+		t.Fatalf("got %q", got)
+	}
+	if l, _ := q.TryLease(); l != nil {
+		t.Fatal("delayed task leased early")
+	}
+	// Lease sleeps until the delayed task is due
+	start := time.Now()
+	if l := lease(t, q); string(l.Task.Payload) != "later" || time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("got %q after %s", l.Task.Payload, time.Since(start))
+	}
+}
+
+func TestLeaseWaitsForEnqueue(t *testing.T) {
+	q := open(t, Options{Dir: t.TempDir()})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.Enqueue([]byte("x"))
+	}()
+	if l := lease(t, q); string(l.Task.Payload) != "x" {
+		t.Fatal(l.Task)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Lease(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	q := open(t, Options{Dir: t.TempDir(), VisibilityTimeout: 30 * time.Millisecond})
+	id, _ := q.Enqueue([]byte("x"))
+
+	first := lease(t, q)
+	if l, _ := q.TryLease(); l != nil {
+		t.Fatal("leased twice")
+	}
+	// a consumer that stalls past the timeout loses the task to the next one
+	second := lease(t, q)
+	if second.Task.ID != id || second.Task.Attempts != 2 || second.Task.LastError != "visibility timeout" {
+		t.Fatalf("%+v", second.Task)
+	}
+	if err := first.Ack(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("stale ack: %v", err)
+	}
+	// Extend keeps it past the original timeout
+	if err := second.Extend(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if l, _ := q.TryLease(); l != nil {
+		t.Fatal("extended lease expired")
+	}
+	if err := second.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Get(id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestRetriesAndDeadLetters(t *testing.T) {
+	q := open(t, Options{Dir: t.TempDir(), MaxAttempts: 3,
+		RetryDelay: func(n int) time.Duration { return time.Duration(n) * time.Millisecond }})
+	flaky, _ := q.Enqueue([]byte("flaky"))
+	bad, _ := q.Enqueue([]byte("bad"), WithMaxAttempts(10))
+
+	for i := 1; i <= 3; i++ {
+		l := lease(t, q)
+		if l.Task.ID == bad {
+			l.Fail(errors.New("malformed"))
+			l = lease(t, q)
+		}
+		if l.Task.Attempts != i {
+			t.Fatalf("attempt %d: %+v", i, l.Task)
+		}
+		l.Nack(fmt.Errorf("try %d", i))
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 2 || dead[0].ID != flaky || dead[0].LastError != "try 3" || dead[1].Attempts != 1 {
+		t.Fatalf("%+v", dead)
+	}
+	if st := q.Stats(); st.Dead != 2 || st.Ready+st.Delayed != 0 {
+		t.Fatalf("%+v", st)
+	}
+
+	if err := q.Requeue(flaky); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Purge(bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Purge(flaky); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purged a live task: %v", err)
+	}
+	if l := lease(t, q); l.Task.ID != flaky || l.Task.Attempts != 1 {
+		t.Fatalf("%+v", l.Task)
+	}
+	if len(q.DeadLetters()) != 0 {
+		t.Fatal("dead letters left")
+	}
+}
+
+func TestReopenRestoresState(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Options{Dir: dir, MaxAttempts: 2, CompactEvery: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		q.Enqueue([]byte(strconv.Itoa(i)), WithPriority(i%2))
+	}
+	// ack half, leave one leased, one delayed and one dead
+	for range 5 {
+		lease(t, q).Ack()
+	}
+	held := lease(t, q)
+	delayed := lease(t, q)
+	delayed.NackAfter(time.Hour, errors.New("later"))
+	dead := lease(t, q)
+	dead.Fail(errors.New("no"))
+	q.Close()
+	if err := held.Ack(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v", err)
+	}
+
+	q = open(t, Options{Dir: dir, MaxAttempts: 2})
+	if st := q.Stats(); st != (Stats{Ready: 3, Delayed: 1, Dead: 1}) {
+		t.Fatalf("%+v", st)
+	}
+	task, _ := q.Get(held.Task.ID)
+	if task.State != Ready || task.Attempts != 1 || !strings.Contains(task.LastError, "reopened") {
+		t.Fatalf("%+v", task)
+	}
+	// the odd ones had priority 1 and were acked; 0, 2 and 4 were held,
+	// delayed and failed, and the rest keep their order
+	var got []string
+	for range 3 {
+		got = append(got, string(lease(t, q).Task.Payload))
+	}
+	if strings.Join(got, " ") != "0 6 8" {
+		t.Fatalf("got %v", got)
+	}
+
+	// the log was compacted along the way
+	if q.records >= 20 {
+		t.Fatalf("%d records, no compaction", q.records)
+	}
+	if _, err := q.Enqueue(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTornTailIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := q.Enqueue([]byte("a"))
+	b, _ := q.Enqueue([]byte("b"))
+	q.Close()
+
+	// a crash in the middle of writing b's record
+	path := filepath.Join(dir, logName)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-5)
+
+	q = open(t, Options{Dir: dir})
+	if _, err := q.Get(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Get(b); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("torn record replayed: %v", err)
+	}
+	// appends land after the last good record, not after the garbage
+	c, _ := q.Enqueue([]byte("c"))
+	q.Close()
+	q = open(t, Options{Dir: dir})
+	if _, err := q.Get(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorruptionBeforeTheTailIsAnError(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "b", "c"} {
+		q.Enqueue([]byte(p))
+	}
+	if _, err := q.Enqueue(make([]byte, wal.MaxEntrySize)); !errors.Is(err, wal.ErrTooLarge) {
+		t.Fatalf("oversized task: %v", err)
+	}
+	q.Close()
+
+	path := filepath.Join(dir, logName)
+	good, _ := os.ReadFile(path)
+	for name, damage := range map[string]func([]byte){
+		// a flipped bit in the first record's payload
+		"checksum": func(b []byte) { b[20] ^= 1 },
+		// a length that would need a 4 GiB buffer
+		"length": func(b []byte) { b[3] = 0xff },
+	} {
+		b := append([]byte(nil), good...)
+		damage(b)
+		os.WriteFile(path, b, 0o644)
+		if _, err := Open(Options{Dir: dir}); !errors.Is(err, wal.ErrCorrupt) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+		// the records after the damage are still there for repair
+		if after, _ := os.ReadFile(path); len(after) != len(good) {
+			t.Fatalf("%s: log truncated to %d bytes", name, len(after))
+		}
+	}
+
+	// the same damage to the last record is a torn write and is dropped
+	b := append([]byte(nil), good...)
+	b[len(b)-2] ^= 1
+	os.WriteFile(path, b, 0o644)
+	q = open(t, Options{Dir: dir})
+	if st := q.Stats(); st.Ready != 2 {
+		t.Fatalf("%+v", st)
+	}
+}
+
+// TestCrashHelper is the process TestCrashRecovery kills. It enqueues and
+// works through tasks forever, printing each step after it returns.
+func TestCrashHelper(t *testing.T) {
+	dir := os.Getenv("TASKQUEUE_CRASH_DIR")
+	if dir == "" {
+		t.Skip("run by TestCrashRecovery")
+	}
+	// compact often so some kills land in the middle of one
+	q, err := Open(Options{Dir: dir, CompactEvery: 64, VisibilityTimeout: time.Minute})
+	if err != nil {
+		fmt.Println("ERR", err)
+		os.Exit(1)
+	}
+	var out sync.Mutex
+	say := func(format string, args ...any) {
+		out.Lock()
+		fmt.Printf(format+"\n", args...)
+		out.Unlock()
+	}
+	go func() {
+		for i := 0; ; i++ {
+			id, err := q.Enqueue([]byte(strconv.Itoa(i)), WithPriority(i%3))
+			if err != nil {
+				say("ERR %v", err)
+				os.Exit(1)
+			}
+			say("E %s", id)
+		}
+	}()
+	for range 3 {
+		go func() {
+			for {
+				l, err := q.Lease(context.Background())
+				if err != nil {
+					say("ERR %v", err)
+					os.Exit(1)
+				}
+				say("L %s", l.Task.ID)
+				if err := l.Ack(); err != nil {
+					say("ERR %v", err)
+					os.Exit(1)
+				}
+				say("A %s", l.Task.ID)
+			}
+		}()
+	}
+	select {}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+	dir := t.TempDir()
+	for round := range 3 {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelper$")
+		cmd.Env = append(os.Environ(), "TASKQUEUE_CRASH_DIR="+dir)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		enqueued, leased, acked := map[string]bool{}, map[string]bool{}, map[string]bool{}
+		sc := bufio.NewScanner(stdout)
+		// keep reading after the kill: what the helper printed before it
+		// died still happened
+		for sc.Scan() {
+			op, id, _ := strings.Cut(sc.Text(), " ")
+			switch op {
+			case "E":
+				enqueued[id] = true
+			case "L":
+				leased[id] = true
+			case "A":
+				acked[id] = true
+			case "ERR":
+				t.Fatalf("helper: %s", id)
+			}
+			if len(acked) == 300 {
+				// kill -9 mid-flight: writes, leases and maybe a compaction
+				// are all in progress
+				cmd.Process.Kill()
+			}
+		}
+		cmd.Wait()
+		if len(acked) < 300 {
+			t.Fatalf("round %d: helper stopped after %d acks", round, len(acked))
+		}
+
+		q, err := Open(Options{Dir: dir})
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		for id := range acked {
+			if _, err := q.Get(id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("round %d: acked task %s came back", round, id)
+			}
+		}
+		for id := range enqueued {
+			task, err := q.Get(id)
+			switch {
+			case acked[id]:
+			case leased[id]:
+				// its ack may have reached the disk without being printed;
+				// if not, the interrupted attempt counts
+				if err == nil && (task.State != Ready || task.Attempts != 1) {
+					t.Errorf("round %d: leased task %+v", round, task)
+				}
+			case err != nil:
+				t.Errorf("round %d: enqueued task %s lost: %v", round, id, err)
+			case task.State != Ready || task.Attempts > 1:
+				// a lease can reach the disk just before the kill, unprinted
+				t.Errorf("round %d: %+v", round, task)
+			}
+		}
+		if st := q.Stats(); st.Leased != 0 || st.Dead != 0 {
+			t.Errorf("round %d: %+v", round, st)
+		}
+		q.Close()
+	}
+}
@@ -0,0 +1,22 @@
+package taskqueue
+
+// record is one change to the queue, framed by internal/wal. put carries a
+// whole task; the others name it by ID.
+type record struct {
+	Op    string `json:"op"`
+	Task  *Task  `json:"task,omitempty"`
+	ID    string `json:"id,omitempty"`
+	Token uint64 `json:"token,omitempty"` // lease
+	At    int64  `json:"at,omitempty"`    // unix nanoseconds: lease expiry, retry time
+	Err   string `json:"err,omitempty"`
+}
+
+const (
+	opPut     = "put"     // enqueue, or a task's whole state after compaction
+	opLease   = "lease"   // counts an attempt
+	opAck     = "ack"     // done, forget it
+	opNack    = "nack"    // retry at At
+	opDead    = "dead"    // moved to the dead letter queue
+	opRequeue = "requeue" // back from the dead letter queue
+	opDelete  = "delete"  // purged from the dead letter queue
+)
@@ -0,0 +1,151 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/workpool"
+)
+
+const (
+	// MaxBulkItems caps the number of items a single bulk request may touch
+	MaxBulkItems = 1000
+	// BulkConcurrency is how many repository calls a bulk request runs at once
+	BulkConcurrency = 8
+)
+
+// BulkItemResult is the outcome for one item of a bulk request
+type BulkItemResult struct {
+	Index  int    `json:"index"`
+	Id     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Todo   *Todo  `json:"todo,omitempty"`
+}
+
+// BulkResponse is returned by every bulk endpoint. The status is 200 when all
+// items succeeded and 207 Multi-Status otherwise.
+type BulkResponse struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// bulkCreate handles POST /todos/bulk with a JSON array of todos
+func (h *Handler) bulkCreate(w http.ResponseWriter, r *http.Request) {
+	var todos []Todo
+	if !decode(w, r, &todos) || !checkBulkSize(w, len(todos)) {
+		return
+	}
+	h.runBulk(r.Context(), w, len(todos), func(ctx context.Context, i int) (int, Todo, error) {
+		if err := todos[i].Validate(); err != nil {
+			return 0, Todo{}, err
+		}
+		t, err := h.repo.Create(ctx, todos[i])
+		return t.Id, t, err
+	}, http.StatusCreated)
+}
+
+// bulkPatch handles PATCH /todos?userId=23 and applies the body to every match
+func (h *Handler) bulkPatch(w http.ResponseWriter, r *http.Request) {
+	f, ok := queryFilter(w, r)
+	if !ok {
+		return
+	}
+	if f.UserID == nil && f.Completed == nil {
+		writeError(w, http.StatusBadRequest, "bulk PATCH needs a userId or completed filter")
+		return
+	}
+	var p todoPatch
+	if !decode(w, r, &p) {
+		return
+	}
+	matches, err := h.repo.List(r.Context(), f)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	if !checkBulkSize(w, len(matches)) {
+		return
+	}
+	h.runBulk(r.Context(), w, len(matches), func(ctx context.Context, i int) (int, Todo, error) {
+		t, err := h.applyPatch(ctx, matches[i].Id, matches[i].Version, p)
+		return matches[i].Id, t, err
+	}, http.StatusOK)
+}
+
+// bulkDelete handles DELETE /todos?id=1&id=2
+func (h *Handler) bulkDelete(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query()["id"]
+	if len(raw) == 0 {
+		writeError(w, http.StatusBadRequest, "bulk DELETE needs at least one id parameter")
+		return
+	}
+	if !checkBulkSize(w, len(raw)) {
+		return
+	}
+	ids := make([]int, len(raw))
+	for i, v := range raw {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("id %q is not a number", v))
+			return
+		}
+		ids[i] = id
+	}
+	h.runBulk(r.Context(), w, len(ids), func(ctx context.Context, i int) (int, Todo, error) {
+		return ids[i], Todo{}, h.repo.Delete(ctx, ids[i], 0)
+	}, http.StatusNoContent)
+}
+
+// runBulk runs op for every index with bounded concurrency and writes a BulkResponse.
+// okStatus is the per-item status reported on success.
+func (h *Handler) runBulk(ctx context.Context, w http.ResponseWriter, n int, op func(ctx context.Context, i int) (int, Todo, error), okStatus int) {
+	resp := BulkResponse{Results: make([]BulkItemResult, n)}
+	workpool.Run(ctx, n, BulkConcurrency, func(ctx context.Context, i int) {
+		res := BulkItemResult{Index: i}
+		var t Todo
+		var err error
+		if err = ctx.Err(); err == nil {
+			res.Id, t, err = op(ctx, i)
+		}
+		if err != nil {
+			res.Status = statusFor(err)
+			res.Error = err.Error()
+			if res.Status == http.StatusInternalServerError {
+				log.Println("todo:", err)
+				res.Error = "internal error"
+			}
+		} else {
+			res.Status = okStatus
+			if t.Id != 0 {
+				res.Todo = &t
+			}
+		}
+		resp.Results[i] = res
+	})
+
+	for _, res := range resp.Results {
+		if res.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, status, resp)
+}
+
+func checkBulkSize(w http.ResponseWriter, n int) bool {
+	if n > MaxBulkItems {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d items per bulk request", MaxBulkItems))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,299 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Handler serves the Todo REST API on /todos
+type Handler struct {
+	repo Repository
+	mux  *http.ServeMux
+}
+
+// NewHandler returns a Handler backed by repo
+func NewHandler(repo Repository) *Handler {
+	h := &Handler{repo: repo, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /todos", h.list)
+	h.mux.HandleFunc("POST /todos", h.create)
+	h.mux.HandleFunc("POST /todos/bulk", h.bulkCreate)
+	h.mux.HandleFunc("PATCH /todos", h.bulkPatch)
+	h.mux.HandleFunc("DELETE /todos", h.bulkDelete)
+	h.mux.HandleFunc("GET /todos/{id}", h.get)
+	h.mux.HandleFunc("PUT /todos/{id}", h.update)
+	h.mux.HandleFunc("PATCH /todos/{id}", h.patch)
+	h.mux.HandleFunc("DELETE /todos/{id}", h.delete)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	f, ok := queryFilter(w, r)
+	if !ok {
+		return
+	}
+	todos, err := h.repo.List(r.Context(), f)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, todos)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	t, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	if r.Header.Get("If-None-Match") == etag(t) {
+		w.Header().Set("ETag", etag(t))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeTodo(w, http.StatusOK, t)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var t Todo
+	if !decode(w, r, &t) {
+		return
+	}
+	if err := t.Validate(); err != nil {
+		h.fail(w, err)
+		return
+	}
+	t, err := h.repo.Create(r.Context(), t)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/todos/%d", t.Id))
+	writeTodo(w, http.StatusCreated, t)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	var t Todo
+	if !decode(w, r, &t) {
+		return
+	}
+	if err := t.Validate(); err != nil {
+		h.fail(w, err)
+		return
+	}
+	t.Id = id
+	t, err := h.repo.Update(r.Context(), t, version)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	writeTodo(w, http.StatusOK, t)
+}
+
+// todoPatch holds the fields a PATCH may change; nil means unchanged
+type todoPatch struct {
+	UserID    *int    `json:"userId"`
+	Title     *string `json:"title"`
+	Completed *bool   `json:"completed"`
+}
+
+func (p todoPatch) apply(t Todo) Todo {
+	if p.UserID != nil {
+		t.UserID = *p.UserID
+	}
+	if p.Title != nil {
+		t.Title = *p.Title
+	}
+	if p.Completed != nil {
+		t.Completed = *p.Completed
+	}
+	return t
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	var p todoPatch
+	if !decode(w, r, &p) {
+		return
+	}
+
+	t, err := h.applyPatch(r.Context(), id, version, p)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	writeTodo(w, http.StatusOK, t)
+}
+
+// applyPatch reads the todo, applies p and writes it back if the version still matches
+func (h *Handler) applyPatch(ctx context.Context, id, version int, p todoPatch) (Todo, error) {
+	cur, err := h.repo.Get(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+	if version == 0 {
+		// still guard against a write landing between Get and Update
+		version = cur.Version
+	}
+	t := p.apply(cur)
+	if err := t.Validate(); err != nil {
+		return Todo{}, err
+	}
+	return h.repo.Update(ctx, t, version)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	if err := h.repo.Delete(r.Context(), id, version); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fail maps repository and validation errors to HTTP statuses
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	var verr *ValidationError
+	status := statusFor(err)
+	switch {
+	case errors.As(err, &verr):
+		writeJSON(w, status, map[string]any{"error": "validation failed", "fields": verr.Fields})
+	case status == http.StatusInternalServerError:
+		log.Println("todo:", err)
+		writeError(w, status, "internal error")
+	default:
+		writeError(w, status, err.Error())
+	}
+}
+
+func statusFor(err error) int {
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, context.Canceled):
+		// the client went away; nobody reads this, but it is not our fault
+		return statusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// statusClientClosedRequest is nginx's status for a request the client
+// cancelled before the response was ready
+const statusClientClosedRequest = 499
+
+// queryFilter reads the userId and completed query parameters
+func queryFilter(w http.ResponseWriter, r *http.Request) (Filter, bool) {
+	var f Filter
+	q := r.URL.Query()
+	if v := q.Get("userId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "userId must be a number")
+			return f, false
+		}
+		f.UserID = &id
+	}
+	if v := q.Get("completed"); v != "" {
+		done, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "completed must be true or false")
+			return f, false
+		}
+		f.Completed = &done
+	}
+	return f, true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusNotFound, ErrNotFound.Error())
+		return 0, false
+	}
+	return id, true
+}
+
+// ifMatch returns the version from an If-Match header, or zero when there is none
+func ifMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.Header.Get("If-Match")
+	if v == "" || v == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(v, "W/"), `"`))
+	if err != nil {
+		writeError(w, http.StatusPreconditionFailed, "If-Match must be an ETag returned by this API")
+		return 0, false
+	}
+	return version, true
+}
+
+func etag(t Todo) string {
+	return strconv.Quote(strconv.Itoa(t.Version))
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeTodo(w http.ResponseWriter, status int, t Todo) {
+	w.Header().Set("ETag", etag(t))
+	writeJSON(w, status, t)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,172 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func repositories(t *testing.T) map[string]Repository {
+	sqlite, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]Repository{"memory": NewMemoryRepository(), "sqlite": sqlite}
+}
+
+func do(t *testing.T, h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCRUDWithETags(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			h := NewHandler(repo)
+
+			rr := do(t, h, "POST", "/todos", `{"userId":23,"title":"Prince Kumar"}`)
+			if rr.Code != http.StatusCreated || rr.Header().Get("ETag") != `"1"` {
+				t.Fatalf("create: %d %s etag=%s", rr.Code, rr.Body, rr.Header().Get("ETag"))
+			}
+			var created Todo
+			json.Unmarshal(rr.Body.Bytes(), &created)
+			if created.Id != 1 || created.Title != "Prince Kumar" {
+				t.Fatalf("create body: %+v", created)
+			}
+
+			rr = do(t, h, "PATCH", "/todos/1", `{"completed":true}`, "If-Match", `"1"`)
+			if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` || !strings.Contains(rr.Body.String(), `"completed":true`) {
+				t.Fatalf("patch: %d %s", rr.Code, rr.Body)
+			}
+
+			// a stale ETag must not overwrite the newer version
+			rr = do(t, h, "PUT", "/todos/1", `{"userId":23,"title":"stale"}`, "If-Match", `"1"`)
+			if rr.Code != http.StatusPreconditionFailed {
+				t.Fatalf("stale put: expected 412, got %d %s", rr.Code, rr.Body)
+			}
+
+			rr = do(t, h, "GET", "/todos/1", "", "If-None-Match", `"2"`)
+			if rr.Code != http.StatusNotModified {
+				t.Fatalf("conditional get: expected 304, got %d", rr.Code)
+			}
+
+			rr = do(t, h, "GET", "/todos?userId=23&completed=true", "")
+			if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Prince Kumar") {
+				t.Fatalf("list: %d %s", rr.Code, rr.Body)
+			}
+
+			rr = do(t, h, "DELETE", "/todos/1", "", "If-Match", `"2"`)
+			if rr.Code != http.StatusNoContent {
+				t.Fatalf("delete: %d %s", rr.Code, rr.Body)
+			}
+			if rr = do(t, h, "GET", "/todos/1", ""); rr.Code != http.StatusNotFound {
+				t.Fatalf("get after delete: expected 404, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestValidation(t *testing.T) {
+	h := NewHandler(NewMemoryRepository())
+
+	rr := do(t, h, "POST", "/todos", `{"userId":0,"title":"  "}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d %s", rr.Code, rr.Body)
+	}
+	var body struct{ Fields map[string]string }
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if body.Fields["userId"] == "" || body.Fields["title"] == "" {
+		t.Fatalf("expected userId and title errors, got %v", body.Fields)
+	}
+
+	if rr := do(t, h, "POST", "/todos", `{"title":"x","unknown":1}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown field: expected 400, got %d", rr.Code)
+	}
+
+	err := Todo{UserID: 1, Title: strings.Repeat("x", MaxTitleLength+1)}.Validate()
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprint(MaxTitleLength)) {
+		t.Fatalf("long title: %v", err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	ctx := context.Background()
+
+	first, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Create(ctx, Todo{UserID: 1, Title: "survives restart"}); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	second, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer second.Close()
+	if got, err := second.Get(ctx, 1); err != nil || got.Title != "survives restart" {
+		t.Fatalf("after reopen: %+v, %v", got, err)
+	}
+}
+
+func TestBulkOperations(t *testing.T) {
+	h := NewHandler(NewMemoryRepository())
+
+	rr := do(t, h, "POST", "/todos/bulk", `[{"userId":23,"title":"a"},{"userId":23,"title":""},{"userId":23,"title":"c"},{"userId":7,"title":"d"}]`)
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("bulk create with one invalid item: expected 207, got %d %s", rr.Code, rr.Body)
+	}
+	var resp BulkResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Succeeded != 3 || resp.Failed != 1 || resp.Results[1].Status != http.StatusUnprocessableEntity {
+		t.Fatalf("bulk create report: %+v", resp)
+	}
+
+	rr = do(t, h, "PATCH", "/todos?userId=23", `{"completed":true}`)
+	resp = BulkResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || resp.Succeeded != 2 {
+		t.Fatalf("bulk patch: %d %s", rr.Code, rr.Body)
+	}
+	if rr = do(t, h, "GET", "/todos?completed=true", ""); strings.Count(rr.Body.String(), `"userId":23`) != 2 {
+		t.Fatalf("expected both todos of user 23 completed: %s", rr.Body)
+	}
+
+	if rr = do(t, h, "PATCH", "/todos", `{"completed":true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unfiltered bulk patch: expected 400, got %d", rr.Code)
+	}
+
+	// a client that gave up is not a server fault
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/todos/bulk", strings.NewReader(`[{"userId":23,"title":"e"}]`)).WithContext(ctx)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	resp = BulkResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Failed != 1 || resp.Results[0].Status != statusClientClosedRequest || resp.Results[0].Error != context.Canceled.Error() {
+		t.Fatalf("cancelled bulk create: %d %s", rr.Code, rr.Body)
+	}
+
+	rr = do(t, h, "DELETE", "/todos?id=1&id=2&id=99", "")
+	resp = BulkResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusMultiStatus || resp.Succeeded != 2 || resp.Results[2].Status != http.StatusNotFound {
+		t.Fatalf("bulk delete: %d %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,86 @@
+package todo
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository keeps todos in a map; data is lost on restart
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	todos  map[int]Todo
+	lastID int
+}
+
+// NewMemoryRepository returns an empty MemoryRepository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{todos: map[int]Todo{}}
+}
+
+func (m *MemoryRepository) List(ctx context.Context, f Filter) ([]Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := []Todo{}
+	for _, t := range m.todos {
+		if f.match(t) {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out, nil
+}
+
+func (m *MemoryRepository) Get(ctx context.Context, id int) (Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.todos[id]
+	if !ok {
+		return Todo{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *MemoryRepository) Create(ctx context.Context, t Todo) (Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	t.Id = m.lastID
+	t.Version = 1
+	m.todos[t.Id] = t
+	return t, nil
+}
+
+func (m *MemoryRepository) Update(ctx context.Context, t Todo, expectedVersion int) (Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.todos[t.Id]
+	if !ok {
+		return Todo{}, ErrNotFound
+	}
+	if expectedVersion != 0 && cur.Version != expectedVersion {
+		return Todo{}, ErrVersionConflict
+	}
+	t.Version = cur.Version + 1
+	m.todos[t.Id] = t
+	return t, nil
+}
+
+func (m *MemoryRepository) Delete(ctx context.Context, id int, expectedVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.todos[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if expectedVersion != 0 && cur.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+	delete(m.todos, id)
+	return nil
+}
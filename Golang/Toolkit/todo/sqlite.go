@@ -0,0 +1,179 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// migrations are applied in order and recorded in schema_migrations. Only ever append.
+var migrations = []string{
+	`CREATE TABLE todos (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id   INTEGER NOT NULL,
+		title     TEXT    NOT NULL,
+		completed INTEGER NOT NULL DEFAULT 0,
+		version   INTEGER NOT NULL DEFAULT 1
+	)`,
+	`CREATE INDEX todos_user_id ON todos (user_id)`,
+}
+
+// SQLiteRepository stores todos in a SQLite database file
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (or creates) the database at path and runs pending migrations
+func OpenSQLite(ctx context.Context, path string) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+// Migrate applies every migration not yet recorded in schema_migrations
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLiteRepository) Close() error { return s.db.Close() }
+
+func (s *SQLiteRepository) List(ctx context.Context, f Filter) ([]Todo, error) {
+	query := `SELECT id, user_id, title, completed, version FROM todos WHERE 1=1`
+	var args []any
+	if f.UserID != nil {
+		query += ` AND user_id = ?`
+		args = append(args, *f.UserID)
+	}
+	if f.Completed != nil {
+		query += ` AND completed = ?`
+		args = append(args, *f.Completed)
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Todo{}
+	for rows.Next() {
+		var t Todo
+		if err := rows.Scan(&t.Id, &t.UserID, &t.Title, &t.Completed, &t.Version); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteRepository) Get(ctx context.Context, id int) (Todo, error) {
+	var t Todo
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, title, completed, version FROM todos WHERE id = ?`, id,
+	).Scan(&t.Id, &t.UserID, &t.Title, &t.Completed, &t.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrNotFound
+	}
+	return t, err
+}
+
+func (s *SQLiteRepository) Create(ctx context.Context, t Todo) (Todo, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO todos (user_id, title, completed, version) VALUES (?, ?, ?, 1)`,
+		t.UserID, t.Title, t.Completed)
+	if err != nil {
+		return Todo{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Todo{}, err
+	}
+	t.Id = int(id)
+	t.Version = 1
+	return t, nil
+}
+
+func (s *SQLiteRepository) Update(ctx context.Context, t Todo, expectedVersion int) (Todo, error) {
+	query := `UPDATE todos SET user_id = ?, title = ?, completed = ?, version = version + 1 WHERE id = ?`
+	args := []any{t.UserID, t.Title, t.Completed, t.Id}
+	if expectedVersion != 0 {
+		query += ` AND version = ?`
+		args = append(args, expectedVersion)
+	}
+	query += ` RETURNING version`
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&t.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, s.missOrConflict(ctx, t.Id)
+	}
+	return t, err
+}
+
+func (s *SQLiteRepository) Delete(ctx context.Context, id int, expectedVersion int) error {
+	query := `DELETE FROM todos WHERE id = ?`
+	args := []any{id}
+	if expectedVersion != 0 {
+		query += ` AND version = ?`
+		args = append(args, expectedVersion)
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return s.missOrConflict(ctx, id)
+	}
+	return nil
+}
+
+// missOrConflict tells apart a missing row from a version mismatch after a write touched no rows
+func (s *SQLiteRepository) missOrConflict(ctx context.Context, id int) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
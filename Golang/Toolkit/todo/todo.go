@@ -0,0 +1,79 @@
+// Package todo is a CRUD service for the same Todo shape served by JSONPlaceholder.
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrNotFound        = errors.New("todo not found")
+	ErrVersionConflict = errors.New("todo was modified by another request")
+)
+
+// MaxTitleLength is the longest title accepted, in characters
+const MaxTitleLength = 200
+
+// Todo is the stored item. Version is bumped on every write and served as the ETag.
+type Todo struct {
+	UserID    int    `json:"userId"`
+	Id        int    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Version   int    `json:"-"`
+}
+
+// Filter narrows List. Nil fields match everything.
+type Filter struct {
+	UserID    *int
+	Completed *bool
+}
+
+// Repository stores todos. A expectedVersion of zero skips the version check.
+type Repository interface {
+	List(ctx context.Context, f Filter) ([]Todo, error)
+	Get(ctx context.Context, id int) (Todo, error)
+	Create(ctx context.Context, t Todo) (Todo, error)
+	Update(ctx context.Context, t Todo, expectedVersion int) (Todo, error)
+	Delete(ctx context.Context, id int, expectedVersion int) error
+}
+
+// ValidationError lists every invalid field
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for field, msg := range e.Fields {
+		msgs = append(msgs, field+" "+msg)
+	}
+	sort.Strings(msgs)
+	return "invalid todo: " + strings.Join(msgs, ", ")
+}
+
+// Validate checks the user supplied fields
+func (t Todo) Validate() error {
+	fields := map[string]string{}
+	if t.UserID <= 0 {
+		fields["userId"] = "must be a positive number"
+	}
+	if strings.TrimSpace(t.Title) == "" {
+		fields["title"] = "is required"
+	} else if utf8.RuneCountInString(t.Title) > MaxTitleLength {
+		fields["title"] = fmt.Sprintf("must be at most %d characters", MaxTitleLength)
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func (f Filter) match(t Todo) bool {
+	return (f.UserID == nil || *f.UserID == t.UserID) &&
+		(f.Completed == nil || *f.Completed == t.Completed)
+}
@@ -0,0 +1,190 @@
+// Package urlbuilder builds request URLs from a base URL, an escaped path
+// template and typed query parameters, instead of editing url.URL fields by hand.
+package urlbuilder
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Builder accumulates a URL. Errors are kept until Build so calls can be chained.
+type Builder struct {
+	base   *url.URL
+	paths  []string
+	params map[string]string
+	query  url.Values
+	err    error
+}
+
+// New starts a Builder from an absolute base URL; its path and query are kept
+func New(base string) *Builder {
+	u, err := url.Parse(base)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = fmt.Errorf("base URL %q must be absolute", base)
+	}
+	b := &Builder{params: map[string]string{}, query: url.Values{}, err: err}
+	if err == nil {
+		b.base = u
+		for k, v := range u.Query() {
+			b.query[k] = v
+		}
+	}
+	return b
+}
+
+// Path appends a path template such as "/users/{user}/gists" to the base path.
+// Placeholders are filled by Param.
+func (b *Builder) Path(template string) *Builder {
+	b.paths = append(b.paths, template)
+	return b
+}
+
+// Param sets a path placeholder. The value is escaped as a single segment,
+// so "a/b" becomes "a%2Fb" rather than two segments.
+func (b *Builder) Param(name string, value any) *Builder {
+	s, err := format(value)
+	if err != nil {
+		b.setErr(fmt.Errorf("path param %s: %w", name, err))
+	}
+	b.params[name] = s
+	return b
+}
+
+// Query adds one or more values for key; repeated calls add repeated keys
+func (b *Builder) Query(key string, values ...any) *Builder {
+	for _, v := range values {
+		s, err := format(v)
+		if err != nil {
+			b.setErr(fmt.Errorf("query %s: %w", key, err))
+			continue
+		}
+		b.query.Add(key, s)
+	}
+	return b
+}
+
+// SetQuery replaces every value of key
+func (b *Builder) SetQuery(key string, values ...any) *Builder {
+	b.query.Del(key)
+	return b.Query(key, values...)
+}
+
+// QueryStruct adds the fields of a struct tagged with `url:"name,omitempty"`
+func (b *Builder) QueryStruct(v any) *Builder {
+	values, err := Encode(v)
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	for k, vs := range values {
+		b.query[k] = append(b.query[k], vs...)
+	}
+	return b
+}
+
+// Build returns the URL, or the first error seen while building
+func (b *Builder) Build() (*url.URL, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	rawPath := strings.TrimRight(b.base.EscapedPath(), "/")
+	used := map[string]bool{}
+	for _, tmpl := range b.paths {
+		seg, err := expand(tmpl, b.params, used)
+		if err != nil {
+			return nil, err
+		}
+		if seg = strings.Trim(seg, "/"); seg != "" {
+			rawPath += "/" + seg
+		}
+	}
+	for name := range b.params {
+		if !used[name] {
+			return nil, fmt.Errorf("path param %s is not in any path template", name)
+		}
+	}
+
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, err
+	}
+	u := *b.base
+	u.Path = path
+	u.RawPath = rawPath
+	u.RawQuery = b.query.Encode()
+	return &u, nil
+}
+
+// String is Build followed by String, for printing and logging. On a build
+// error it returns "!urlbuilder(<error>)", which never parses as a URL with
+// a host; code that sends requests should call Build and check the error.
+func (b *Builder) String() string {
+	u, err := b.Build()
+	if err != nil {
+		return "!urlbuilder(" + err.Error() + ")"
+	}
+	return u.String()
+}
+
+// Resolve merges ref against base as a browser would (RFC 3986), e.g. for
+// relative Link headers. An absolute ref replaces base.
+func Resolve(base, ref string) (*url.URL, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	return b.ResolveReference(r), nil
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// expand replaces {name} placeholders with escaped params and records which were used
+func expand(tmpl string, params map[string]string, used map[string]bool) (string, error) {
+	var out strings.Builder
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			if strings.IndexByte(tmpl, '}') >= 0 {
+				return "", fmt.Errorf("unbalanced } in path template")
+			}
+			out.WriteString(escapeLiteral(tmpl))
+			return out.String(), nil
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			return "", errors.New("unclosed { in path template")
+		}
+		name := tmpl[open+1 : open+end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("path param %s is not set", name)
+		}
+		if value == "" {
+			return "", fmt.Errorf("path param %s is empty", name)
+		}
+		used[name] = true
+		out.WriteString(escapeLiteral(tmpl[:open]))
+		out.WriteString(url.PathEscape(value))
+		tmpl = tmpl[open+end+1:]
+	}
+}
+
+// escapeLiteral escapes each segment of the fixed parts of a template, keeping the slashes
+func escapeLiteral(s string) string {
+	parts := strings.Split(s, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,103 @@
+package urlbuilder
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathTemplateEscapesSegments(t *testing.T) {
+	u, err := New("https://api.github.com").
+		Path("/users/{user}/gists").
+		Param("user", "a/b c?").
+		Query("page", 2).
+		Query("per_page", 5).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://api.github.com/users/a%2Fb%20c%3F/gists?page=2&per_page=5"
+	if u.String() != want {
+		t.Fatalf("got  %s\nwant %s", u, want)
+	}
+	if u.Path != "/users/a/b c?/gists" {
+		t.Fatalf("decoded path = %q", u.Path)
+	}
+}
+
+func TestBasePathAndQueryAreKept(t *testing.T) {
+	got := New("https://example.com/api/v1/?key1=value1").
+		Path("todos").Path("{id}").Param("id", 7).
+		Query("tag", "a", "b").
+		Query("done", true).
+		Query("since", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)).
+		String()
+	want := "https://example.com/api/v1/todos/7?done=true&key1=value1&since=2025-01-02T03%3A04%3A05Z&tag=a&tag=b"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := map[string]*Builder{
+		"relative base":  New("/no/host"),
+		"missing param":  New("https://x").Path("/users/{user}"),
+		"unused param":   New("https://x").Path("/users").Param("user", "a"),
+		"unclosed brace": New("https://x").Path("/users/{user").Param("user", "a"),
+		"bad value":      New("https://x").Query("k", struct{}{}),
+	}
+	for name, b := range cases {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		// printing a broken builder must not panic
+		if s := fmt.Sprint(b); !strings.HasPrefix(s, "!urlbuilder(") {
+			t.Errorf("%s: printed as %q", name, s)
+		}
+	}
+}
+
+func TestQueryStruct(t *testing.T) {
+	type Paging struct {
+		Page    int `url:"page"`
+		PerPage int `url:"per_page,omitempty"`
+	}
+	type filter struct {
+		Paging
+		UserID    *int     `url:"userId"`
+		Completed *bool    `url:"completed,omitempty"`
+		Labels    []string `url:"label"`
+		Secret    string   `url:"-"`
+		Sort      string
+	}
+	user := 1
+	got := New("https://x/todos").QueryStruct(filter{
+		Paging: Paging{Page: 3},
+		UserID: &user,
+		Labels: []string{"x", "y"},
+		Secret: "hidden",
+		Sort:   "id",
+	}).String()
+	want := "https://x/todos?Sort=id&label=x&label=y&page=3&userId=1"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	u, err := Resolve("https://api.github.com/users/octocat/gists?page=1", "/user/583231/gists?page=2")
+	if err != nil || u.String() != "https://api.github.com/user/583231/gists?page=2" {
+		t.Fatalf("got %v, %v", u, err)
+	}
+}
+
+// Same change as API/API.ChangeURL.go, without assigning Path and RawQuery by hand
+func ExampleBuilder() {
+	u := New("https://jsonplaceholder.typicode.com").
+		Path("/users/{user}/gists").
+		Param("user", "iamprince").
+		Query("per_page", 5)
+	fmt.Println(u)
+	// Output: https://jsonplaceholder.typicode.com/users/iamprince/gists?per_page=5
+}
@@ -0,0 +1,125 @@
+package urlbuilder
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is used for time.Time values in paths and queries
+const TimeFormat = time.RFC3339
+
+// Encode turns a struct into query values. Fields use the `url` tag:
+//
+//	Page    int       `url:"page"`
+//	Since   time.Time `url:"since,omitempty"`
+//	Labels  []string  `url:"label"`       // repeated key
+//	Secret  string    `url:"-"`           // skipped
+//
+// Untagged fields use their Go name. Embedded structs are flattened.
+func Encode(v any) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query struct: expected a struct, got %s", rv.Kind())
+	}
+	values := url.Values{}
+	return values, encodeStruct(rv, values)
+}
+
+func encodeStruct(rv reflect.Value, values url.Values) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := opts == "omitempty"
+		fv := rv.Field(i)
+
+		if field.Anonymous && tag == "" && fv.Kind() == reflect.Struct {
+			if err := encodeStruct(fv, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if (fv.Kind() == reflect.Pointer && fv.IsNil()) || (omitEmpty && fv.IsZero()) {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				s, err := format(fv.Index(j).Interface())
+				if err != nil {
+					return fmt.Errorf("query %s: %w", name, err)
+				}
+				values.Add(name, s)
+			}
+			continue
+		}
+		s, err := format(fv.Interface())
+		if err != nil {
+			return fmt.Errorf("query %s: %w", name, err)
+		}
+		values.Add(name, s)
+	}
+	return nil
+}
+
+// format renders a scalar for a path segment or query value
+func format(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(TimeFormat), nil
+	case time.Duration:
+		return v.String(), nil
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
@@ -0,0 +1,94 @@
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// Matcher reports whether a live request (with its body already read) matches a recorded one
+type Matcher func(req *http.Request, body []byte, rec Request) bool
+
+// DefaultMatcher compares method, URL without query, and the query
+var DefaultMatcher = All(MatchMethod, MatchURL, MatchQuery)
+
+// All matches when every matcher does
+func All(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, rec Request) bool {
+		for _, m := range matchers {
+			if !m(req, body, rec) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod compares the HTTP method
+func MatchMethod(req *http.Request, _ []byte, rec Request) bool {
+	return req.Method == rec.Method
+}
+
+// MatchURL compares scheme, host and path, ignoring the query
+func MatchURL(req *http.Request, _ []byte, rec Request) bool {
+	u, err := url.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+	return req.URL.Scheme == u.Scheme && req.URL.Host == u.Host && req.URL.EscapedPath() == u.EscapedPath()
+}
+
+// MatchQuery compares query parameters regardless of their order
+func MatchQuery(req *http.Request, _ []byte, rec Request) bool {
+	u, err := url.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+	return queryEqual(req.URL.Query(), u.Query())
+}
+
+// MatchBody compares bodies; JSON bodies are compared by value so key order and spacing don't matter
+func MatchBody(_ *http.Request, body []byte, rec Request) bool {
+	var a, b any
+	if json.Unmarshal(body, &a) == nil && json.Unmarshal(rec.Body, &b) == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(body, rec.Body)
+}
+
+// MatchQueryIgnoring is MatchQuery with some parameters left out, e.g. a timestamp or nonce
+func MatchQueryIgnoring(params ...string) Matcher {
+	return func(req *http.Request, _ []byte, rec Request) bool {
+		u, err := url.Parse(rec.URL)
+		if err != nil {
+			return false
+		}
+		live, recorded := req.URL.Query(), u.Query()
+		for _, p := range params {
+			live.Del(p)
+			recorded.Del(p)
+		}
+		return queryEqual(live, recorded)
+	}
+}
+
+// queryEqual compares query values in order; a redacted recorded value matches anything
+func queryEqual(live, recorded url.Values) bool {
+	if len(live) != len(recorded) {
+		return false
+	}
+	for k, want := range recorded {
+		got := live[k]
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if want[i] != Redacted && want[i] != got[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+// Package vcr records HTTP interactions to cassette files and replays them in
+// tests, so examples that call live APIs can be tested offline and deterministically.
+//
+// Record once against the real service, then commit the cassette:
+//
+//	VCR_MODE=record go test ./...
+package vcr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Redacted replaces secret header and query values in cassettes
+const Redacted = "REDACTED"
+
+// Mode selects between recording and replaying
+type Mode int
+
+const (
+	// ModeReplay serves only from the cassette and fails on unmatched requests
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the real transport and rewrites the cassette on Stop
+	ModeRecord
+)
+
+// DefaultRedactHeaders are always redacted
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "GITHUB_TOKEN"}
+
+// Recorder is an http.RoundTripper that records to or replays from a cassette
+type Recorder struct {
+	path         string
+	mode         Mode
+	real         http.RoundTripper
+	matcher      Matcher
+	redactHeader map[string]bool
+	redactQuery  map[string]bool
+	onUnmatched  func(error)
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// Option configures a Recorder
+type Option func(*Recorder)
+
+// WithMode overrides the mode taken from the VCR_MODE environment variable
+func WithMode(m Mode) Option { return func(r *Recorder) { r.mode = m } }
+
+// WithMatcher replaces DefaultMatcher, e.g. All(DefaultMatcher, MatchBody)
+func WithMatcher(m Matcher) Option { return func(r *Recorder) { r.matcher = m } }
+
+// WithTransport sets the real transport used while recording
+func WithTransport(rt http.RoundTripper) Option { return func(r *Recorder) { r.real = rt } }
+
+// WithRedactedHeaders adds header names whose values are never written to the cassette
+func WithRedactedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.redactHeader[http.CanonicalHeaderKey(n)] = true
+		}
+	}
+}
+
+// WithRedactedQuery adds query parameters whose values are never written, such as access_token
+func WithRedactedQuery(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.redactQuery[n] = true
+		}
+	}
+}
+
+// New returns a Recorder for the cassette at path. The mode comes from
+// VCR_MODE ("record" or "replay", default replay) unless WithMode is given.
+// Replaying a missing cassette is an error.
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:         path,
+		real:         http.DefaultTransport,
+		matcher:      DefaultMatcher,
+		redactHeader: map[string]bool{},
+		redactQuery:  map[string]bool{},
+		cassette:     &Cassette{},
+	}
+	if os.Getenv("VCR_MODE") == "record" {
+		r.mode = ModeRecord
+	}
+	WithRedactedHeaders(DefaultRedactHeaders...)(r)
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode == ModeReplay {
+		if !exists(path) {
+			return nil, fmt.Errorf("vcr: cassette %s does not exist; record it with VCR_MODE=record", path)
+		}
+		c, err := Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("vcr: load %s: %w", path, err)
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// TB is the part of testing.TB that NewForTest needs. Taking it instead of
+// testing.TB keeps the testing package out of binaries that import vcr.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+	Cleanup(func())
+}
+
+// NewForTest is New for tests: setup errors and unmatched requests fail t,
+// and the cassette is saved when the test ends in record mode.
+func NewForTest(t TB, path string, opts ...Option) *Recorder {
+	t.Helper()
+	r, err := New(path, opts...)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	r.onUnmatched = func(err error) { t.Errorf("%v", err) }
+	t.Cleanup(func() {
+		if err := r.Stop(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+	return r
+}
+
+// Client returns an http.Client using the recorder as its transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop saves the cassette when recording; it is a no-op when replaying
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+// Unused returns the recorded requests that were never replayed
+func (r *Recorder) Unused() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Request
+	for i, used := range r.used {
+		if !used {
+			out = append(out, r.cassette.Interactions[i].Request)
+		}
+	}
+	return out
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+// replay returns the first unused interaction that matches
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !r.matcher(req, body, in.Request) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	err := fmt.Errorf("vcr: no unused interaction in %s matches %s %s", r.path, req.Method, req.URL.Redacted())
+	if r.onUnmatched != nil {
+		r.onUnmatched(err)
+	}
+	return nil, err
+}
+
+// record sends the request for real and appends the redacted interaction
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		out.Body = http.NoBody
+	}
+	res, err := r.real.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	in := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    r.redactURL(req.URL),
+			Header: r.redactHeaders(req.Header),
+			Body:   body,
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     r.redactHeaders(res.Header),
+			Body:       resBody,
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return res, nil
+}
+
+func (r *Recorder) redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if r.redactHeader[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{Redacted}
+		}
+	}
+	return out
+}
+
+func (r *Recorder) redactURL(u *url.URL) string {
+	out := *u
+	out.User = nil
+	q := out.Query()
+	for k := range q {
+		if r.redactQuery[k] {
+			q[k] = []string{Redacted}
+		}
+	}
+	if len(q) > 0 {
+		out.RawQuery = q.Encode()
+	}
+	return out.String()
+}
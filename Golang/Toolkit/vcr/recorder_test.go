@@ -0,0 +1,100 @@
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + string(body)))
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	rec, err := New(path, WithMode(ModeRecord), WithRedactedQuery("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/echo?q=1&token=abc", strings.NewReader(`{"a":1,"b":2}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	if got := send(t, rec.Client(), req); got != `POST 1 {"a":1,"b":2}` {
+		t.Fatalf("recording returned %q", got)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "abc") {
+		t.Fatalf("cassette leaks a secret:\n%s", data)
+	}
+
+	replay, err := New(path, WithMode(ModeReplay), WithMatcher(All(DefaultMatcher, MatchBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// same JSON in a different key order, and a different token value, still match
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/echo?token=xyz&q=1", strings.NewReader(`{"b":2, "a":1}`))
+	if got := send(t, replay.Client(), req); got != `POST 1 {"a":1,"b":2}` {
+		t.Fatalf("replay returned %q", got)
+	}
+	if len(replay.Unused()) != 0 {
+		t.Fatalf("expected the interaction to be used")
+	}
+
+	// each interaction replays once, and an unmatched request is an error
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/echo?q=1&token=x", strings.NewReader(`{"a":1,"b":2}`))
+	if _, err := replay.Client().Do(req); err == nil || !strings.Contains(err.Error(), "no unused interaction") {
+		t.Fatalf("expected unmatched error, got %v", err)
+	}
+}
+
+func TestBinaryBodiesReplayExactly(t *testing.T) {
+	payload := []byte{0xff, 0xfe, 0x00, 'h', 'i', 0xc3, 0x28} // not UTF-8
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "binary.json")
+
+	rec, _ := New(path, WithMode(ModeRecord))
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/blob", nil)
+	send(t, rec.Client(), req)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := New(path, WithMode(ModeReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/blob", nil)
+	if got := send(t, replay.Client(), req); got != string(payload) {
+		t.Fatalf("replayed %q, want %q", got, payload)
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), WithMode(ModeReplay)); err == nil {
+		t.Fatal("expected an error for a missing cassette")
+	}
+}
+
+func send(t *testing.T, c *http.Client, req *http.Request) string {
+	t.Helper()
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return string(body)
+}
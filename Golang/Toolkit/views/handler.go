@@ -0,0 +1,108 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// View is a count as the API returns it
+type View struct {
+	Post      string `json:"post"`
+	Views     int64  `json:"views"`
+	Duplicate bool   `json:"duplicate,omitempty"` // the Idempotency-Key was already used
+}
+
+// Handler serves
+//
+//	POST /posts/{id}/views   add one view, or ?by=n; an Idempotency-Key header makes
+//	                         retries safe and X-Visitor-ID or a visitor cookie names the reader
+//	GET  /posts/{id}/views   one count
+//	GET  /posts/{id}/stats   total, unique and last minute, hour and day, see PostStats
+//	GET  /posts/views?id=a&id=b    several counts, every post when no id is given
+//	GET  /posts/unique?id=a&id=b   distinct visitors across the posts
+func (s *Store) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /posts/{id}/views", s.inc)
+	mux.HandleFunc("GET /posts/{id}/views", s.get)
+	mux.HandleFunc("GET /posts/{id}/stats", s.stats)
+	mux.HandleFunc("GET /posts/views", s.list)
+	mux.HandleFunc("GET /posts/unique", s.unique)
+	return mux
+}
+
+func visitor(r *http.Request) string {
+	if v := r.Header.Get("X-Visitor-ID"); v != "" {
+		return v
+	}
+	if c, err := r.Cookie("visitor"); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+func (s *Store) inc(w http.ResponseWriter, r *http.Request) {
+	by := int64(1)
+	if v := r.URL.Query().Get("by"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "by must be a positive integer"})
+			return
+		}
+		by = n
+	}
+	post := r.PathValue("id")
+	n, dup, err := s.Add(r.Context(), Increment{
+		Post:    post,
+		By:      by,
+		Key:     r.Header.Get("Idempotency-Key"),
+		Visitor: visitor(r),
+	})
+	switch {
+	case errors.Is(err, ErrClosed):
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, View{Post: post, Views: n, Duplicate: dup})
+	}
+}
+
+func (s *Store) get(w http.ResponseWriter, r *http.Request) {
+	post := r.PathValue("id")
+	writeJSON(w, http.StatusOK, View{Post: post, Views: s.Get(post)})
+}
+
+func (s *Store) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Stats(r.PathValue("id")))
+}
+
+func (s *Store) unique(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	n, err := s.Unique(ids...)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"posts": ids, "unique": n})
+}
+
+func (s *Store) list(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 {
+		writeJSON(w, http.StatusOK, s.Counts())
+		return
+	}
+	out := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		out[id] = s.Get(id)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,25 @@
+package views
+
+import (
+	"os"
+
+	"github.com/Shehbab-Kakkar/toolkit/internal/wal"
+)
+
+// record is one durable increment
+type record struct {
+	LSN  uint64 `json:"lsn"`
+	Post string `json:"post"`
+	By   int64  `json:"by"`
+	Key  string `json:"key,omitempty"` // idempotency key
+	// Visitor feeds the unique count and At places the views in the windows
+	Visitor string `json:"visitor,omitempty"`
+	At      int64  `json:"at,omitempty"` // unix nanoseconds
+}
+
+func appendRecord(buf []byte, r record) ([]byte, error) { return wal.Append(buf, r) }
+
+// replayLog calls fn for every intact record and returns the offset just past
+// the last one, where the next append belongs. Damage before the tail is an
+// error, see wal.Replay.
+func replayLog(f *os.File, fn func(record)) (int64, error) { return wal.Replay(f, fn) }
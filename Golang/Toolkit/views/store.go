@@ -0,0 +1,590 @@
+// Package views counts page views for many posts, the service version of
+// post.inc in Golang/Mutex/With-Mutex.go. Counts live in sharded maps so
+// readers of different posts never share a lock, and every increment is in
+// an append-only log before it is acknowledged. Periodic snapshots keep the
+// log short; replay skips what a snapshot already holds, so nothing is counted
+// twice after a restart.
+//
+// Alongside the total each post keeps a HyperLogLog sketch of its visitors and
+// ring buffers of views over the last minute, hour and day. Sketches are saved
+// and the window buckets are saved with the snapshot, so both survive restarts.
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/hll"
+	"github.com/Shehbab-Kakkar/toolkit/internal/fsutil"
+)
+
+// ErrClosed is returned by Inc after Close
+var ErrClosed = errors.New("views: store closed")
+
+const (
+	logName      = "views.log"
+	snapshotName = "views.snapshot"
+	maxBatch     = 1024
+)
+
+// Options configures a Store
+type Options struct {
+	Dir    string
+	Shards int // default 64
+	// FlushInterval is how long a batch collects increments before one fsync
+	// acknowledges all of them. By default a batch is whatever queued up
+	// during the previous fsync, which batches well under load without making
+	// a lone request wait.
+	FlushInterval time.Duration
+	// A snapshot is taken every SnapshotInterval or SnapshotEvery log records
+	SnapshotInterval time.Duration
+	SnapshotEvery    int
+	// DedupWindow is how many idempotency keys are remembered
+	DedupWindow int
+	// UniquePrecision sizes each post's visitor sketch at 2^p bytes; the
+	// default 12 gives about 1.6% error
+	UniquePrecision uint8
+	Now             func() time.Time
+}
+
+func (o *Options) defaults() {
+	if o.Shards <= 0 {
+		o.Shards = 64
+	}
+	if o.SnapshotInterval <= 0 {
+		o.SnapshotInterval = time.Minute
+	}
+	if o.SnapshotEvery <= 0 {
+		o.SnapshotEvery = 100_000
+	}
+	if o.DedupWindow <= 0 {
+		o.DedupWindow = 100_000
+	}
+	if o.UniquePrecision == 0 {
+		o.UniquePrecision = 12
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+}
+
+type shard struct {
+	mu      sync.RWMutex
+	counts  map[string]int64
+	traffic map[string]*traffic
+}
+
+// traffic is everything about a post beyond its total
+type traffic struct {
+	unique  *hll.Sketch // nil until a view names its visitor
+	windows [len(windowSpans)]*window
+}
+
+// Store is a durable set of counters
+type Store struct {
+	opts   Options
+	shards []shard
+
+	reqs   chan *incReq
+	quit   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+	err    error // why the writer stopped
+
+	// owned by the writer goroutine after Open
+	log         *os.File
+	lsn         uint64
+	sinceSnap   int
+	keys        map[string]bool
+	keyRing     []string
+	keyNext     int
+	snapshotLSN uint64
+}
+
+type incReq struct {
+	post    string
+	by      int64
+	key     string
+	visitor string
+	resp    chan incResp
+}
+
+type incResp struct {
+	count int64
+	dup   bool
+	err   error
+}
+
+// snapshot is the on-disk form of all counts up to LSN
+type snapshot struct {
+	LSN    uint64           `json:"lsn"`
+	Counts map[string]int64 `json:"counts"`
+	Keys   []string         `json:"keys,omitempty"` // oldest first
+	// Uniques holds each post's visitor sketch, see hll.Sketch.MarshalBinary
+	Uniques map[string][]byte `json:"uniques,omitempty"`
+	// Windows holds each post's minute, hour and day buckets
+	Windows map[string][len(windowSpans)]windowState `json:"windows,omitempty"`
+}
+
+// Open loads the latest snapshot, replays the log written after it and starts the writer
+func Open(opts Options) (*Store, error) {
+	opts.defaults()
+	if _, err := hll.New(opts.UniquePrecision); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		opts:   opts,
+		shards: make([]shard, opts.Shards),
+		reqs:   make(chan *incReq),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+		keys:   map[string]bool{},
+	}
+	for i := range s.shards {
+		s.shards[i].counts = map[string]int64{}
+		s.shards[i].traffic = map[string]*traffic{}
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	go s.writer()
+	return s, nil
+}
+
+func (s *Store) load() error {
+	b, err := os.ReadFile(filepath.Join(s.opts.Dir, snapshotName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(b, &snap); err != nil {
+			return fmt.Errorf("views: snapshot: %w", err)
+		}
+		for post, n := range snap.Counts {
+			s.shard(post).counts[post] = n
+		}
+		for post, b := range snap.Uniques {
+			sk := new(hll.Sketch)
+			if err := sk.UnmarshalBinary(b); err != nil {
+				return fmt.Errorf("views: snapshot: sketch of %q: %w", post, err)
+			}
+			s.shard(post).traffic[post] = &traffic{unique: sk}
+		}
+		for post, states := range snap.Windows {
+			tr := s.shard(post).traffic[post]
+			if tr == nil {
+				tr = &traffic{}
+				s.shard(post).traffic[post] = tr
+			}
+			for i, ws := range windowSpans {
+				tr.windows[i] = newWindow(ws.span, ws.width)
+				tr.windows[i].restore(states[i])
+			}
+		}
+		for _, k := range snap.Keys {
+			s.remember(k)
+		}
+		s.lsn, s.snapshotLSN = snap.LSN, snap.LSN
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.opts.Dir, logName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	end, err := replayLog(f, func(r record) {
+		// records up to the snapshot are already in the counts
+		if r.LSN <= s.snapshotLSN {
+			return
+		}
+		s.apply(r)
+		s.sinceSnap++
+	})
+	if err == nil {
+		// drop a torn tail so new records follow the last good one
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.log = f
+	return nil
+}
+
+func (s *Store) shard(post string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(post))
+	return &s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// apply adds a record to the counts; only load and the writer call it
+func (s *Store) apply(r record) int64 {
+	sh := s.shard(r.Post)
+	sh.mu.Lock()
+	sh.counts[r.Post] += r.By
+	n := sh.counts[r.Post]
+	tr := sh.traffic[r.Post]
+	if tr == nil {
+		tr = &traffic{}
+		sh.traffic[r.Post] = tr
+	}
+	if r.Visitor != "" {
+		if tr.unique == nil {
+			tr.unique, _ = hll.New(s.opts.UniquePrecision)
+		}
+		tr.unique.AddString(r.Visitor)
+	}
+	if r.At != 0 {
+		at := time.Unix(0, r.At)
+		for i, ws := range windowSpans {
+			if tr.windows[i] == nil {
+				tr.windows[i] = newWindow(ws.span, ws.width)
+			}
+			tr.windows[i].add(at, r.By)
+		}
+	}
+	sh.mu.Unlock()
+	if r.Key != "" {
+		s.remember(r.Key)
+	}
+	if r.LSN > s.lsn {
+		s.lsn = r.LSN
+	}
+	return n
+}
+
+// remember adds an idempotency key, forgetting the oldest beyond DedupWindow
+func (s *Store) remember(key string) {
+	if len(s.keyRing) < s.opts.DedupWindow {
+		s.keyRing = append(s.keyRing, key)
+	} else {
+		delete(s.keys, s.keyRing[s.keyNext])
+		s.keyRing[s.keyNext] = key
+		s.keyNext = (s.keyNext + 1) % len(s.keyRing)
+	}
+	s.keys[key] = true
+}
+
+// Get returns the current count of post
+func (s *Store) Get(post string) int64 {
+	sh := s.shard(post)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.counts[post]
+}
+
+// Counts returns a copy of every count
+func (s *Store) Counts() map[string]int64 {
+	out := map[string]int64{}
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for k, v := range sh.counts {
+			out[k] = v
+		}
+		sh.mu.RUnlock()
+	}
+	return out
+}
+
+// Inc adds by to post once the increment is on disk and returns the new
+// count. A key that was already used returns the current count with dup set
+// and changes nothing, so clients can retry safely.
+func (s *Store) Inc(ctx context.Context, post string, by int64, key string) (count int64, dup bool, err error) {
+	return s.Add(ctx, Increment{Post: post, By: by, Key: key})
+}
+
+// Increment is one call to Add
+type Increment struct {
+	Post    string
+	By      int64
+	Key     string // idempotency key, optional
+	Visitor string // counted towards unique visitors when set
+}
+
+// Add is Inc that also records who viewed the post
+func (s *Store) Add(ctx context.Context, inc Increment) (count int64, dup bool, err error) {
+	req := &incReq{post: inc.Post, by: inc.By, key: inc.Key, visitor: inc.Visitor, resp: make(chan incResp, 1)}
+	select {
+	case s.reqs <- req:
+	case <-s.done:
+		return 0, false, s.stopErr()
+	case <-ctx.Done():
+		return 0, false, ctx.Err()
+	}
+	// once the writer has it the increment will happen; wait for it even if
+	// ctx ends so the caller learns the outcome
+	r := <-req.resp
+	return r.count, r.dup, r.err
+}
+
+func (s *Store) stopErr() error {
+	<-s.done
+	if s.err != nil {
+		return s.err
+	}
+	return ErrClosed
+}
+
+// writer batches increments so one fsync acknowledges many of them
+func (s *Store) writer() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		var first *incReq
+		select {
+		case <-s.quit:
+			s.err = s.finish()
+			return
+		case <-ticker.C:
+			if s.sinceSnap > 0 {
+				if s.err = s.snapshot(); s.err != nil {
+					s.log.Close()
+					return
+				}
+			}
+			continue
+		case first = <-s.reqs:
+		}
+
+		batch := s.collect(first)
+		if err := s.commit(batch); err != nil {
+			s.err = err
+			s.log.Close()
+			return
+		}
+		if s.sinceSnap >= s.opts.SnapshotEvery {
+			if s.err = s.snapshot(); s.err != nil {
+				s.log.Close()
+				return
+			}
+		}
+	}
+}
+
+// collect gathers the requests that follow first into one batch
+func (s *Store) collect(first *incReq) []*incReq {
+	batch := []*incReq{first}
+	var wait <-chan time.Time
+	if s.opts.FlushInterval > 0 {
+		timer := time.NewTimer(s.opts.FlushInterval)
+		defer timer.Stop()
+		wait = timer.C
+	}
+	for len(batch) < maxBatch {
+		if wait == nil {
+			select {
+			case r := <-s.reqs:
+				batch = append(batch, r)
+			default:
+				return batch
+			}
+			continue
+		}
+		select {
+		case r := <-s.reqs:
+			batch = append(batch, r)
+		case <-wait:
+			return batch
+		}
+	}
+	return batch
+}
+
+// commit writes the batch, fsyncs, then applies and acknowledges it
+func (s *Store) commit(batch []*incReq) error {
+	var buf []byte
+	recs := make([]*record, len(batch))
+	pending := map[string]bool{} // keys earlier in this batch
+	lsn := s.lsn
+	at := s.opts.Now().UnixNano()
+	for i, req := range batch {
+		if req.key != "" && (s.keys[req.key] || pending[req.key]) {
+			continue
+		}
+		rec := record{LSN: lsn + 1, Post: req.post, By: req.by, Key: req.key, Visitor: req.visitor, At: at}
+		var err error
+		if buf, err = appendRecord(buf, rec); err != nil {
+			// this increment alone can't be logged; the rest of the batch can
+			req.resp <- incResp{err: fmt.Errorf("views: %w", err)}
+			batch[i] = nil
+			continue
+		}
+		lsn++
+		recs[i] = &rec
+		if req.key != "" {
+			pending[req.key] = true
+		}
+	}
+
+	if len(buf) > 0 {
+		_, err := s.log.Write(buf)
+		if err == nil {
+			err = s.log.Sync()
+		}
+		if err != nil {
+			for _, req := range batch {
+				if req != nil {
+					req.resp <- incResp{err: err}
+				}
+			}
+			return err
+		}
+	}
+
+	for i, req := range batch {
+		switch {
+		case req == nil: // answered above
+		case recs[i] == nil:
+			req.resp <- incResp{count: s.Get(req.post), dup: true}
+		default:
+			req.resp <- incResp{count: s.apply(*recs[i])}
+			s.sinceSnap++
+		}
+	}
+	return nil
+}
+
+// snapshot writes every count and key up to s.lsn and then empties the log.
+// A crash between the two leaves records the next load skips by LSN.
+func (s *Store) snapshot() error {
+	snap := snapshot{LSN: s.lsn, Counts: s.Counts()}
+	snap.Keys = append(snap.Keys, s.keyRing[s.keyNext:]...)
+	snap.Keys = append(snap.Keys, s.keyRing[:s.keyNext]...)
+	snap.Uniques = map[string][]byte{}
+	snap.Windows = map[string][len(windowSpans)]windowState{}
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for post, tr := range sh.traffic {
+			if tr.unique != nil {
+				snap.Uniques[post], _ = tr.unique.MarshalBinary()
+			}
+			if tr.windows[0] != nil {
+				var states [len(windowSpans)]windowState
+				for j, w := range tr.windows {
+					states[j] = w.state()
+				}
+				// the day window holds the others' views too
+				if len(states[len(states)-1].Counts) > 0 {
+					snap.Windows[post] = states
+				}
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	err := fsutil.WriteFileAtomic(filepath.Join(s.opts.Dir, snapshotName), 0o644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snap)
+	})
+	if err == nil {
+		err = fsutil.SyncDir(s.opts.Dir)
+	}
+	if err == nil {
+		err = s.log.Truncate(0)
+	}
+	if err == nil {
+		_, err = s.log.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("views: snapshot: %w", err)
+	}
+	s.snapshotLSN, s.sinceSnap = s.lsn, 0
+	return nil
+}
+
+// finish takes a final snapshot and closes the log
+func (s *Store) finish() error {
+	var err error
+	if s.sinceSnap > 0 {
+		err = s.snapshot()
+	}
+	return errors.Join(err, s.log.Close())
+}
+
+// Close takes a final snapshot and stops the writer. Inc calls after Close fail with ErrClosed.
+func (s *Store) Close() error {
+	s.closed.Do(func() { close(s.quit) })
+	<-s.done
+	return s.err
+}
+
+// PostStats is what Stats reports about one post
+type PostStats struct {
+	Post       string `json:"post"`
+	Total      int64  `json:"total"`
+	Unique     uint64 `json:"unique"` // estimated distinct visitors
+	LastMinute int64  `json:"last_minute"`
+	LastHour   int64  `json:"last_hour"`
+	LastDay    int64  `json:"last_day"`
+}
+
+// Stats returns the total, unique and windowed views of post
+func (s *Store) Stats(post string) PostStats {
+	now := s.opts.Now()
+	st := PostStats{Post: post}
+	sh := s.shard(post)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	st.Total = sh.counts[post]
+	tr := sh.traffic[post]
+	if tr == nil {
+		return st
+	}
+	if tr.unique != nil {
+		st.Unique = tr.unique.Estimate()
+	}
+	for i, p := range []*int64{&st.LastMinute, &st.LastHour, &st.LastDay} {
+		if tr.windows[i] != nil {
+			*p = tr.windows[i].sum(now)
+		}
+	}
+	return st
+}
+
+// Unique estimates the distinct visitors across all of posts by merging their
+// sketches, so a visitor who read several of them counts once. Sketches
+// saved before UniquePrecision changed can't be merged with newer ones; that
+// is an error rather than an undercount.
+func (s *Store) Unique(posts ...string) (uint64, error) {
+	var union *hll.Sketch
+	for _, post := range posts {
+		sh := s.shard(post)
+		sh.mu.RLock()
+		var err error
+		if tr := sh.traffic[post]; tr != nil && tr.unique != nil {
+			if union == nil {
+				union = tr.unique.Clone()
+			} else if err = union.Merge(tr.unique); err != nil {
+				err = fmt.Errorf("views: unique of %q: %w", post, err)
+			}
+		}
+		sh.mu.RUnlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if union == nil {
+		return 0, nil
+	}
+	return union.Estimate(), nil
+}
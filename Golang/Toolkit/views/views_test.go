@@ -0,0 +1,385 @@
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/hll"
+)
+
+func open(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := Open(Options{Dir: dir, Shards: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// crashImage copies what is on disk right now, which is what a restart after
+// a crash would find, into a fresh directory
+func crashImage(t *testing.T, dir string) string {
+	t.Helper()
+	img := t.TempDir()
+	for _, name := range []string{logName, snapshotName} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(img, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return img
+}
+
+func TestExactlyOnceAcrossCrash(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	defer s.Close()
+
+	// like post.inc, 100 goroutines; every acknowledged increment must survive
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			post := fmt.Sprintf("post-%d", i%5)
+			if _, _, err := s.Inc(context.Background(), post, 1, fmt.Sprintf("req-%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	img := crashImage(t, dir)
+	r := open(t, img)
+	for i := range 5 {
+		if n := r.Get(fmt.Sprintf("post-%d", i)); n != 20 {
+			t.Errorf("post-%d: %d views after restart, want 20", i, n)
+		}
+	}
+
+	// a client retrying after the crash is not counted again
+	n, dup, err := r.Inc(context.Background(), "post-0", 1, "req-0")
+	if err != nil || !dup || n != 20 {
+		t.Fatalf("retry: n=%d dup=%v err=%v", n, dup, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys live on in the snapshot Close wrote
+	r = open(t, img)
+	defer r.Close()
+	if _, dup, _ := r.Inc(context.Background(), "post-1", 1, "req-1"); !dup {
+		t.Fatal("key forgotten after snapshot")
+	}
+	if n, _, _ := r.Inc(context.Background(), "post-1", 1, "new"); n != 21 {
+		t.Fatalf("post-1 = %d, want 21", n)
+	}
+}
+
+func TestTornTailIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	for range 3 {
+		s.Inc(context.Background(), "a", 1, "")
+	}
+	img := crashImage(t, dir)
+	s.Close()
+
+	// half of a record that never got acknowledged
+	rec, _ := appendRecord(nil, record{LSN: 4, Post: "a", By: 100})
+	f, err := os.OpenFile(filepath.Join(img, logName), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(rec[:len(rec)-3])
+	f.Close()
+
+	r := open(t, img)
+	if n := r.Get("a"); n != 3 {
+		t.Fatalf("a = %d, want 3", n)
+	}
+	// the next record replaces the torn one rather than following it
+	r.Inc(context.Background(), "a", 2, "")
+	img2 := crashImage(t, img)
+	r.Close()
+
+	r = open(t, img2)
+	defer r.Close()
+	if n := r.Get("a"); n != 5 {
+		t.Fatalf("a = %d after second restart, want 5", n)
+	}
+}
+
+func TestSnapshotSkipsLoggedRecords(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	for range 5 {
+		s.Inc(context.Background(), "a", 1, "")
+	}
+	logged, err := os.ReadFile(filepath.Join(dir, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash after the snapshot but before the log was emptied
+	if err := os.WriteFile(filepath.Join(dir, logName), logged, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := open(t, dir)
+	defer r.Close()
+	if n := r.Get("a"); n != 5 {
+		t.Fatalf("a = %d, want 5", n)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	s, err := Open(Options{Dir: t.TempDir(), DedupWindow: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	for _, k := range []string{"k1", "k2", "k3"} {
+		s.Inc(ctx, "a", 1, k)
+	}
+	if _, dup, _ := s.Inc(ctx, "a", 1, "k3"); !dup {
+		t.Error("k3 should still be remembered")
+	}
+	if _, dup, _ := s.Inc(ctx, "a", 1, "k1"); dup {
+		t.Error("k1 should have been forgotten")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := open(t, t.TempDir())
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	post := func(path, key string) (int, View) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var v View
+		json.NewDecoder(res.Body).Decode(&v)
+		return res.StatusCode, v
+	}
+
+	if code, v := post("/posts/hello/views", "x"); code != 200 || v.Views != 1 {
+		t.Fatalf("%d %+v", code, v)
+	}
+	if _, v := post("/posts/hello/views", "x"); !v.Duplicate || v.Views != 1 {
+		t.Fatalf("retry counted: %+v", v)
+	}
+	if _, v := post("/posts/hello/views?by=4", ""); v.Views != 5 {
+		t.Fatalf("%+v", v)
+	}
+	if code, _ := post("/posts/hello/views?by=-1", ""); code != http.StatusBadRequest {
+		t.Fatalf("negative by: %d", code)
+	}
+
+	res, err := http.Get(srv.URL + "/posts/views?id=hello&id=other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts map[string]int64
+	json.NewDecoder(res.Body).Decode(&counts)
+	res.Body.Close()
+	if counts["hello"] != 5 || counts["other"] != 0 || len(counts) != 2 {
+		t.Fatalf("%v", counts)
+	}
+
+	s.Close()
+	if code, _ := post("/posts/hello/views", ""); code != http.StatusServiceUnavailable {
+		t.Fatalf("after Close: %d", code)
+	}
+}
+
+func BenchmarkInc(b *testing.B) {
+	s, err := Open(Options{Dir: b.TempDir()})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	b.SetParallelism(64)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.Inc(context.Background(), fmt.Sprintf("post-%d", i%100), 1, "")
+			i++
+		}
+	})
+}
+
+// clock is a settable Options.Now
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestWindows(t *testing.T) {
+	clk := &clock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s, err := Open(Options{Dir: t.TempDir(), Now: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	s.Inc(ctx, "a", 5, "")
+	clk.advance(30 * time.Second)
+	s.Inc(ctx, "a", 1, "")
+	if st := s.Stats("a"); st.Total != 6 || st.LastMinute != 6 || st.LastHour != 6 || st.LastDay != 6 {
+		t.Fatalf("%+v", st)
+	}
+
+	clk.advance(45 * time.Second) // the first 5 are now 75s old
+	if st := s.Stats("a"); st.LastMinute != 1 || st.LastHour != 6 {
+		t.Fatalf("after 75s: %+v", st)
+	}
+	clk.advance(2 * time.Hour)
+	s.Inc(ctx, "a", 2, "")
+	if st := s.Stats("a"); st.LastMinute != 2 || st.LastHour != 2 || st.LastDay != 8 {
+		t.Fatalf("after 2h: %+v", st)
+	}
+	clk.advance(25 * time.Hour)
+	if st := s.Stats("a"); st.Total != 8 || st.LastDay != 0 {
+		t.Fatalf("after a day: %+v", st)
+	}
+
+	// a slot reused a full ring later starts from zero
+	w := newWindow(time.Minute, time.Second)
+	at := clk.Now()
+	w.add(at, 3)
+	w.add(at.Add(time.Minute), 1)
+	if n := w.sum(at.Add(time.Minute)); n != 1 {
+		t.Fatalf("stale slot counted: %d", n)
+	}
+	// a late view for the slot's previous bucket doesn't wipe the newer one
+	w.add(at, 7)
+	if n := w.sum(at.Add(time.Minute)); n != 1 {
+		t.Fatalf("late view reset the slot: %d", n)
+	}
+}
+
+func TestWindowsSurviveRestart(t *testing.T) {
+	clk := &clock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	s, err := Open(Options{Dir: dir, Now: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s.Inc(ctx, "a", 4, "")
+	clk.advance(10 * time.Minute)
+	s.Inc(ctx, "a", 1, "")
+	// Close snapshots and empties the log, so the windows come back from the snapshot
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, logName)); err != nil || fi.Size() != 0 {
+		t.Fatalf("log not emptied: %v", err)
+	}
+
+	s, err = Open(Options{Dir: dir, Now: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if st := s.Stats("a"); st.LastMinute != 1 || st.LastHour != 5 || st.LastDay != 5 {
+		t.Fatalf("%+v", st)
+	}
+	clk.advance(time.Hour)
+	if st := s.Stats("a"); st.LastHour != 0 || st.LastDay != 5 {
+		t.Fatalf("an hour later: %+v", st)
+	}
+}
+
+func TestUniqueVisitors(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	defer s.Close()
+	ctx := context.Background()
+
+	// 3000 readers of a, each three times; 1000 of them also read b
+	for round := range 3 {
+		for v := range 3000 {
+			visitor := fmt.Sprintf("visitor-%d", v)
+			s.Add(ctx, Increment{Post: "a", By: 1, Visitor: visitor})
+			if round == 0 && v < 1000 {
+				s.Add(ctx, Increment{Post: "b", By: 1, Visitor: visitor})
+			}
+		}
+	}
+	near := func(got uint64, want float64) bool {
+		// precision 12 has a 1.6% standard error; allow four of them
+		return math.Abs(float64(got)-want)/want <= 4*0.0163
+	}
+	if st := s.Stats("a"); st.Total != 9000 || !near(st.Unique, 3000) {
+		t.Fatalf("%+v", st)
+	}
+	if u, err := s.Unique("a", "b"); err != nil || !near(u, 3000) {
+		t.Fatalf("a and b share their readers, got %d (%v)", u, err)
+	}
+
+	// sketches come back from the log after a crash and from the snapshot after Close
+	img := crashImage(t, dir)
+	r := open(t, img)
+	if st := r.Stats("b"); st.Total != 1000 || !near(st.Unique, 1000) {
+		t.Fatalf("after crash: %+v", st)
+	}
+	r.Close()
+	r = open(t, img)
+	if st := r.Stats("a"); !near(st.Unique, 3000) {
+		t.Fatalf("after snapshot: %+v", st)
+	}
+	r.Close()
+
+	// sketches saved at precision 12 don't merge with new ones at 10
+	r, err := Open(Options{Dir: img, UniquePrecision: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Add(ctx, Increment{Post: "c", By: 1, Visitor: "new"})
+	if _, err := r.Unique("a", "c"); !errors.Is(err, hll.ErrPrecision) {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package views
+
+import "time"
+
+// window counts over the last len(counts)*width with a ring of buckets. Each
+// slot remembers which bucket it holds, so stale slots are skipped on read and
+// reused on write without a background sweeper.
+type window struct {
+	width  time.Duration
+	counts []int64
+	bucket []int64 // bucket number held by each slot
+}
+
+func newWindow(span, width time.Duration) *window {
+	n := int(span / width)
+	return &window{width: width, counts: make([]int64, n), bucket: make([]int64, n)}
+}
+
+func (w *window) add(at time.Time, n int64) {
+	b := at.UnixNano() / int64(w.width)
+	i := b % int64(len(w.counts))
+	switch {
+	case w.bucket[i] > b:
+		// older than what the slot now holds, so outside every window
+		return
+	case w.bucket[i] < b:
+		w.bucket[i], w.counts[i] = b, 0
+	}
+	w.counts[i] += n
+}
+
+// windowState is the slots of a window that hold views, as a snapshot saves them
+type windowState struct {
+	Buckets []int64 `json:"buckets"`
+	Counts  []int64 `json:"counts"`
+}
+
+func (w *window) state() windowState {
+	var st windowState
+	for i, n := range w.counts {
+		if n != 0 {
+			st.Buckets = append(st.Buckets, w.bucket[i])
+			st.Counts = append(st.Counts, n)
+		}
+	}
+	return st
+}
+
+func (w *window) restore(st windowState) {
+	for i := range min(len(st.Buckets), len(st.Counts)) {
+		w.add(time.Unix(0, st.Buckets[i]*int64(w.width)), st.Counts[i])
+	}
+}
+
+// sum counts everything in the buckets that overlap (now-span, now]. The
+// oldest bucket may be partly outside, so the window slides a bucket at a time.
+func (w *window) sum(now time.Time) int64 {
+	cur := now.UnixNano() / int64(w.width)
+	oldest := cur - int64(len(w.counts)) + 1
+	var total int64
+	for i, b := range w.bucket {
+		if b >= oldest && b <= cur {
+			total += w.counts[i]
+		}
+	}
+	return total
+}
+
+// The windows PostStats reports, with the bucket width of each
+var windowSpans = [...]struct{ span, width time.Duration }{
+	{time.Minute, time.Second},
+	{time.Hour, time.Minute},
+	{24 * time.Hour, 10 * time.Minute},
+}
@@ -1,3 +1,7 @@
 module github-gists-api
 
-go 1.22
+go 1.23
+
+require github.com/Shehbab-Kakkar/toolkit v0.0.0
+
+replace github.com/Shehbab-Kakkar/toolkit => ../Toolkit
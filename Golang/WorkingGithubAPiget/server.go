@@ -1,11 +1,21 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/Shehbab-Kakkar/toolkit/pager"
+	"github.com/Shehbab-Kakkar/toolkit/urlbuilder"
+)
+
+const (
+	githubAPI = "https://api.github.com"
+	userAgent = "golang-gists-api"
 )
 
 // Server holds HTTP client
@@ -15,11 +25,15 @@ type Server struct {
 
 // NewServer returns a http.Handler
 func NewServer() http.Handler {
-	s := &Server{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
+	return NewServerWithClient(&http.Client{
+		Timeout: 10 * time.Second,
+	})
+}
+
+// NewServerWithClient returns a http.Handler that calls GitHub through client,
+// e.g. a vcr recorder in tests
+func NewServerWithClient(client *http.Client) http.Handler {
+	s := &Server{client: client}
 	return http.HandlerFunc(s.handleUserGists)
 }
 
@@ -32,17 +46,35 @@ func (s *Server) handleUserGists(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Pagination parameters
-	page := r.URL.Query().Get("page")
-	if page == "" {
-		page = "1"
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	perPage := r.URL.Query().Get("per_page")
-	if perPage == "" {
-		perPage = "5"
+	perPage, err := queryInt(r, "per_page", 5)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	url := fmt.Sprintf("https://api.github.com/users/%s/gists?page=%s&per_page=%s", user, page, perPage)
-	req, err := http.NewRequest("GET", url, nil)
+	url, err := urlbuilder.New(githubAPI).
+		Path("/users/{user}/gists").
+		Param("user", user).
+		Query("page", page).
+		Query("per_page", perPage).
+		Build()
+	if err != nil {
+		http.Error(w, "failed to build GitHub URL", http.StatusBadRequest)
+		return
+	}
+
+	// ?all=true walks every page by following GitHub's Link header
+	if r.URL.Query().Get("all") == "true" {
+		s.writeAllGists(w, r, url.String())
+		return
+	}
+
+	req, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
 		http.Error(w, "failed to create request", http.StatusInternalServerError)
 		return
@@ -53,7 +85,7 @@ func (s *Server) handleUserGists(w http.ResponseWriter, r *http.Request) {
 		req.Header.Set("Authorization", "token "+token)
 	}
 
-	req.Header.Set("User-Agent", "golang-gists-api")
+	req.Header.Set("User-Agent", userAgent)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -76,3 +108,36 @@ func (s *Server) handleUserGists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(body)
 }
+
+// writeAllGists collects every page starting at firstPage into one JSON array
+func (s *Server) writeAllGists(w http.ResponseWriter, r *http.Request, firstPage string) {
+	opts := []pager.Option{pager.WithHeader("User-Agent", userAgent)}
+	if token := r.Header.Get("GITHUB_TOKEN"); token != "" {
+		opts = append(opts, pager.WithHeader("Authorization", "token "+token))
+	}
+
+	gists := []json.RawMessage{}
+	for gist, err := range pager.New[json.RawMessage](s.client, firstPage, pager.LinkHeader(), opts...).All(r.Context()) {
+		if err != nil {
+			http.Error(w, "failed to contact GitHub", http.StatusBadGateway)
+			return
+		}
+		gists = append(gists, gist)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gists)
+}
+
+// queryInt reads a positive integer query parameter, or def when it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+	return n, nil
+}
@@ -5,10 +5,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/Shehbab-Kakkar/toolkit/vcr"
 )
 
+// Replays testdata/octocat_gists.json; refresh it with VCR_MODE=record go test ./...
 func TestOctocatGists(t *testing.T) {
-	server := NewServer()
+	recorder := vcr.NewForTest(t, "testdata/octocat_gists.json")
+	server := NewServerWithClient(recorder.Client())
 	req := httptest.NewRequest(http.MethodGet, "/octocat", nil)
 	rr := httptest.NewRecorder()
 